@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// backgroundStyles are the generated backgrounds drawn over the theme
+// gradient, selected with -background.
+var backgroundStyles = []string{"waves", "blobs", "grid", "dots", "noise", "none"}
+
+const defaultBackground = "waves"
+
+func parseBackgroundStyle(s string) (string, error) {
+	if s == "" {
+		return defaultBackground, nil
+	}
+	for _, style := range backgroundStyles {
+		if s == style {
+			return style, nil
+		}
+	}
+	return "", fmt.Errorf("unknown background %q. Use: %s", s, strings.Join(backgroundStyles, ", "))
+}
+
+// backgroundSeed hashes seed, or the project name when seed is empty, so a
+// project always gets the same background and different projects get
+// different ones.
+func backgroundSeed(name, seed string) uint64 {
+	if seed == "" {
+		seed = name
+	}
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	return h.Sum64()
+}
+
+// newBackgroundRand returns the generator every shape is drawn from. PCG's
+// output is fixed by its definition, so backgrounds do not change between
+// Go releases.
+func newBackgroundRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed^0x9E3779B97F4A7C15))
+}
+
+// between returns a random value in [lo, hi).
+func between(rng *rand.Rand, lo, hi float64) float64 {
+	return lo + rng.Float64()*(hi-lo)
+}
+
+// seededWaves returns a filled wave along the bottom of the canvas and a
+// wave line above it, drawn at the default canvas and scaled to l. Each is
+// a smooth chain of cubic curves through randomly placed points.
+func seededWaves(seed uint64, l Layout) (fill, line string) {
+	rng := newBackgroundRand(seed)
+	w := float64(defaultCanvas.Width)
+	h := float64(defaultCanvas.Height)
+	sx := l.Width / w
+	sy := l.Height / h
+
+	fill = wavePath(rng, between(rng, 430, 470), 60) + fmt.Sprintf(" L%s %s L0 %s Z", formatNumber(w), formatNumber(h), formatNumber(h))
+	line = wavePath(rng, between(rng, 360, 400), 50)
+	return scalePath(fill, sx, sy), scalePath(line, sx, sy)
+}
+
+// wavePath draws a curve across the default canvas around baseline y, with
+// two to four segments whose ends vary by up to amplitude. Neighbouring
+// segments share their tangent, so the joins are smooth.
+func wavePath(rng *rand.Rand, y, amplitude float64) string {
+	w := float64(defaultCanvas.Width)
+	n := 2 + rng.IntN(3)
+	xs := make([]float64, n+1)
+	ys := make([]float64, n+1)
+	slopes := make([]float64, n+1)
+	for i := range xs {
+		xs[i] = w * float64(i) / float64(n)
+		if i > 0 && i < n {
+			xs[i] += between(rng, -0.15, 0.15) * w / float64(n)
+		}
+		ys[i] = y + between(rng, -amplitude, amplitude)
+		slopes[i] = between(rng, -0.35, 0.35)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "M0 %s", formatNumber(ys[0]))
+	for i := 0; i < n; i++ {
+		dx := (xs[i+1] - xs[i]) / 3
+		fmt.Fprintf(&b, " C %s %s, %s %s, %s %s",
+			formatNumber(xs[i]+dx), formatNumber(ys[i]+slopes[i]*dx),
+			formatNumber(xs[i+1]-dx), formatNumber(ys[i+1]-slopes[i+1]*dx),
+			formatNumber(xs[i+1]), formatNumber(ys[i+1]))
+	}
+	return b.String()
+}
+
+// generateBackground returns the shapes of a background style for layout l.
+// Filled shapes use the theme's wave gradient and lines are translucent
+// white, like the original waves, so every theme applies.
+func generateBackground(style string, seed uint64, l Layout) []*SVGNode {
+	rng := newBackgroundRand(seed)
+	switch style {
+	case "waves":
+		return []*SVGNode{
+			svgElement("path", "d", l.WavePath, "fill", "url(#waveGradient)"),
+			svgElement("path", "d", l.WaveLine, "fill", "none", "stroke", "#FFFFFF", "stroke-opacity", "0.20", "stroke-width", formatNumber(l.StrokeWidth)),
+		}
+	case "blobs":
+		return blobShapes(rng, l)
+	case "grid":
+		return gridShapes(rng, l)
+	case "dots":
+		return dotShapes(rng, l)
+	case "noise":
+		return noiseShapes(rng, seed, l)
+	}
+	return nil
+}
+
+// blobShapes draws three to five rounded blobs, each a closed curve through
+// points at jittered angles and radii.
+func blobShapes(rng *rand.Rand, l Layout) []*SVGNode {
+	var nodes []*SVGNode
+	for n := 3 + rng.IntN(3); n > 0; n-- {
+		cx := between(rng, 0, l.Width)
+		cy := between(rng, 0, l.Height)
+		r := between(rng, 0.25, 0.45) * l.Height
+		k := 6 + rng.IntN(3)
+		points := make([][2]float64, k)
+		offset := between(rng, 0, 2*math.Pi)
+		for i := range points {
+			angle := offset + 2*math.Pi*(float64(i)+between(rng, -0.2, 0.2))/float64(k)
+			radius := r * between(rng, 0.75, 1.2)
+			points[i] = [2]float64{cx + radius*math.Cos(angle), cy + radius*math.Sin(angle)}
+		}
+		opacity := formatNumber(between(rng, 0.6, 1))
+		nodes = append(nodes, svgElement("path", "d", closedCurve(points), "fill", "url(#waveGradient)", "opacity", opacity))
+	}
+	return nodes
+}
+
+// closedCurve draws a smooth closed path through points, converting the
+// Catmull-Rom spline through them to cubic curves.
+func closedCurve(points [][2]float64) string {
+	k := len(points)
+	at := func(i int) [2]float64 { return points[(i+k)%k] }
+	var b strings.Builder
+	fmt.Fprintf(&b, "M%s %s", formatNumber(points[0][0]), formatNumber(points[0][1]))
+	for i := 0; i < k; i++ {
+		p0, p1, p2, p3 := at(i-1), at(i), at(i+1), at(i+2)
+		fmt.Fprintf(&b, "C%s %s %s %s %s %s",
+			formatNumber(p1[0]+(p2[0]-p0[0])/6), formatNumber(p1[1]+(p2[1]-p0[1])/6),
+			formatNumber(p2[0]-(p3[0]-p1[0])/6), formatNumber(p2[1]-(p3[1]-p1[1])/6),
+			formatNumber(p2[0]), formatNumber(p2[1]))
+	}
+	b.WriteString("Z")
+	return b.String()
+}
+
+// gridShapes draws a grid of thin lines, turned by a small angle, with a few
+// of its cells filled.
+func gridShapes(rng *rand.Rand, l Layout) []*SVGNode {
+	cell := between(rng, 70, 110) * l.scale()
+	angle := between(rng, -18, 18)
+	// The lines extend past the canvas so no corner is left bare when
+	// the grid turns.
+	reach := math.Hypot(l.Width, l.Height)
+	ox := between(rng, 0, cell)
+	oy := between(rng, 0, cell)
+
+	var d strings.Builder
+	for x := ox - math.Ceil(reach/cell)*cell; x <= l.Width+reach; x += cell {
+		fmt.Fprintf(&d, "M%s %sV%s", formatNumber(x), formatNumber(-reach), formatNumber(l.Height+reach))
+	}
+	for y := oy - math.Ceil(reach/cell)*cell; y <= l.Height+reach; y += cell {
+		fmt.Fprintf(&d, "M%s %sH%s", formatNumber(-reach), formatNumber(y), formatNumber(l.Width+reach))
+	}
+
+	group := svgElement("g", "transform", fmt.Sprintf("rotate(%s %s %s)", formatNumber(angle), formatNumber(l.Width/2), formatNumber(l.Height/2)))
+	cols := int(l.Width / cell)
+	rows := int(l.Height / cell)
+	for n := 5 + rng.IntN(5); n > 0 && cols > 0 && rows > 0; n-- {
+		x := ox + float64(rng.IntN(cols))*cell
+		y := oy + float64(rng.IntN(rows))*cell
+		group.AppendChild(svgElement("rect", "x", formatNumber(x), "y", formatNumber(y), "width", formatNumber(cell), "height", formatNumber(cell), "fill", "url(#waveGradient)"))
+	}
+	group.AppendChild(svgElement("path", "d", d.String(), "fill", "none", "stroke", "#FFFFFF", "stroke-opacity", "0.12", "stroke-width", formatNumber(l.StrokeWidth/3)))
+	return []*SVGNode{group}
+}
+
+// dotShapes draws a staggered halftone of dots whose sizes follow a smooth
+// random field.
+func dotShapes(rng *rand.Rand, l Layout) []*SVGNode {
+	spacing := between(rng, 40, 60) * l.scale()
+	maxR := spacing * 0.28
+	fx := 2 * math.Pi / (l.Width * between(rng, 0.4, 0.9))
+	fy := 2 * math.Pi / (l.Height * between(rng, 0.6, 1.2))
+	px := between(rng, 0, 2*math.Pi)
+	py := between(rng, 0, 2*math.Pi)
+
+	group := svgElement("g", "fill", "#FFFFFF", "fill-opacity", "0.18")
+	for row := 0; float64(row)*spacing <= l.Height+spacing; row++ {
+		y := float64(row) * spacing
+		shift := float64(row%2) * spacing / 2
+		for x := shift; x <= l.Width+spacing; x += spacing {
+			r := maxR * (0.25 + 0.75*(0.5+0.5*math.Sin(fx*x+px)*math.Cos(fy*y+py)))
+			if r < 0.5 {
+				continue
+			}
+			group.AppendChild(svgElement("circle", "cx", formatNumber(x), "cy", formatNumber(y), "r", formatNumber(r)))
+		}
+	}
+	return []*SVGNode{group}
+}
+
+// noiseShapes covers the canvas with subtle white grain from fractal noise.
+// The noise has its own seed attribute, taken from the background seed.
+func noiseShapes(rng *rand.Rand, seed uint64, l Layout) []*SVGNode {
+	filter := svgElement("filter", "id", "background-noise", "x", "0", "y", "0", "width", "100%", "height", "100%")
+	filter.AppendChild(svgElement("feTurbulence", "type", "fractalNoise",
+		"baseFrequency", strconv.FormatFloat(math.Round(between(rng, 0.6, 0.9)*100)/100, 'f', -1, 64),
+		"numOctaves", "3", "seed", strconv.FormatUint(seed%100000, 10), "stitchTiles", "stitch"))
+	// White, with the noise's first channel as its alpha.
+	filter.AppendChild(svgElement("feColorMatrix", "type", "matrix", "values", "0 0 0 0 1 0 0 0 0 1 0 0 0 0 1 0.35 0 0 0 0"))
+	return []*SVGNode{
+		filter,
+		svgElement("rect", "width", formatNumber(l.Width), "height", formatNumber(l.Height), "filter", "url(#background-noise)"),
+	}
+}
+
+// svgElement creates an element from name and value pairs.
+func svgElement(name string, attrs ...string) *SVGNode {
+	n := &SVGNode{Kind: ElementNode, Name: name}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.Attrs = append(n.Attrs, SVGAttr{Name: attrs[i], Value: attrs[i+1]})
+	}
+	return n
+}
+
+// setBackgroundSlot draws shapes in the template's background-pattern slot.
+func setBackgroundSlot(doc *SVGDocument, shapes []*SVGNode) {
+	slot := doc.Root.FindByID("background-pattern")
+	if slot == nil {
+		return
+	}
+	for _, shape := range shapes {
+		slot.AppendChild(shape)
+	}
+}
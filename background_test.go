@@ -0,0 +1,130 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBackgroundStyle(t *testing.T) {
+	style, err := parseBackgroundStyle("")
+	require.NoError(t, err)
+	assert.Equal(t, "waves", style)
+
+	for _, name := range backgroundStyles {
+		style, err := parseBackgroundStyle(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, style)
+	}
+
+	_, err = parseBackgroundStyle("stripes")
+	assert.ErrorContains(t, err, `unknown background "stripes". Use: waves, blobs, grid, dots, noise, none`)
+}
+
+func TestBackgroundSeed(t *testing.T) {
+	assert.Equal(t, backgroundSeed("Kit", ""), backgroundSeed("Kit", ""))
+	assert.NotEqual(t, backgroundSeed("Kit", ""), backgroundSeed("Other", ""))
+	assert.Equal(t, backgroundSeed("Kit", "v2"), backgroundSeed("Other", "v2"), "the seed replaces the name")
+	assert.NotEqual(t, backgroundSeed("Kit", ""), backgroundSeed("Kit", "v2"))
+}
+
+func TestSeededWaves(t *testing.T) {
+	l := computeLayout(defaultCanvas, "left")
+	fill, line := seededWaves(backgroundSeed("Kit", ""), l)
+	assert.True(t, strings.HasPrefix(fill, "M0 "), fill)
+	assert.True(t, strings.HasSuffix(fill, " L1600 600 L0 600 Z"), fill)
+	assert.True(t, strings.HasPrefix(line, "M0 "), line)
+	assert.NotContains(t, line, "Z")
+
+	again, _ := seededWaves(backgroundSeed("Kit", ""), l)
+	assert.Equal(t, fill, again)
+	other, _ := seededWaves(backgroundSeed("Other", ""), l)
+	assert.NotEqual(t, fill, other)
+
+	// The waves and their control points stay in the lower part of the
+	// canvas, and scale with it.
+	numbers := pathNumbers(fill + " " + line)
+	for i := 1; i < len(numbers); i += 2 {
+		assert.GreaterOrEqual(t, numbers[i], 200.0)
+		assert.LessOrEqual(t, numbers[i], 600.0)
+	}
+	small, _ := seededWaves(backgroundSeed("Kit", ""), computeLayout(Canvas{Width: 800, Height: 300}, "left"))
+	assert.True(t, strings.HasSuffix(small, " L800 300 L0 300 Z"), small)
+}
+
+func TestGenerateBackground(t *testing.T) {
+	l := computeLayout(defaultCanvas, "left")
+	l.WavePath, l.WaveLine = seededWaves(1, l)
+	render := func(style string, seed uint64) string {
+		var b strings.Builder
+		for _, node := range generateBackground(style, seed, l) {
+			b.WriteString((&SVGDocument{Root: node}).String())
+		}
+		return b.String()
+	}
+
+	t.Run("waves use the layout paths", func(t *testing.T) {
+		svg := render("waves", 1)
+		assert.Contains(t, svg, `<path d="`+l.WavePath+`" fill="url(#waveGradient)"/>`)
+		assert.Contains(t, svg, `<path d="`+l.WaveLine+`" fill="none" stroke="#FFFFFF" stroke-opacity="0.20" stroke-width="6"/>`)
+	})
+
+	t.Run("styles", func(t *testing.T) {
+		assert.Regexp(t, `^(<path d="M[^"]+Z" fill="url\(#waveGradient\)" opacity="[0-9.]+"/>){3,5}$`, strings.ReplaceAll(render("blobs", 1), "\n", ""))
+		assert.Regexp(t, `^<g transform="rotate\(-?[0-9.]+ 800 300\)"><rect `, render("grid", 1))
+		assert.Regexp(t, `^<g fill="#FFFFFF" fill-opacity="0.18"><circle cx="0" cy="0" r="[0-9.]+"/>`, render("dots", 1))
+		assert.Regexp(t, `^<filter id="background-noise" x="0" y="0" width="100%" height="100%"><feTurbulence type="fractalNoise" baseFrequency="0\.[0-9]+" numOctaves="3" seed="[0-9]+" stitchTiles="stitch"/>`, render("noise", 1))
+		assert.Contains(t, render("noise", 1), `<rect width="1600" height="600" filter="url(#background-noise)"/>`)
+		assert.Empty(t, generateBackground("none", 1, l))
+	})
+
+	t.Run("seed picks the shapes", func(t *testing.T) {
+		for _, style := range []string{"blobs", "grid", "dots", "noise"} {
+			assert.Equal(t, render(style, 1), render(style, 1), style)
+			assert.NotEqual(t, render(style, 1), render(style, 2), style)
+		}
+	})
+
+	t.Run("blobs are placed on the canvas", func(t *testing.T) {
+		for seed := uint64(0); seed < 20; seed++ {
+			for _, node := range generateBackground("blobs", seed, l) {
+				d, _ := node.Attr("d")
+				numbers := pathNumbers(d)
+				// Points sit within 1.2 radii of a center on the canvas, and
+				// the control points may reach a little further.
+				for i := 0; i+1 < len(numbers); i += 2 {
+					assert.InDelta(t, l.Width/2, numbers[i], l.Width/2+0.7*l.Height)
+					assert.InDelta(t, l.Height/2, numbers[i+1], l.Height/2+0.7*l.Height)
+				}
+			}
+		}
+	})
+}
+
+func TestSetBackgroundSlot(t *testing.T) {
+	theme, err := getTheme("dark")
+	require.NoError(t, err)
+
+	svg, err := generateSVG(&Metadata{Name: "Kit", Background: "none"}, theme, "left", nil, defaultCanvas)
+	require.NoError(t, err)
+	assert.Contains(t, svg, `<g id="background-pattern"/>`)
+
+	_, err = generateSVG(&Metadata{Name: "Kit", Background: "stripes"}, theme, "left", nil, defaultCanvas)
+	assert.ErrorContains(t, err, "unknown background")
+}
+
+var pathNumberPattern = regexp.MustCompile(`-?[0-9]+(?:\.[0-9]+)?`)
+
+// pathNumbers returns the coordinates of path data d, in order.
+func pathNumbers(d string) []float64 {
+	var numbers []float64
+	for _, s := range pathNumberPattern.FindAllString(d, -1) {
+		v, _ := strconv.ParseFloat(s, 64)
+		numbers = append(numbers, v)
+	}
+	return numbers
+}
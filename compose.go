@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Templates are composed from shared pieces with comment directives, so every
+// file stays a well-formed SVG fragment:
+//
+//	<!-- extends: base -->                 inherit from a base template
+//	<!-- block: card -->...<!-- endblock --> define or override a named block
+//	<!-- include: waves -->                 insert a partial
+//
+// A template that extends another contributes only its blocks; everything
+// outside them is ignored. Blocks cannot be nested.
+var (
+	extendsRe = regexp.MustCompile(`^\s*(?:<\?xml[^>]*\?>\s*)?<!--\s*extends:\s*([\w./-]+)\s*-->`)
+	blockRe   = regexp.MustCompile(`(?s)<!--\s*block:\s*([\w-]+)\s*-->(.*?)<!--\s*endblock\s*-->`)
+	includeRe = regexp.MustCompile(`<!--\s*include:\s*([\w./-]+)\s*-->`)
+)
+
+// maxComposeDepth bounds extends chains and nested includes to catch cycles.
+const maxComposeDepth = 10
+
+// templateResolver finds base templates and partials by name. Built-in names
+// come from templateFS; names ending in .svg are read relative to dir, which
+// is set for user templates only.
+type templateResolver struct {
+	dir string
+}
+
+func (r templateResolver) base(name string) (string, error) {
+	if r.isFile(name) {
+		return r.readFile(name)
+	}
+	if name != "base" && !validAlignment(name) {
+		return "", fmt.Errorf("unknown base template %q", name)
+	}
+	data, err := templateFS.ReadFile(builtinTemplatePath(name))
+	if err != nil {
+		return "", fmt.Errorf("unknown base template %q", name)
+	}
+	return string(data), nil
+}
+
+func (r templateResolver) partial(name string) (string, error) {
+	if r.isFile(name) {
+		return r.readFile(name)
+	}
+	data, err := templateFS.ReadFile(fmt.Sprintf("templates/partials/%s.svg", name))
+	if err != nil {
+		return "", fmt.Errorf("unknown partial %q", name)
+	}
+	if name == "badges" {
+		return repeatBadge(string(data)), nil
+	}
+	return string(data), nil
+}
+
+// repeatBadge expands the single badge of the badges partial into one group
+// per badge slot, badge-1 to badge-3, each placed by its own layout values.
+func repeatBadge(badge string) string {
+	var out strings.Builder
+	for i := range len(Layout{}.BadgeX) {
+		n := strconv.Itoa(i + 1)
+		out.WriteString(strings.NewReplacer(
+			`id="badge"`, `id="badge-`+n+`"`,
+			"{{BADGE_X}}", "{{BADGE"+n+"_X}}",
+			"{{BADGE_TEXT_X}}", "{{BADGE"+n+"_TEXT_X}}",
+		).Replace(badge))
+	}
+	return out.String()
+}
+
+func (r templateResolver) isFile(name string) bool {
+	return r.dir != "" && strings.HasSuffix(name, ".svg")
+}
+
+func (r templateResolver) readFile(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(r.dir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// composeTemplate resolves inheritance, blocks and includes into a single
+// SVG document.
+func composeTemplate(source string, resolver templateResolver) (string, error) {
+	composed, err := resolveExtends(source, resolver, map[string]string{}, 0)
+	if err != nil {
+		return "", err
+	}
+	return expandIncludes(composed, resolver, 0)
+}
+
+// resolveExtends walks up the extends chain. Overrides from the most derived
+// template win over those of its parents.
+func resolveExtends(source string, resolver templateResolver, overrides map[string]string, depth int) (string, error) {
+	if depth > maxComposeDepth {
+		return "", fmt.Errorf("template extends chain is too deep (cycle?)")
+	}
+
+	match := extendsRe.FindStringSubmatch(source)
+	if match == nil {
+		return applyBlocks(source, overrides)
+	}
+
+	merged := make(map[string]string)
+	for _, block := range blockRe.FindAllStringSubmatch(source, -1) {
+		merged[block[1]] = block[2]
+	}
+	for name, content := range overrides {
+		merged[name] = content
+	}
+
+	parent, err := resolver.base(match[1])
+	if err != nil {
+		return "", err
+	}
+	return resolveExtends(parent, resolver, merged, depth+1)
+}
+
+// applyBlocks replaces every block of the base template with its override or
+// its default content.
+func applyBlocks(source string, overrides map[string]string) (string, error) {
+	defined := make(map[string]bool)
+	result := blockRe.ReplaceAllStringFunc(source, func(m string) string {
+		block := blockRe.FindStringSubmatch(m)
+		defined[block[1]] = true
+		if content, ok := overrides[block[1]]; ok {
+			return strings.TrimSpace(content)
+		}
+		return strings.TrimSpace(block[2])
+	})
+
+	for name := range overrides {
+		if !defined[name] {
+			return "", fmt.Errorf("template overrides block %q, which the base template does not define", name)
+		}
+	}
+
+	return result, nil
+}
+
+func expandIncludes(source string, resolver templateResolver, depth int) (string, error) {
+	if !includeRe.MatchString(source) {
+		return source, nil
+	}
+	if depth > maxComposeDepth {
+		return "", fmt.Errorf("template includes are nested too deeply (cycle?)")
+	}
+
+	var err error
+	result := includeRe.ReplaceAllStringFunc(source, func(m string) string {
+		if err != nil {
+			return m
+		}
+		var partial string
+		partial, err = resolver.partial(includeRe.FindStringSubmatch(m)[1])
+		return strings.TrimSpace(partial)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return expandIncludes(result, resolver, depth+1)
+}
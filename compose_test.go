@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComposeTemplate(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"parent.svg": `<svg>
+  <!-- block: head -->
+  <g id="head"/>
+  <!-- endblock -->
+  <!-- block: body -->
+  <!-- include: row.svg -->
+  <!-- endblock -->
+</svg>`,
+		"row.svg":   `<rect class="row"/>`,
+		"loop.svg":  `<!-- include: loop.svg -->`,
+		"child.svg": "<!-- extends: parent.svg -->\n<!-- block: head --><g id=\"child-head\"/><!-- endblock -->",
+		"self.svg":  `<!-- extends: self.svg -->`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644))
+	}
+	resolver := templateResolver{dir: tempDir}
+
+	tests := []struct {
+		name        string
+		source      string
+		expected    string
+		expectError string
+	}{
+		{
+			name:     "plain SVG is unchanged",
+			source:   `<svg><rect/></svg>`,
+			expected: `<svg><rect/></svg>`,
+		},
+		{
+			name:     "include built-in partial",
+			source:   "<svg>\n  <!--include:background-->\n</svg>",
+			expected: "<svg>\n  <!-- Background -->\n  <rect x=\"0\" y=\"0\" width=\"{{WIDTH}}\" height=\"{{HEIGHT}}\" fill=\"url(#bgGradient)\" />\n</svg>",
+		},
+		{
+			name:     "blocks keep their default content",
+			source:   "<svg><!-- block: a --> <g/> <!-- endblock --></svg>",
+			expected: "<svg><g/></svg>",
+		},
+		{
+			name:     "extends overrides a single block",
+			source:   "<!-- extends: parent.svg -->\n<!-- block: body --><circle/><!-- endblock -->\n<ignored/>",
+			expected: "<svg>\n  <g id=\"head\"/>\n  <circle/>\n</svg>",
+		},
+		{
+			name:     "most derived override wins",
+			source:   "<!-- extends: child.svg -->\n<!-- block: body --><circle/><!-- endblock -->",
+			expected: "<svg>\n  <g id=\"child-head\"/>\n  <circle/>\n</svg>",
+		},
+		{
+			name:     "inherited blocks keep includes",
+			source:   "<!-- extends: child.svg -->",
+			expected: "<svg>\n  <g id=\"child-head\"/>\n  <rect class=\"row\"/>\n</svg>",
+		},
+		{
+			name:        "unknown block",
+			source:      "<!-- extends: parent.svg -->\n<!-- block: footer --><g/><!-- endblock -->",
+			expectError: `block "footer"`,
+		},
+		{
+			name:        "unknown partial",
+			source:      "<svg><!-- include: nope --></svg>",
+			expectError: `unknown partial "nope"`,
+		},
+		{
+			name:        "unknown base",
+			source:      "<!-- extends: nope -->",
+			expectError: `unknown base template "nope"`,
+		},
+		{
+			name:        "include cycle",
+			source:      "<svg><!-- include: loop.svg --></svg>",
+			expectError: "cycle",
+		},
+		{
+			name:        "extends cycle",
+			source:      "<!-- extends: self.svg -->",
+			expectError: "cycle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := composeTemplate(tt.source, resolver)
+
+			if tt.expectError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestComposeBuiltinTemplates(t *testing.T) {
+	t.Run("alignments override the base", func(t *testing.T) {
+		for align, anchor := range map[string]string{"center": "{{TEXT_ANCHOR}}", "left": "start", "right": "end"} {
+			template, err := loadTemplate(align)
+			require.NoError(t, err)
+			assert.NotContains(t, template, "extends:")
+			assert.NotContains(t, template, "block:")
+			assert.NotContains(t, template, "include:")
+			assert.Equal(t, 2, strings.Count(template, `<g text-anchor="`+anchor+`">`), align)
+
+			_, err = parseSVG(template)
+			assert.NoError(t, err, "alignment: %s", align)
+		}
+	})
+
+	t.Run("badge is repeated for every slot", func(t *testing.T) {
+		template, err := loadTemplate("center")
+		require.NoError(t, err)
+		for n := 1; n <= 3; n++ {
+			assert.Equal(t, 1, strings.Count(template, fmt.Sprintf(`<g id="badge-%d">`, n)))
+			assert.Contains(t, template, fmt.Sprintf(`x="{{BADGE%d_X}}"`, n))
+			assert.Contains(t, template, fmt.Sprintf(`x="{{BADGE%d_TEXT_X}}"`, n))
+		}
+		assert.NotContains(t, template, `id="badge"`)
+		assert.NotContains(t, template, "{{BADGE_X}}")
+	})
+
+	t.Run("alignment names resolve to their template or the base", func(t *testing.T) {
+		base, err := templateResolver{}.base("base")
+		require.NoError(t, err)
+		center, err := templateResolver{}.base("center")
+		require.NoError(t, err)
+		assert.Equal(t, base, center)
+		for _, align := range []string{"left", "right"} {
+			template, err := templateResolver{}.base(align)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(template, "<!-- extends: base -->"), align)
+		}
+		_, err = templateResolver{}.base("Left")
+		assert.EqualError(t, err, `unknown base template "Left"`)
+
+		_, err = loadTemplate("middle")
+		assert.EqualError(t, err, `failed to load template: unknown alignment "middle". Use: center, left, right`)
+	})
+
+	t.Run("user template extends a built-in base", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "custom.svg")
+		source := `<!-- extends: left -->
+<!-- block: card -->
+  <rect id="custom-card" x="{{CARD_X}}"/>
+<!-- endblock -->`
+		require.NoError(t, os.WriteFile(path, []byte(source), 0644))
+
+		template, err := loadTemplateFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, template, `<rect id="custom-card" x="{{CARD_X}}"/>`)
+		assert.NotContains(t, template, "Card Container")
+		assert.Contains(t, template, `<g id="background-pattern">`)
+		assert.Equal(t, 1, strings.Count(template, `id="title"`))
+		assert.Equal(t, 2, strings.Count(template, `<g text-anchor="start">`))
+	})
+}
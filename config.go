@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is looked up in the project directory when no explicit
+// config path is given.
+const configFileName = ".banner.yml"
+
+// Config is the optional per-project configuration file.
+type Config struct {
+	Vars map[string]string `yaml:"vars"`
+	// Template is a custom template path, relative to the project directory.
+	Template string `yaml:"template"`
+	// Renderer is a comma-separated renderer fallback list, or "auto".
+	Renderer string `yaml:"renderer"`
+	// FontDirs and FontFiles add fonts to every renderer, relative to the
+	// project directory.
+	FontDirs  []string `yaml:"font_dirs"`
+	FontFiles []string `yaml:"font_files"`
+	// SystemFonts prefers the installed fonts over the embedded ones.
+	SystemFonts bool `yaml:"system_fonts"`
+	// ColorEmoji draws emoji with SVG glyphs, from EmojiDir, relative to the
+	// project directory, or the embedded set.
+	ColorEmoji bool   `yaml:"color_emoji"`
+	EmojiDir   string `yaml:"emoji_dir"`
+	// Logo is the project logo, relative to the project directory. It
+	// replaces the README's banner-logo marker.
+	Logo string `yaml:"logo"`
+	// Background is the generated background style, and Seed replaces the
+	// project name as the source of its shapes.
+	Background string `yaml:"background"`
+	Seed       string `yaml:"seed"`
+	// Themes are extra themes, such as a brand gradient, selected by name
+	// like the built-in ones.
+	Themes map[string]ThemePalette `yaml:"themes"`
+}
+
+func parseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	vars, err := normalizeVars(config.Vars)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config vars: %w", err)
+	}
+	config.Vars = vars
+
+	for name, theme := range config.Themes {
+		if _, ok := themes[name]; ok || name == fromLogoTheme {
+			return nil, fmt.Errorf("invalid config theme %q: the name of a built-in theme", name)
+		}
+		if err := validateTheme(&theme); err != nil {
+			return nil, fmt.Errorf("invalid config theme %q: %w", name, err)
+		}
+		config.Themes[name] = theme
+	}
+
+	return &config, nil
+}
+
+// readProjectConfig loads the config at path, or the project's .banner.yml
+// when path is empty. A missing default config is not an error.
+func readProjectConfig(projectDir, path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = filepath.Join(projectDir, configFileName)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	config, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
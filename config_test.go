@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			name: "vars section",
+			content: `vars:
+  version: 1.2.0
+  AUTHOR: Jane Doe
+`,
+			expected: map[string]string{"VERSION": "1.2.0", "AUTHOR": "Jane Doe"},
+		},
+		{
+			name:     "empty config",
+			content:  "",
+			expected: map[string]string{},
+		},
+		{
+			name: "invalid variable name",
+			content: `vars:
+  "my-var": value
+`,
+			expectError: true,
+		},
+		{
+			name:        "malformed yaml",
+			content:     "vars: [unterminated",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := parseConfig([]byte(tt.content))
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Nil(t, config)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, config.Vars)
+			}
+		})
+	}
+}
+
+func TestParseConfigFonts(t *testing.T) {
+	config, err := parseConfig([]byte(`font_dirs:
+  - fonts
+font_files:
+  - assets/Hack-Regular.ttf
+  - /opt/fonts/Inter.ttf
+system_fonts: true
+color_emoji: true
+emoji_dir: twemoji/svg
+logo: assets/logo.svg
+background: dots
+seed: v2
+`))
+	require.NoError(t, err)
+	assert.True(t, config.SystemFonts)
+	assert.True(t, config.ColorEmoji)
+	assert.Equal(t, "twemoji/svg", config.EmojiDir)
+	assert.Equal(t, "assets/logo.svg", config.Logo)
+	assert.Equal(t, "dots", config.Background)
+	assert.Equal(t, "v2", config.Seed)
+	assert.Equal(t, []string{"fonts"}, config.FontDirs)
+	assert.Equal(t, []string{"assets/Hack-Regular.ttf", "/opt/fonts/Inter.ttf"}, config.FontFiles)
+}
+
+func TestParseConfigThemes(t *testing.T) {
+	config, err := parseConfig([]byte(`themes:
+  brand:
+    background:
+      type: radial
+      stops:
+        - "#FF5F6D"
+        - "rgb(255, 195, 113) 40%"
+        - "#2C3E50"
+    wave1: "#FFC371"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, ThemePalette{
+		Background: Gradient{Type: "radial", Stops: []GradientStop{{"#FF5F6D", 0}, {"rgb(255, 195, 113)", 40}, {"#2C3E50", 100}}},
+		WAVE0:      "#FF5F6D",
+		WAVE1:      "#FFC371",
+	}, config.Themes["brand"])
+
+	tests := map[string]string{
+		"themes:\n  dark:\n    background:\n      stops: [\"#000\", \"#FFF\"]\n":                      `invalid config theme "dark": the name of a built-in theme`,
+		"themes:\n  brand:\n    background:\n      stops: [\"#000\"]\n":                               `invalid config theme "brand": a gradient needs at least 2 stops`,
+		"themes:\n  brand:\n    background:\n      stops: [\"#000\", \"tomato 50%\"]\n":               `invalid gradient stop "tomato 50%"`,
+		"themes:\n  brand:\n    background:\n      type: spiral\n      stops: [\"#000\", \"#FFF\"]\n": `unknown gradient type "spiral"`,
+	}
+	for input, want := range tests {
+		_, err := parseConfig([]byte(input))
+		assert.ErrorContains(t, err, want, input)
+	}
+}
+
+func TestReadProjectConfig(t *testing.T) {
+	tempDir := t.TempDir()
+
+	t.Run("missing default config is not an error", func(t *testing.T) {
+		config, err := readProjectConfig(tempDir, "")
+		require.NoError(t, err)
+		assert.Empty(t, config.Vars)
+	})
+
+	t.Run("default config in project directory", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "with-config")
+		require.NoError(t, os.MkdirAll(projectDir, 0755))
+		err := os.WriteFile(filepath.Join(projectDir, configFileName), []byte("vars:\n  url: https://example.com\n"), 0644)
+		require.NoError(t, err)
+
+		config, err := readProjectConfig(projectDir, "")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", config.Vars["URL"])
+	})
+
+	t.Run("missing explicit config is an error", func(t *testing.T) {
+		config, err := readProjectConfig(tempDir, filepath.Join(tempDir, "nope.yml"))
+		assert.Error(t, err)
+		assert.Nil(t, config)
+		assert.Contains(t, err.Error(), "failed to read config")
+	})
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// twemojiZip holds the embedded color emoji glyphs, the complete Twemoji
+// set. See emoji/README.md.
+//
+//go:embed emoji/twemoji.zip
+var twemojiZip []byte
+
+// embeddedEmoji opens the embedded glyph archive once.
+var embeddedEmoji = sync.OnceValues(func() (fs.FS, error) {
+	r, err := zip.NewReader(bytes.NewReader(twemojiZip), int64(len(twemojiZip)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded emoji: %w", err)
+	}
+	return r, nil
+})
+
+// Color emoji are drawn in a square of one em, on an advance slightly wider
+// so they do not touch neighbouring text. emojiAscent is the part of the
+// square above the baseline, as in common emoji fonts.
+const (
+	emojiAdvance = 1.15
+	emojiAscent  = 0.85
+)
+
+// emojiGlyphSet returns the color emoji glyphs in dir, named like Twemoji
+// (1f680.svg, 1f44d-1f3fd.svg) or Noto Emoji (emoji_u1f680.svg), or the
+// embedded glyphs when dir is empty.
+func emojiGlyphSet(dir string) (fs.FS, error) {
+	if dir == "" {
+		return embeddedEmoji()
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid emoji directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("invalid emoji directory: %s is not a directory", dir)
+	}
+	return os.DirFS(dir), nil
+}
+
+// textRun is a piece of slot text. Emoji runs hold one emoji sequence;
+// Attrs style the <tspan> of a text run.
+type textRun struct {
+	Text  string
+	Emoji bool
+	Attrs []SVGAttr
+}
+
+// splitEmoji splits text into plain runs and emoji sequences: a base
+// character with its variation selector, skin tone, keycap and tag
+// modifiers, regional indicator pairs (flags), and sequences of those joined
+// with zero width joiners. Non-ASCII characters are emoji candidates; the
+// glyph set decides which ones are drawn in color.
+func splitEmoji(text string) []textRun {
+	var runs []textRun
+	var plain strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		start := i
+		i++
+		if runes[start] < 0x80 {
+			// Only keycaps such as 1️⃣ start with ASCII.
+			for i < len(runes) && (runes[i] == 0xFE0F || runes[i] == 0x20E3) {
+				i++
+			}
+			if i-start == 1 {
+				plain.WriteRune(runes[start])
+				continue
+			}
+		} else {
+			if regionalIndicator(runes[start]) && i < len(runes) && regionalIndicator(runes[i]) {
+				i++
+			}
+		extend:
+			for i < len(runes) {
+				switch r := runes[i]; {
+				case emojiModifier(r):
+					i++
+				case r == 0x200D && i+1 < len(runes):
+					i += 2
+				default:
+					break extend
+				}
+			}
+		}
+		if plain.Len() > 0 {
+			runs = append(runs, textRun{Text: plain.String()})
+			plain.Reset()
+		}
+		runs = append(runs, textRun{Text: string(runes[start:i]), Emoji: true})
+	}
+	if plain.Len() > 0 {
+		runs = append(runs, textRun{Text: plain.String()})
+	}
+	return runs
+}
+
+func regionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// emojiModifier reports whether r modifies the preceding character: the
+// emoji variation selector, the keycap mark, skin tones and tag characters.
+func emojiModifier(r rune) bool {
+	return r == 0xFE0F || r == 0x20E3 || (r >= 0x1F3FB && r <= 0x1F3FF) || (r >= 0xE0020 && r <= 0xE007F)
+}
+
+// emojiLike reports whether a sequence is meant as an emoji, so a missing
+// glyph is worth a warning. Other non-ASCII characters, such as accented
+// letters, are left to the fonts silently.
+func emojiLike(seq string) bool {
+	for _, r := range seq {
+		switch {
+		case emojiModifier(r), r == 0x200D, regionalIndicator(r),
+			r >= 0x1F000 && r <= 0x1FAFF, r >= 0x2600 && r <= 0x27BF, r >= 0x2B00 && r <= 0x2BFF:
+			return true
+		}
+	}
+	return false
+}
+
+// emojiKey is the Twemoji name of a sequence: its code points in lowercase
+// hex, joined with dashes, without the emoji variation selector.
+func emojiKey(seq string, keepFE0F bool) string {
+	var parts []string
+	for _, r := range seq {
+		if r != 0xFE0F || keepFE0F {
+			parts = append(parts, strconv.FormatInt(int64(r), 16))
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// emojiFileNames returns the file names a glyph set may use for seq.
+func emojiFileNames(seq string) []string {
+	names := []string{emojiKey(seq, true) + ".svg"}
+	if key := emojiKey(seq, false); key+".svg" != names[0] {
+		names = append(names, key+".svg")
+	}
+	var noto []string
+	for _, r := range seq {
+		if r != 0xFE0F {
+			noto = append(noto, fmt.Sprintf("%04x", r))
+		}
+	}
+	return append(names, "emoji_u"+strings.Join(noto, "_")+".svg")
+}
+
+// loadEmojiGlyph reads the glyph of seq from glyphs as a <symbol> with the
+// given id. It returns nil when the set has no glyph for seq.
+func loadEmojiGlyph(glyphs fs.FS, seq, id string) (*SVGNode, error) {
+	for _, name := range emojiFileNames(seq) {
+		data, err := fs.ReadFile(glyphs, name)
+		if err != nil {
+			continue
+		}
+		doc, err := parseSVG(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid emoji glyph %s: %w", name, err)
+		}
+		viewBox, ok := svgViewBox(doc.Root)
+		if !ok {
+			return nil, fmt.Errorf("invalid emoji glyph %s: no viewBox or size", name)
+		}
+
+		symbol := &SVGNode{Kind: ElementNode, Name: "symbol", Attrs: []SVGAttr{{Name: "id", Value: id}, {Name: "viewBox", Value: viewBox}}}
+		for _, child := range doc.Root.Children {
+			if child.Kind == ElementNode {
+				symbol.AppendChild(child.Clone())
+			}
+		}
+		prefixIDs(symbol, id+"-")
+		return symbol, nil
+	}
+	return nil, nil
+}
+
+// svgViewBox returns the viewBox of an <svg> root, or one made from its
+// width and height in pixels.
+func svgViewBox(root *SVGNode) (string, bool) {
+	if viewBox, ok := root.Attr("viewBox"); ok {
+		return viewBox, true
+	}
+	width, _ := root.Attr("width")
+	height, _ := root.Attr("height")
+	w, errW := strconv.ParseFloat(strings.TrimSuffix(width, "px"), 64)
+	h, errH := strconv.ParseFloat(strings.TrimSuffix(height, "px"), 64)
+	if errW != nil || errH != nil {
+		return "", false
+	}
+	return fmt.Sprintf("0 0 %s %s", formatNumber(w), formatNumber(h)), true
+}
+
+var idURLPattern = regexp.MustCompile(`url\(\s*['"]?#([^'")\s]+)['"]?\s*\)`)
+
+// prefixIDs renames the ids and classes below n and the references to them,
+// including those in <style> sheets, so glyphs that define gradients under
+// the same ids do not clash and their class rules do not style the rest of
+// the banner. xlink:href becomes href, since the banner does not declare the
+// xlink namespace.
+func prefixIDs(n *SVGNode, prefix string) {
+	ids := map[string]bool{}
+	for _, child := range n.Children {
+		child.Walk(func(node *SVGNode) bool {
+			if id, ok := node.Attr("id"); ok {
+				ids[id] = true
+				node.SetAttr("id", prefix+id)
+			}
+			return true
+		})
+	}
+	n.Walk(func(node *SVGNode) bool {
+		for i, attr := range node.Attrs {
+			switch {
+			case attr.Name == "xlink:href" || attr.Name == "href":
+				node.Attrs[i].Name = "href"
+				if ids[strings.TrimPrefix(attr.Value, "#")] && strings.HasPrefix(attr.Value, "#") {
+					node.Attrs[i].Value = "#" + prefix + attr.Value[1:]
+				}
+			case strings.HasPrefix(attr.Name, "xmlns"):
+				continue
+			case attr.Name == "class":
+				classes := strings.Fields(attr.Value)
+				for j, class := range classes {
+					classes[j] = prefix + class
+				}
+				node.Attrs[i].Value = strings.Join(classes, " ")
+			default:
+				node.Attrs[i].Value = prefixURLs(attr.Value, prefix, ids)
+			}
+		}
+		if node.Name == "style" {
+			for _, child := range node.Children {
+				if child.Kind == TextNode {
+					child.Text = prefixStyleSheet(child.Text, prefix, ids)
+				}
+			}
+		}
+		attrs := node.Attrs[:0]
+		for _, attr := range node.Attrs {
+			if !strings.HasPrefix(attr.Name, "xmlns") {
+				attrs = append(attrs, attr)
+			}
+		}
+		node.Attrs = attrs
+		return true
+	})
+}
+
+// prefixURLs renames the url(#id) references to ids in value.
+func prefixURLs(value, prefix string, ids map[string]bool) string {
+	return idURLPattern.ReplaceAllStringFunc(value, func(m string) string {
+		id := idURLPattern.FindStringSubmatch(m)[1]
+		if !ids[id] {
+			return m
+		}
+		return "url(#" + prefix + id + ")"
+	})
+}
+
+var selectorNamePattern = regexp.MustCompile(`([.#])(-?[_a-zA-Z][\w-]*)`)
+
+// prefixStyleSheet renames the id and class selectors of a CSS style sheet,
+// and the url(#id) references to ids in its declarations. Selectors are
+// renamed even when nothing below the prefixed element matches them, so a
+// glyph's rules never apply outside it.
+func prefixStyleSheet(css, prefix string, ids map[string]bool) string {
+	var out strings.Builder
+	// blocks holds, for every open brace, whether it holds declarations
+	// rather than rules, as at-rules such as @media do.
+	var blocks []bool
+	inDeclarations := func() bool { return len(blocks) > 0 && blocks[len(blocks)-1] }
+	for css != "" {
+		end := strings.IndexAny(css, "{}")
+		if end < 0 {
+			end = len(css)
+		}
+		part := css[:end]
+		switch {
+		case inDeclarations():
+			out.WriteString(prefixURLs(part, prefix, ids))
+		case end < len(css) && css[end] == '{' && !strings.HasPrefix(strings.TrimSpace(part), "@"):
+			out.WriteString(selectorNamePattern.ReplaceAllString(part, "${1}"+prefix+"${2}"))
+		default:
+			out.WriteString(part)
+		}
+		if end == len(css) {
+			break
+		}
+		if css[end] == '{' {
+			at := strings.TrimSpace(part)
+			blocks = append(blocks, !strings.HasPrefix(at, "@") || strings.HasPrefix(at, "@font-face") || strings.HasPrefix(at, "@page"))
+		} else if len(blocks) > 0 {
+			blocks = blocks[:len(blocks)-1]
+		}
+		out.WriteByte(css[end])
+		css = css[end+1:]
+	}
+	return out.String()
+}
+
+// replaceColorEmoji draws the emoji of the text slots with glyphs from the
+// glyph set instead of a font. Each glyph is added once to <defs> and
+// placed with <use> next to the slot's <text>, whose remaining text is split
+// into <tspan>s positioned with the embedded font metrics, so every renderer
+// shows the same emoji. It returns a warning for every emoji that stays
+// text.
+func replaceColorEmoji(svg string, glyphs fs.FS) (string, []string, error) {
+	doc, err := parseSVG(svg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var warnings []string
+	symbols := map[string]bool{}
+	var defs *SVGNode
+	changed := false
+	for _, slot := range glyphSlots {
+		text := slotTextElement(doc, slot)
+		if text == nil {
+			continue
+		}
+		var runs []textRun
+		for _, run := range slotRuns(text) {
+			for _, split := range splitEmoji(run.Text) {
+				if !split.Emoji {
+					split.Attrs = run.Attrs
+				}
+				runs = append(runs, split)
+			}
+		}
+
+		drawn := false
+		for i, run := range runs {
+			if !run.Emoji {
+				continue
+			}
+			id := "emoji-" + emojiKey(run.Text, false)
+			if !symbols[id] {
+				symbol, err := loadEmojiGlyph(glyphs, run.Text, id)
+				if err != nil {
+					return "", nil, err
+				}
+				if symbol == nil {
+					if emojiLike(run.Text) {
+						warnings = append(warnings, fmt.Sprintf("%s: no color glyph for %s, drawing it with the fonts", slot, codePoints(run.Text)))
+					}
+					runs[i].Emoji = false
+					continue
+				}
+				if defs == nil {
+					defs = emojiDefs(doc)
+				}
+				defs.AppendChild(symbol)
+				symbols[id] = true
+			}
+			drawn = true
+		}
+		if !drawn {
+			continue
+		}
+
+		if err := layoutTextRuns(text, runs); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v, drawing emoji with the fonts", slot, err))
+			continue
+		}
+		changed = true
+	}
+
+	if !changed {
+		return svg, warnings, nil
+	}
+	return doc.String(), warnings, nil
+}
+
+// emojiDefs returns the root's <defs>, adding one when there is none.
+func emojiDefs(doc *SVGDocument) *SVGNode {
+	for _, child := range doc.Root.Children {
+		if child.Kind == ElementNode && child.Name == "defs" {
+			return child
+		}
+	}
+	defs := &SVGNode{Kind: ElementNode, Name: "defs", Parent: doc.Root}
+	doc.Root.Children = append([]*SVGNode{defs}, doc.Root.Children...)
+	return defs
+}
+
+// textPosition returns the x or y of a text element, or of the <text> that
+// contains a <tspan> without its own.
+func textPosition(n *SVGNode, name string) (float64, error) {
+	for ; n != nil && (n.Name == "text" || n.Name == "tspan"); n = n.Parent {
+		value, ok := n.Attr(name)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, "px"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s %q is not a single number", name, value)
+		}
+		return v, nil
+	}
+	return 0, fmt.Errorf("text has no %s", name)
+}
+
+// codePoints formats seq as "U+1F9D1 U+200D U+1F4BB".
+func codePoints(seq string) string {
+	var parts []string
+	for _, r := range seq {
+		parts = append(parts, fmt.Sprintf("U+%04X", r))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitEmoji(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []textRun
+	}{
+		{"plain text", "Banner Kit", []textRun{{Text: "Banner Kit"}}},
+		{"single emoji", "\U0001F680 Launch", []textRun{{Text: "\U0001F680", Emoji: true}, {Text: " Launch"}}},
+		{"variation selector", "I ❤\ufe0f Go", []textRun{{Text: "I "}, {Text: "❤\ufe0f", Emoji: true}, {Text: " Go"}}},
+		{"skin tone", "\U0001F44D\U0001F3FD!", []textRun{{Text: "\U0001F44D\U0001F3FD", Emoji: true}, {Text: "!"}}},
+		{"zwj sequence", "\U0001F9D1\u200d\U0001F4BB", []textRun{{Text: "\U0001F9D1\u200d\U0001F4BB", Emoji: true}}},
+		{"flag", "\U0001F1E9\U0001F1EA\U0001F1EB\U0001F1F7", []textRun{{Text: "\U0001F1E9\U0001F1EA", Emoji: true}, {Text: "\U0001F1EB\U0001F1F7", Emoji: true}}},
+		{"keycap", "v1\ufe0f\u20e3", []textRun{{Text: "v"}, {Text: "1\ufe0f\u20e3", Emoji: true}}},
+		{"adjacent emoji", "⚡✨", []textRun{{Text: "⚡", Emoji: true}, {Text: "✨", Emoji: true}}},
+		{"trailing joiner", "\U0001F9D1\u200d", []textRun{{Text: "\U0001F9D1", Emoji: true}, {Text: "\u200d", Emoji: true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitEmoji(tt.text))
+		})
+	}
+}
+
+func TestEmojiFileNames(t *testing.T) {
+	assert.Equal(t, []string{"1f680.svg", "emoji_u1f680.svg"}, emojiFileNames("\U0001F680"))
+	assert.Equal(t, []string{"2764-fe0f.svg", "2764.svg", "emoji_u2764.svg"}, emojiFileNames("❤\ufe0f"))
+	assert.Equal(t, []string{"31-fe0f-20e3.svg", "31-20e3.svg", "emoji_u0031_20e3.svg"}, emojiFileNames("1\ufe0f\u20e3"))
+	assert.Equal(t, []string{"1f9d1-200d-1f4bb.svg", "emoji_u1f9d1_200d_1f4bb.svg"}, emojiFileNames("\U0001F9D1\u200d\U0001F4BB"))
+}
+
+func TestEmbeddedEmojiGlyphs(t *testing.T) {
+	glyphs, err := emojiGlyphSet("")
+	require.NoError(t, err)
+	names, err := fs.Glob(glyphs, "*.svg")
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+
+	// Every glyph must be found under its own name and load as a symbol.
+	for _, name := range names {
+		var seq strings.Builder
+		for _, part := range strings.Split(strings.TrimSuffix(name, ".svg"), "-") {
+			var r rune
+			for _, c := range part {
+				r = r*16 + rune(strings.IndexRune("0123456789abcdef", c))
+			}
+			seq.WriteRune(r)
+		}
+		symbol, err := loadEmojiGlyph(glyphs, seq.String(), "emoji-test")
+		require.NoError(t, err, name)
+		require.NotNil(t, symbol, name)
+		viewBox, _ := symbol.Attr("viewBox")
+		assert.Equal(t, "0 0 36 36", viewBox, name)
+	}
+
+	// The set is complete: README title emoji, flags, keycaps, skin tones
+	// and ZWJ sequences are all covered.
+	assert.Len(t, names, 3689)
+	for _, emoji := range []string{
+		"\U0001F680", "\U0001F4E6", "\U0001F527", "\U0001F3A8", "⚡",
+		"\U0001F1E9\U0001F1EA", "\U0001F3F4\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", "#\uFE0F\u20E3",
+		"\U0001F44B\U0001F3FD", "\U0001F9D1\U0001F3FF\u200D\U0001F4BB",
+		"\U0001F468\u200D\U0001F469\u200D\U0001F467", "\U0001F3F3\uFE0F\u200D\U0001F308",
+	} {
+		symbol, err := loadEmojiGlyph(glyphs, emoji, "emoji-test")
+		require.NoError(t, err)
+		assert.NotNil(t, symbol, codePoints(emoji))
+	}
+
+	// So is every emoji shortcode.
+	table, err := shortcodeTable()
+	require.NoError(t, err)
+	for name, text := range table {
+		if strings.HasPrefix(name, "nf-") {
+			continue
+		}
+		symbol, err := loadEmojiGlyph(glyphs, text, "emoji-test")
+		require.NoError(t, err, name)
+		assert.NotNil(t, symbol, name)
+	}
+}
+
+func TestLoadEmojiGlyph(t *testing.T) {
+	glyphs := fstest.MapFS{
+		"emoji_u1f680.svg": {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="128" height="128">` +
+			`<defs><linearGradient id="a"><stop offset="0" stop-color="red"/></linearGradient><path id="p" d="M0 0h8v8z"/></defs>` +
+			`<circle r="4" fill="url(#a)"/><use xlink:href="#p"/><rect style="fill:url(#a)" fill="url(#other)"/></svg>`)},
+		"26a1.svg": {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"><path d="M0 0"/></svg>`)},
+	}
+
+	t.Run("noto names, sizes and ids", func(t *testing.T) {
+		symbol, err := loadEmojiGlyph(glyphs, "\U0001F680", "emoji-1f680")
+		require.NoError(t, err)
+		require.NotNil(t, symbol)
+		assert.Equal(t, `<symbol id="emoji-1f680" viewBox="0 0 128 128">`+
+			`<defs><linearGradient id="emoji-1f680-a"><stop offset="0" stop-color="red"/></linearGradient><path id="emoji-1f680-p" d="M0 0h8v8z"/></defs>`+
+			`<circle r="4" fill="url(#emoji-1f680-a)"/><use href="#emoji-1f680-p"/><rect style="fill:url(#emoji-1f680-a)" fill="url(#other)"/></symbol>`,
+			strings.TrimSpace((&SVGDocument{Root: symbol}).String()))
+	})
+
+	t.Run("missing glyph", func(t *testing.T) {
+		symbol, err := loadEmojiGlyph(glyphs, "\U0001F984", "emoji-1f984")
+		require.NoError(t, err)
+		assert.Nil(t, symbol)
+	})
+
+	t.Run("glyph without size", func(t *testing.T) {
+		_, err := loadEmojiGlyph(glyphs, "⚡", "emoji-26a1")
+		assert.ErrorContains(t, err, "invalid emoji glyph 26a1.svg: no viewBox or size")
+	})
+}
+
+func TestEmojiGlyphSet(t *testing.T) {
+	dir := t.TempDir()
+	glyphs, err := emojiGlyphSet(dir)
+	require.NoError(t, err)
+	_, err = fs.Stat(glyphs, ".")
+	assert.NoError(t, err)
+
+	_, err = emojiGlyphSet(path.Join(dir, "missing"))
+	assert.ErrorContains(t, err, "invalid emoji directory")
+}
+
+func TestReplaceColorEmoji(t *testing.T) {
+	glyphs := fstest.MapFS{
+		"1f680.svg": {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 36 36"><circle cx="18" cy="18" r="18" fill="#F00"/></svg>`)},
+		"2764.svg":  {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 36 36"><path d="M0 0h36v36z" fill="#D00"/></svg>`)},
+	}
+	banner := func(title, anchor string) string {
+		return `<svg xmlns="http://www.w3.org/2000/svg" width="400" height="100">` +
+			`<g fill="#FFF"><text id="title" x="200" y="60" text-anchor="` + anchor + `" font-size="20" font-weight="700">` + title + `</text></g>` +
+			`<text id="tagline" x="10" y="90" font-size="10">Plain</text></svg>`
+	}
+	measure := func(t *testing.T, text string) float64 {
+		t.Helper()
+		width, err := measureText(text, 20, true)
+		require.NoError(t, err)
+		return width
+	}
+
+	t.Run("anchored text is split around glyphs", func(t *testing.T) {
+		out, warnings, err := replaceColorEmoji(banner("\U0001F680 Go ❤\ufe0f", "middle"), glyphs)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+
+		emoji := emojiAdvance * 20
+		start := 200 - (2*emoji+measure(t, " Go "))/2
+		doc, err := parseSVG(out)
+		require.NoError(t, err)
+		title := doc.Root.FindByID("title")
+		anchor, _ := title.Attr("text-anchor")
+		assert.Equal(t, "middle", anchor, "kept for a later layout")
+		assert.Equal(t, " Go ", title.TextContent())
+		tspans := title.FindByName("tspan")
+		require.Len(t, tspans, 1)
+		assert.Equal(t, []SVGAttr{{Name: "x", Value: formatNumber(start + emoji)}, {Name: "text-anchor", Value: "start"}, {Name: "font-family", Value: "'DejaVu Sans'"}}, tspans[0].Attrs)
+
+		uses := doc.Root.FindByName("use")
+		require.Len(t, uses, 2)
+		assert.Equal(t, "g", uses[0].Parent.Name)
+		assert.Equal(t, []SVGAttr{
+			{Name: "href", Value: "#emoji-1f680"},
+			{Name: "x", Value: formatNumber(start + (emojiAdvance-1)/2*20)},
+			{Name: "y", Value: formatNumber(60 - emojiAscent*20)},
+			{Name: "width", Value: "20"},
+			{Name: "height", Value: "20"},
+		}, uses[0].Attrs)
+		href, _ := uses[1].Attr("href")
+		assert.Equal(t, "#emoji-2764", href)
+
+		symbols := doc.Root.FindByName("symbol")
+		require.Len(t, symbols, 2)
+		assert.Equal(t, "defs", symbols[0].Parent.Name)
+		assert.Equal(t, doc.Root, symbols[0].Parent.Parent)
+	})
+
+	t.Run("end anchor and repeated emoji", func(t *testing.T) {
+		out, _, err := replaceColorEmoji(banner("Go\U0001F680\U0001F680", "end"), glyphs)
+		require.NoError(t, err)
+		doc, err := parseSVG(out)
+		require.NoError(t, err)
+		assert.Len(t, doc.Root.FindByName("symbol"), 1)
+		uses := doc.Root.FindByName("use")
+		require.Len(t, uses, 2)
+		x, _ := uses[1].Attr("x")
+		assert.Equal(t, formatNumber(200-emojiAdvance*20+(emojiAdvance-1)/2*20), x)
+		tspan, _ := doc.Root.FindByName("tspan")[0].Attr("x")
+		assert.Equal(t, formatNumber(200-2*emojiAdvance*20-measure(t, "Go")), tspan)
+	})
+
+	t.Run("emoji without glyph stay text", func(t *testing.T) {
+		svg := banner("Café \U0001F984", "start")
+		out, warnings, err := replaceColorEmoji(svg, glyphs)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"title: no color glyph for U+1F984, drawing it with the fonts"}, warnings)
+		assert.Equal(t, svg, out)
+	})
+
+	t.Run("glyphs are drawn next to text the embedded font lacks", func(t *testing.T) {
+		embedded, err := emojiGlyphSet("")
+		require.NoError(t, err)
+		for _, tt := range []struct{ title, other, warning string }{
+			{"Banner Kit 🚀 \U0001FAE8", "\U0001FAE8", "title: no color glyph for U+1FAE8, drawing it with the fonts"},
+			{"Banner Kit 🚀 日本", "日本", ""},
+		} {
+			out, warnings, err := replaceColorEmoji(banner(tt.title, "start"), embedded)
+			require.NoError(t, err)
+			if tt.warning == "" {
+				assert.Empty(t, warnings, tt.title)
+			} else {
+				assert.Equal(t, []string{tt.warning}, warnings, tt.title)
+			}
+
+			doc, err := parseSVG(out)
+			require.NoError(t, err)
+			uses := doc.Root.FindByName("use")
+			require.Len(t, uses, 1, tt.title)
+			href, _ := uses[0].Attr("href")
+			assert.Equal(t, "#emoji-1f680", href)
+
+			// The measured text is pinned to the embedded font; the rest is
+			// left to the template's fonts.
+			var other strings.Builder
+			for _, tspan := range doc.Root.FindByID("title").FindByName("tspan") {
+				family, pinned := tspan.Attr("font-family")
+				if tspan.TextContent() == "Banner Kit " {
+					assert.Equal(t, "'DejaVu Sans'", family)
+					continue
+				}
+				assert.False(t, pinned, "%s: %q", tt.title, tspan.TextContent())
+				other.WriteString(tspan.TextContent())
+			}
+			assert.Equal(t, tt.other, strings.TrimSpace(other.String()))
+		}
+	})
+
+	t.Run("unplaceable text is left alone", func(t *testing.T) {
+		svg := strings.Replace(banner("\U0001F680 Go", "start"), `x="200"`, `x="10 20"`, 1)
+		out, warnings, err := replaceColorEmoji(svg, glyphs)
+		require.NoError(t, err)
+		assert.Equal(t, []string{`title: x "10 20" is not a single number, drawing emoji with the fonts`}, warnings)
+		assert.Equal(t, svg, out)
+	})
+
+	t.Run("text without emoji is unchanged", func(t *testing.T) {
+		svg := banner("Banner Kit", "middle")
+		out, warnings, err := replaceColorEmoji(svg, glyphs)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		assert.Equal(t, svg, out)
+	})
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// embeddedFontFS holds DejaVu Sans, the default font, and DejaVu Sans Mono
+// for code. See fonts/README.md.
+//
+//go:embed fonts/*.ttf.gz
+var embeddedFontFS embed.FS
+
+// embeddedFontFamily is the family name of the default embedded fonts. It is
+// the fallback of every font-family list rendered by resvg-wasm.
+const embeddedFontFamily = "DejaVu Sans"
+
+// embeddedMonoFamily is the family of the embedded monospace fonts.
+const embeddedMonoFamily = "DejaVu Sans Mono"
+
+// embeddedFont is a decompressed font file from embeddedFontFS.
+type embeddedFont struct {
+	// name is the file name without the .gz suffix.
+	name string
+	data []byte
+}
+
+// embeddedFonts decompresses the embedded fonts once per process.
+var embeddedFonts = sync.OnceValues(func() ([]embeddedFont, error) {
+	paths, err := fs.Glob(embeddedFontFS, "fonts/*.ttf.gz")
+	if err != nil {
+		return nil, err
+	}
+	fonts := make([]embeddedFont, 0, len(paths))
+	for _, p := range paths {
+		gz, err := embeddedFontFS.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded font: %w", err)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(gz))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress embedded font %s: %w", p, err)
+		}
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress embedded font %s: %w", p, err)
+		}
+		fonts = append(fonts, embeddedFont{name: strings.TrimSuffix(path.Base(p), ".gz"), data: data})
+	}
+	return fonts, nil
+})
+
+// Fonts selects the fonts of every renderer. By default renderers use the
+// embedded fonts, so a banner renders the same on every machine; extra
+// fonts are loaded in addition, e.g. the template fonts in a CI container.
+type Fonts struct {
+	// Dirs are scanned recursively for font files.
+	Dirs []string
+	// Files are single font files (TTF, OTF, TTC).
+	Files []string
+	// System prefers the installed fonts. The embedded fonts remain the
+	// last fallback.
+	System bool
+}
+
+func (f Fonts) empty() bool {
+	return len(f.Dirs) == 0 && len(f.Files) == 0
+}
+
+// key identifies the font set, e.g. to match pooled renderers.
+func (f Fonts) key() string {
+	return fmt.Sprintf("%s\x01%s\x01%t", strings.Join(f.Dirs, "\x00"), strings.Join(f.Files, "\x00"), f.System)
+}
+
+// resolveFonts merges the config fonts, relative to the project directory,
+// with the fonts from flags, relative to the working directory. Every path
+// must exist and is returned absolute, since the renderers see it from
+// elsewhere.
+func resolveFonts(projectDir string, config *Config, dirs, files []string) (Fonts, error) {
+	var fonts Fonts
+	add := func(list *[]string, base, path string, wantDir bool) error {
+		if base != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() != wantDir {
+			if wantDir {
+				return fmt.Errorf("%s is not a directory", path)
+			}
+			return fmt.Errorf("%s is a directory, use a font directory instead", path)
+		}
+		for _, existing := range *list {
+			if existing == abs {
+				return nil
+			}
+		}
+		*list = append(*list, abs)
+		return nil
+	}
+
+	for _, dir := range config.FontDirs {
+		if err := add(&fonts.Dirs, projectDir, dir, true); err != nil {
+			return Fonts{}, fmt.Errorf("invalid font directory: %w", err)
+		}
+	}
+	for _, dir := range dirs {
+		if err := add(&fonts.Dirs, "", dir, true); err != nil {
+			return Fonts{}, fmt.Errorf("invalid font directory: %w", err)
+		}
+	}
+	for _, file := range config.FontFiles {
+		if err := add(&fonts.Files, projectDir, file, false); err != nil {
+			return Fonts{}, fmt.Errorf("invalid font file: %w", err)
+		}
+	}
+	for _, file := range files {
+		if err := add(&fonts.Files, "", file, false); err != nil {
+			return Fonts{}, fmt.Errorf("invalid font file: %w", err)
+		}
+	}
+	return fonts, nil
+}
+
+// fontconfigRoot is the directory holding the fonts and fontconfig files
+// written for rsvg-convert.
+func fontconfigRoot() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "banner-kit-go", "fontconfig"), nil
+}
+
+// rsvgFontconfig returns the fontconfig file rsvg-convert runs with. The
+// files are written once to the user cache; without one they go to a
+// temporary directory that cleanup removes.
+func rsvgFontconfig(fonts Fonts) (path string, cleanup func(), err error) {
+	cleanup = func() {}
+	root, err := fontconfigRoot()
+	if err == nil {
+		err = os.MkdirAll(root, 0755)
+	}
+	if err != nil {
+		root, err = os.MkdirTemp("", "banner-fonts-")
+		if err != nil {
+			return "", cleanup, fmt.Errorf("failed to configure fonts: %w", err)
+		}
+		cleanup = func() { os.RemoveAll(root) }
+	}
+	path, err = writeFontconfig(root, fonts)
+	if err != nil {
+		cleanup()
+		return "", func() {}, fmt.Errorf("failed to configure fonts: %w", err)
+	}
+	return path, cleanup, nil
+}
+
+// writeFontconfig writes a fontconfig file below root with the fonts
+// checkGlyphs expects: the extra fonts, the system configuration only when
+// it is preferred, so installed fonts cannot change the rendering by
+// default, and the embedded fonts as the last fallback. Every
+// directory is named by a hash of its content and written once, so
+// fontconfig's cache, kept in root too, stays valid between runs.
+// Fontconfig only scans directories, so font files are linked into one.
+func writeFontconfig(root string, fonts Fonts) (string, error) {
+	var conf strings.Builder
+	conf.WriteString("<?xml version=\"1.0\"?>\n<!DOCTYPE fontconfig SYSTEM \"fonts.dtd\">\n<fontconfig>\n")
+	if fonts.System {
+		system, err := systemFontconfig()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&conf, "  <include>%s</include>\n", html.EscapeString(system))
+	}
+	for _, fontDir := range fonts.Dirs {
+		fmt.Fprintf(&conf, "  <dir>%s</dir>\n", html.EscapeString(fontDir))
+	}
+	if len(fonts.Files) > 0 {
+		filesDir, err := linkFontFiles(root, fonts.Files)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&conf, "  <dir>%s</dir>\n", html.EscapeString(filesDir))
+	}
+	embeddedDir, err := writeEmbeddedFonts(root)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&conf, "  <dir>%s</dir>\n", html.EscapeString(embeddedDir))
+	fmt.Fprintf(&conf, "  <cachedir>%s</cachedir>\n", html.EscapeString(filepath.Join(root, "cache")))
+	conf.WriteString("</fontconfig>\n")
+
+	dir, err := writeOnce(root, "conf-"+contentHash([]byte(conf.String())), func(dir string) error {
+		return os.WriteFile(filepath.Join(dir, "fonts.conf"), []byte(conf.String()), 0644)
+	})
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "fonts.conf"), nil
+}
+
+// systemFontconfigPaths are the usual places of the system configuration,
+// after the one next to rsvg-convert's installation prefix.
+var systemFontconfigPaths = []string{"/etc/fonts/fonts.conf", "/usr/local/etc/fonts/fonts.conf", "/opt/homebrew/etc/fonts/fonts.conf", "/opt/local/etc/fonts/fonts.conf"}
+
+// systemFontconfig finds the configuration fontconfig would load: the one
+// named by FONTCONFIG_FILE or FONTCONFIG_PATH, the one of rsvg-convert's
+// installation prefix, as with Homebrew, or one of systemFontconfigPaths.
+func systemFontconfig() (string, error) {
+	var candidates []string
+	if file := os.Getenv("FONTCONFIG_FILE"); file != "" {
+		candidates = append(candidates, file)
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("FONTCONFIG_PATH")) {
+		if dir != "" {
+			candidates = append(candidates, filepath.Join(dir, "fonts.conf"))
+		}
+	}
+	if bin, err := exec.LookPath("rsvg-convert"); err == nil {
+		if resolved, err := filepath.EvalSymlinks(bin); err == nil {
+			bin = resolved
+		}
+		candidates = append(candidates, filepath.Join(filepath.Dir(filepath.Dir(bin)), "etc", "fonts", "fonts.conf"))
+	}
+	candidates = append(candidates, systemFontconfigPaths...)
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find the system fontconfig configuration for -system-fonts; set FONTCONFIG_FILE")
+}
+
+// embeddedFontsHash identifies the embedded fonts in directory names.
+var embeddedFontsHash = sync.OnceValue(func() string {
+	paths, _ := fs.Glob(embeddedFontFS, "fonts/*.ttf.gz")
+	h := sha256.New()
+	for _, p := range paths {
+		data, _ := embeddedFontFS.ReadFile(p)
+		h.Write([]byte(p))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+})
+
+// writeEmbeddedFonts writes the embedded fonts to a directory below root,
+// named by their hash.
+func writeEmbeddedFonts(root string) (string, error) {
+	return writeOnce(root, "embedded-"+embeddedFontsHash(), func(dir string) error {
+		embedded, err := embeddedFonts()
+		if err != nil {
+			return err
+		}
+		for _, font := range embedded {
+			if err := os.WriteFile(filepath.Join(dir, font.name), font.data, 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// linkFontFiles links files into a directory below root, named by a hash of
+// their paths, sizes and modification times so changed files get a new one.
+func linkFontFiles(root string, files []string) (string, error) {
+	var key strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&key, "%s\x00%d\x00%d\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return writeOnce(root, "files-"+contentHash([]byte(key.String())), func(dir string) error {
+		for i, file := range files {
+			// Prefix with the index so files with the same name in
+			// different directories do not collide.
+			if err := linkOrCopy(file, filepath.Join(dir, fmt.Sprintf("%d-%s", i, filepath.Base(file)))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeOnce returns root/name, filling it with write first if it does not
+// exist yet. The directory is written under a temporary name and renamed,
+// so concurrent runs never see it half written.
+func writeOnce(root, name string, write func(dir string) error) (string, error) {
+	dir := filepath.Join(root, name)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir, nil
+	}
+	tmp, err := os.MkdirTemp(root, ".tmp-"+name+"-")
+	if err != nil {
+		return "", err
+	}
+	if err := write(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		// Another run may have written it first.
+		if info, statErr := os.Stat(dir); statErr == nil && info.IsDir() {
+			return dir, nil
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// linkOrCopy symlinks src to dst, copying where symlinks are not permitted.
+func linkOrCopy(src, dst string) error {
+	if err := os.Symlink(src, dst); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+// withFontFallback rewrites every font-family list in svg into a form resvg
+// parses and ends it with family. resvg 0.35 drops a quoted name that
+// follows ", ", so "'Hack Nerd Font', 'DejaVu Sans'" would match nothing
+// without a Hack font and the text would not be drawn at all. Lists in <style>
+// elements are left alone. An SVG that cannot be parsed is returned as is.
+func withFontFallback(svg []byte, family string) []byte {
+	if !bytes.Contains(svg, []byte("font-family")) {
+		return svg
+	}
+	doc, err := parseSVG(string(svg))
+	if err != nil {
+		return svg
+	}
+	doc.Root.Walk(func(n *SVGNode) bool {
+		if list, ok := n.Attr("font-family"); ok {
+			n.SetAttr("font-family", fontFamilyList(list, family))
+		}
+		if style, ok := n.Attr("style"); ok && strings.Contains(style, "font-family") {
+			declarations := strings.Split(style, ";")
+			for i, decl := range declarations {
+				name, value, found := strings.Cut(decl, ":")
+				if found && strings.TrimSpace(name) == "font-family" {
+					declarations[i] = "font-family:" + fontFamilyList(value, family)
+				}
+			}
+			n.SetAttr("style", strings.Join(declarations, ";"))
+		}
+		return true
+	})
+	return []byte(doc.String())
+}
+
+// genericFontFamilies are CSS keywords, which must not be quoted.
+var genericFontFamilies = map[string]bool{
+	"serif": true, "sans-serif": true, "monospace": true, "cursive": true, "fantasy": true,
+}
+
+// fontFamilyList normalizes a CSS font-family list to quoted names without
+// spaces after the commas and appends fallback unless it is listed.
+func fontFamilyList(list, fallback string) string {
+	var names []string
+	listed := false
+	for _, name := range splitFontFamilies(list) {
+		listed = listed || strings.EqualFold(name, fallback)
+		names = append(names, quoteFontFamily(name))
+	}
+	if !listed {
+		names = append(names, quoteFontFamily(fallback))
+	}
+	return strings.Join(names, ",")
+}
+
+// splitFontFamilies returns the unquoted names of a font-family list.
+func splitFontFamilies(list string) []string {
+	var names []string
+	var name strings.Builder
+	var quote rune
+	flush := func() {
+		if n := strings.TrimSpace(name.String()); n != "" {
+			names = append(names, n)
+		}
+		name.Reset()
+	}
+	for _, r := range list {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			name.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ',':
+			flush()
+		default:
+			name.WriteRune(r)
+		}
+	}
+	flush()
+	return names
+}
+
+func quoteFontFamily(name string) string {
+	if genericFontFamilies[strings.ToLower(name)] {
+		return name
+	}
+	if strings.Contains(name, "'") {
+		return `"` + name + `"`
+	}
+	return "'" + name + "'"
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveFonts(t *testing.T) {
+	projectDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(projectDir, "fonts"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "fonts", "a.ttf"), []byte("font"), 0644))
+	other := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(other, "b.otf"), []byte("font"), 0644))
+
+	tests := []struct {
+		name        string
+		config      Config
+		dirs        []string
+		files       []string
+		expected    Fonts
+		expectError string
+	}{
+		{
+			name: "no fonts",
+		},
+		{
+			name:   "config paths are relative to the project",
+			config: Config{FontDirs: []string{"fonts"}, FontFiles: []string{"fonts/a.ttf"}},
+			expected: Fonts{
+				Dirs:  []string{filepath.Join(projectDir, "fonts")},
+				Files: []string{filepath.Join(projectDir, "fonts", "a.ttf")},
+			},
+		},
+		{
+			name:   "flags follow the config and duplicates are dropped",
+			config: Config{FontFiles: []string{"fonts/a.ttf"}},
+			files:  []string{filepath.Join(other, "b.otf"), filepath.Join(projectDir, "fonts", "a.ttf")},
+			expected: Fonts{
+				Files: []string{filepath.Join(projectDir, "fonts", "a.ttf"), filepath.Join(other, "b.otf")},
+			},
+		},
+		{
+			name:        "missing directory",
+			dirs:        []string{filepath.Join(other, "nope")},
+			expectError: "invalid font directory",
+		},
+		{
+			name:        "file as directory",
+			config:      Config{FontDirs: []string{"fonts/a.ttf"}},
+			expectError: "is not a directory",
+		},
+		{
+			name:        "directory as file",
+			files:       []string{other},
+			expectError: "is a directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fonts, err := resolveFonts(projectDir, &tt.config, tt.dirs, tt.files)
+
+			if tt.expectError != "" {
+				assert.ErrorContains(t, err, tt.expectError)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, fonts)
+			}
+		})
+	}
+}
+
+func TestWriteFontconfig(t *testing.T) {
+	fontDir := t.TempDir()
+	font := filepath.Join(fontDir, "Hack & Co.ttf")
+	require.NoError(t, os.WriteFile(font, []byte("font"), 0644))
+	system := filepath.Join(t.TempDir(), "fonts.conf")
+	require.NoError(t, os.WriteFile(system, []byte("<fontconfig/>"), 0644))
+	t.Setenv("FONTCONFIG_FILE", system)
+
+	root := t.TempDir()
+	fonts := Fonts{Dirs: []string{"/opt/fonts"}, Files: []string{font}, System: true}
+	path, err := writeFontconfig(root, fonts)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	conf := string(data)
+	assert.Contains(t, conf, "<include>"+system+"</include>")
+	assert.Contains(t, conf, "<dir>/opt/fonts</dir>")
+	assert.Contains(t, conf, "<cachedir>"+filepath.Join(root, "cache")+"</cachedir>")
+	assert.Contains(t, conf, "embedded-", "the embedded fonts remain the fallback")
+
+	linked, err := filepath.Glob(filepath.Join(root, "files-*", "0-Hack & Co.ttf"))
+	require.NoError(t, err)
+	require.Len(t, linked, 1)
+	assert.Contains(t, conf, "<dir>"+filepath.Dir(linked[0])+"</dir>")
+
+	t.Run("written once", func(t *testing.T) {
+		again, err := writeFontconfig(root, fonts)
+		require.NoError(t, err)
+		assert.Equal(t, path, again)
+		entries, err := os.ReadDir(root)
+		require.NoError(t, err)
+		assert.Len(t, entries, 3, "one conf, files and embedded directory each")
+	})
+
+	t.Run("embedded fonts leave out the system ones", func(t *testing.T) {
+		root := t.TempDir()
+		path, err := writeFontconfig(root, Fonts{})
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "<include")
+
+		dirs, err := filepath.Glob(filepath.Join(root, "embedded-*"))
+		require.NoError(t, err)
+		require.Len(t, dirs, 1)
+		assert.Contains(t, string(data), "<dir>"+dirs[0]+"</dir>")
+		for _, name := range []string{"DejaVuSans.ttf", "DejaVuSans-Bold.ttf", "DejaVuSansMono.ttf", "DejaVuSansMono-Bold.ttf"} {
+			assert.FileExists(t, filepath.Join(dirs[0], name))
+		}
+	})
+
+	t.Run("missing system configuration", func(t *testing.T) {
+		t.Setenv("FONTCONFIG_FILE", "")
+		t.Setenv("FONTCONFIG_PATH", t.TempDir())
+		t.Setenv("PATH", "")
+		saved := systemFontconfigPaths
+		systemFontconfigPaths = nil
+		defer func() { systemFontconfigPaths = saved }()
+
+		_, err := writeFontconfig(t.TempDir(), Fonts{System: true, Dirs: []string{"/opt/fonts"}})
+		assert.ErrorContains(t, err, "set FONTCONFIG_FILE")
+
+		t.Setenv("FONTCONFIG_PATH", filepath.Dir(system))
+		path, err := systemFontconfig()
+		require.NoError(t, err)
+		assert.Equal(t, system, path)
+	})
+}
+
+func TestRsvgFontconfig(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	path, cleanup, err := rsvgFontconfig(Fonts{})
+	require.NoError(t, err)
+	defer cleanup()
+	root, err := fontconfigRoot()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(path, root+string(filepath.Separator)), path)
+}
+
+func TestFontFamilyList(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     string
+		expected string
+	}{
+		{
+			name:     "template list",
+			list:     "'Hack Nerd Font', 'HackNerdFont', 'DejaVu Sans', 'Arial'",
+			expected: "'Hack Nerd Font','HackNerdFont','DejaVu Sans','Arial'",
+		},
+		{
+			name:     "fallback appended",
+			list:     `Inter, "Helvetica Neue", sans-serif`,
+			expected: "'Inter','Helvetica Neue',sans-serif,'DejaVu Sans'",
+		},
+		{
+			name:     "fallback listed in other case",
+			list:     "dejavu sans",
+			expected: "'dejavu sans'",
+		},
+		{
+			name:     "quote in name",
+			list:     `"Bob's Font"`,
+			expected: `"Bob's Font",'DejaVu Sans'`,
+		},
+		{
+			name:     "empty list",
+			list:     " ",
+			expected: "'DejaVu Sans'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, fontFamilyList(tt.list, embeddedFontFamily))
+		})
+	}
+}
+
+func TestWithFontFallback(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg">` +
+		`<text font-family="Inter, 'Hack'">a</text>` +
+		`<text style="fill:#fff; font-family: Inter">b</text></svg>`)
+	out := string(withFontFallback(svg, embeddedFontFamily))
+	assert.Contains(t, out, `font-family="'Inter','Hack','DejaVu Sans'"`)
+	assert.Contains(t, out, `font-family:'Inter','DejaVu Sans'`)
+
+	plain := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><rect/></svg>`)
+	assert.Equal(t, plain, withFontFallback(plain, embeddedFontFamily))
+	assert.Equal(t, []byte("not an svg font-family"), withFontFallback([]byte("not an svg font-family"), embeddedFontFamily))
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"sort"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// Format encodes a rendered banner into one output file type.
+type Format interface {
+	// Name is the identifier used by the -format flag.
+	Name() string
+	// Ext is the file extension without the dot.
+	Ext() string
+	Encode(ctx context.Context, src *EncodeSource, opts EncodeOptions) ([]byte, error)
+}
+
+// vectorFormat is a Format that can be encoded from the SVG alone. Such a
+// format is written once, without @2x copies, and is encoded from a raster
+// only when EncodeVector reports that it could not encode the SVG.
+type vectorFormat interface {
+	Format
+	EncodeVector(ctx context.Context, svg string, opts EncodeOptions) ([]byte, bool, error)
+}
+
+// multiSizeFormat is a Format that holds the banner at several sizes in one
+// file. Such a format is written once, from the largest raster.
+type multiSizeFormat interface {
+	Format
+	// Sizes are the largest dimension of each image in the file.
+	Sizes() []int
+}
+
+// EncodeSource is one rendered raster together with the SVG it was rendered
+// from. The PNG is decoded on first use and shared by all formats, so
+// formats that copy it or work from the SVG skip decoding.
+type EncodeSource struct {
+	SVG    string
+	Raster Raster
+
+	img image.Image
+}
+
+func (s *EncodeSource) Image() (image.Image, error) {
+	if s.img == nil {
+		img, err := png.Decode(bytes.NewReader(s.Raster.PNG))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode rendered PNG: %w", err)
+		}
+		s.img = img
+	}
+	return s.img, nil
+}
+
+// EncodeOptions are shared by all formats; each uses what applies to it.
+type EncodeOptions struct {
+	// Quality is the JPEG quality from 1 to 100. The other formats are
+	// lossless and ignore it.
+	Quality int
+	// OptimizePNG runs the PNG through optimizePNG.
+	OptimizePNG bool
+	// MaxPNGKB is the PNG size budget in kilobytes. A positive budget
+	// enables optimization and allows palette quantization to meet it.
+	MaxPNGKB int
+	// Render are the options of the scale-1 raster, used by formats encoded
+	// from the SVG.
+	Render RenderOptions
+}
+
+const defaultQuality = 90
+
+var formatRegistry = map[string]Format{}
+
+func registerFormat(f Format) {
+	formatRegistry[f.Name()] = f
+}
+
+func init() {
+	registerFormat(pngFormat{})
+	registerFormat(jpegFormat{})
+	registerFormat(gifFormat{})
+	registerFormat(webpFormat{})
+	registerFormat(icoFormat{})
+	registerFormat(pdfFormat{})
+}
+
+func formatNames() []string {
+	names := make([]string, 0, len(formatRegistry))
+	for name := range formatRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseFormatList parses a comma-separated list such as "png,webp". Empty
+// input selects PNG only.
+func parseFormatList(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return []string{"png"}, nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "jpg" {
+			name = "jpeg"
+		}
+		if _, ok := formatRegistry[name]; !ok {
+			return nil, fmt.Errorf("unknown format %q. Use: %s", name, strings.Join(formatNames(), ", "))
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (o EncodeOptions) validate() error {
+	if o.Quality < 1 || o.Quality > 100 {
+		return fmt.Errorf("invalid quality %d: must be between 1 and 100", o.Quality)
+	}
+	if o.MaxPNGKB < 0 {
+		return fmt.Errorf("invalid PNG size budget %d KB: must not be negative", o.MaxPNGKB)
+	}
+	return nil
+}
+
+func (o EncodeOptions) optimizePNG() bool {
+	return o.OptimizePNG || o.MaxPNGKB > 0
+}
+
+type pngFormat struct{}
+
+func (pngFormat) Name() string { return "png" }
+func (pngFormat) Ext() string  { return "png" }
+
+// Encode returns the PNG as rendered, or optimized when requested.
+func (pngFormat) Encode(ctx context.Context, src *EncodeSource, opts EncodeOptions) ([]byte, error) {
+	if !opts.optimizePNG() {
+		return src.Raster.PNG, nil
+	}
+	return optimizePNG(src.Raster.PNG, opts.MaxPNGKB*1024)
+}
+
+type jpegFormat struct{}
+
+func (jpegFormat) Name() string { return "jpeg" }
+func (jpegFormat) Ext() string  { return "jpg" }
+
+// Encode flattens transparency onto white, since JPEG has no alpha channel.
+func (jpegFormat) Encode(ctx context.Context, src *EncodeSource, opts EncodeOptions) ([]byte, error) {
+	img, err := src.Image()
+	if err != nil {
+		return nil, err
+	}
+
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: opts.Quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode JPEG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+type gifFormat struct{}
+
+func (gifFormat) Name() string { return "gif" }
+func (gifFormat) Ext() string  { return "gif" }
+
+func (gifFormat) Encode(ctx context.Context, src *EncodeSource, opts EncodeOptions) ([]byte, error) {
+	img, err := src.Image()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, img, &gif.Options{NumColors: 256}); err != nil {
+		return nil, fmt.Errorf("failed to encode GIF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+type webpFormat struct{}
+
+func (webpFormat) Name() string { return "webp" }
+func (webpFormat) Ext() string  { return "webp" }
+
+// Encode writes lossless WebP, the only mode of the pure Go encoder.
+func (webpFormat) Encode(ctx context.Context, src *EncodeSource, opts EncodeOptions) ([]byte, error) {
+	img, err := src.Image()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, img, nil); err != nil {
+		return nil, fmt.Errorf("failed to encode WebP: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+type icoFormat struct{}
+
+func (icoFormat) Name() string { return "ico" }
+func (icoFormat) Ext() string  { return "ico" }
+
+// icoSizes are the sizes of the square icons in the file.
+var icoSizes = []int{256, 128, 64, 48, 32, 16}
+
+func (icoFormat) Sizes() []int { return icoSizes }
+
+// Encode writes an icon file with PNG-compressed entries, supported since
+// Windows Vista and by all browsers. Icons are square: the banner is fitted
+// and centered on a transparent background.
+func (icoFormat) Encode(ctx context.Context, src *EncodeSource, opts EncodeOptions) ([]byte, error) {
+	img, err := src.Image()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([][]byte, len(icoSizes))
+	for i, limit := range icoSizes {
+		size := fitSize(img.Bounds().Size(), limit)
+		icon := image.NewNRGBA(image.Rect(0, 0, limit, limit))
+		at := image.Pt((limit-size.X)/2, (limit-size.Y)/2)
+		draw.Draw(icon, image.Rectangle{Min: at, Max: at.Add(size)}, resizeImage(img, size.X, size.Y), image.Point{}, draw.Src)
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, icon); err != nil {
+			return nil, fmt.Errorf("failed to encode ICO: %w", err)
+		}
+		entries[i] = buf.Bytes()
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, [3]uint16{0, 1, uint16(len(entries))})
+	offset := 6 + 16*len(entries)
+	for i, data := range entries {
+		// A dimension of 256 is stored as 0.
+		buf.WriteByte(byte(icoSizes[i]))
+		buf.WriteByte(byte(icoSizes[i]))
+		buf.Write([]byte{0, 0}) // palette size, reserved
+		binary.Write(&buf, binary.LittleEndian, [2]uint16{1, 32})
+		binary.Write(&buf, binary.LittleEndian, [2]uint32{uint32(len(data)), uint32(offset)})
+		offset += len(data)
+	}
+	for _, data := range entries {
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// fitSize scales size so its larger dimension is limit.
+func fitSize(size image.Point, limit int) image.Point {
+	if size.X >= size.Y {
+		return image.Pt(limit, max(1, (size.Y*limit+size.X/2)/size.X))
+	}
+	return image.Pt(max(1, (size.X*limit+size.Y/2)/size.Y), limit)
+}
+
+// resizeImage downscales by averaging the source pixels covered by each
+// destination pixel.
+func resizeImage(img image.Image, width, height int) *image.RGBA {
+	src := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
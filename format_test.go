@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormatList(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:     "empty selects PNG",
+			input:    "",
+			expected: []string{"png"},
+		},
+		{
+			name:     "list with alias and duplicates",
+			input:    "png, WebP,jpg,jpeg",
+			expected: []string{"png", "webp", "jpeg"},
+		},
+		{
+			name:        "unknown format",
+			input:       "png,avif",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formats, err := parseFormatList(tt.input)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown format")
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, formats)
+			}
+		})
+	}
+}
+
+func TestEncodeOptionsValidate(t *testing.T) {
+	assert.NoError(t, EncodeOptions{Quality: 1}.validate())
+	assert.NoError(t, EncodeOptions{Quality: 100}.validate())
+	assert.Error(t, EncodeOptions{Quality: 0}.validate())
+	assert.Error(t, EncodeOptions{Quality: 101}.validate())
+	assert.Error(t, EncodeOptions{Quality: 90, MaxPNGKB: -1}.validate())
+}
+
+// testImage is a two-color image so lossy formats can be checked roughly.
+func testImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{R: 200, G: 40, B: 40, A: 255}
+			if x >= width/2 {
+				c = color.RGBA{R: 40, G: 40, B: 200, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// imageSource wraps img as a rendered raster.
+func imageSource(t *testing.T, img image.Image) *EncodeSource {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return &EncodeSource{SVG: "<svg/>", Raster: Raster{Scale: 1, PNG: buf.Bytes()}}
+}
+
+func TestFormatsRoundTrip(t *testing.T) {
+	img := testImage(64, 24)
+	src := imageSource(t, img)
+	opts := EncodeOptions{Quality: defaultQuality}
+
+	decoders := map[string]func([]byte) (image.Image, error){
+		"png":  func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) },
+		"jpeg": func(b []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(b)) },
+		"gif":  func(b []byte) (image.Image, error) { return gif.Decode(bytes.NewReader(b)) },
+		"webp": func(b []byte) (image.Image, error) { return nativewebp.Decode(bytes.NewReader(b)) },
+	}
+
+	for name, decode := range decoders {
+		t.Run(name, func(t *testing.T) {
+			data, err := formatRegistry[name].Encode(context.Background(), src, opts)
+			require.NoError(t, err)
+
+			decoded, err := decode(data)
+			require.NoError(t, err)
+			assert.Equal(t, img.Bounds(), decoded.Bounds())
+
+			r, _, b, _ := decoded.At(4, 12).RGBA()
+			assert.Greater(t, r, b, "left half should stay red")
+		})
+	}
+
+	t.Run("png is written as rendered", func(t *testing.T) {
+		data, err := pngFormat{}.Encode(context.Background(), src, opts)
+		require.NoError(t, err)
+		assert.Equal(t, src.Raster.PNG, data)
+	})
+
+	t.Run("webp is lossless", func(t *testing.T) {
+		data, err := webpFormat{}.Encode(context.Background(), src, opts)
+		require.NoError(t, err)
+		decoded, err := nativewebp.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, color.NRGBAModel.Convert(img.At(60, 3)), color.NRGBAModel.Convert(decoded.At(60, 3)))
+	})
+
+	t.Run("jpeg quality changes size", func(t *testing.T) {
+		noisy := image.NewRGBA(image.Rect(0, 0, 64, 64))
+		for i := range noisy.Pix {
+			noisy.Pix[i] = uint8(i * 7919 % 251)
+		}
+		low, err := jpegFormat{}.Encode(context.Background(), imageSource(t, noisy), EncodeOptions{Quality: 10})
+		require.NoError(t, err)
+		high, err := jpegFormat{}.Encode(context.Background(), imageSource(t, noisy), EncodeOptions{Quality: 95})
+		require.NoError(t, err)
+		assert.Less(t, len(low), len(high))
+	})
+}
+
+func TestICOFormat(t *testing.T) {
+	data, err := icoFormat{}.Encode(context.Background(), imageSource(t, testImage(1600, 600)), EncodeOptions{Quality: defaultQuality})
+	require.NoError(t, err)
+
+	var header [3]uint16
+	require.NoError(t, binary.Read(bytes.NewReader(data), binary.LittleEndian, &header))
+	assert.Equal(t, [3]uint16{0, 1, uint16(len(icoSizes))}, header)
+
+	for i, limit := range icoSizes {
+		entry := data[6+16*i : 6+16*(i+1)]
+		width, height := int(entry[0]), int(entry[1])
+		if width == 0 {
+			width, height = 256, 256
+		}
+		assert.Equal(t, limit, width)
+		assert.Equal(t, limit, height)
+
+		size := binary.LittleEndian.Uint32(entry[8:12])
+		offset := binary.LittleEndian.Uint32(entry[12:16])
+		icon, err := png.Decode(bytes.NewReader(data[offset : offset+size]))
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, limit, limit), icon.Bounds())
+
+		// The banner is centered between transparent bands, not squashed.
+		bands := (limit - max(1, (600*limit+800)/1600)) / 2
+		_, _, _, a := icon.At(limit/2, bands/2).RGBA()
+		assert.Zero(t, a, limit)
+		_, _, _, a = icon.At(limit/2, limit/2).RGBA()
+		assert.Equal(t, uint32(0xffff), a, limit)
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	small := resizeImage(testImage(64, 24), 16, 6)
+	assert.Equal(t, image.Rect(0, 0, 16, 6), small.Bounds())
+	assert.Equal(t, color.RGBA{R: 200, G: 40, B: 40, A: 255}, small.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 40, G: 40, B: 200, A: 255}, small.RGBAAt(15, 5))
+
+	assert.Equal(t, image.Pt(256, 96), fitSize(image.Pt(1600, 600), 256))
+	assert.Equal(t, image.Pt(8, 16), fitSize(image.Pt(50, 100), 16))
+	assert.Equal(t, image.Pt(16, 1), fitSize(image.Pt(1000, 10), 16))
+}
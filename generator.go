@@ -5,85 +5,184 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
-
-	"github.com/kanrichan/resvg-go"
+	"time"
 )
 
-//go:embed templates/*.svg
+//go:embed templates/*.svg templates/partials/*.svg
 var templateFS embed.FS
 
+// alignments are the built-in layouts. Each may override blocks of
+// templates/base.svg in templates/banner.<align>.svg and uses the base as is
+// otherwise; the computed layout places the elements.
+var alignments = []string{"center", "left", "right"}
+
+func validAlignment(align string) bool {
+	for _, a := range alignments {
+		if a == align {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinTemplatePath returns the embedded template of an alignment, or
+// base.svg for "base" and alignments without overrides of their own.
+func builtinTemplatePath(name string) string {
+	path := fmt.Sprintf("templates/banner.%s.svg", name)
+	if _, err := fs.Stat(templateFS, path); err != nil {
+		return "templates/base.svg"
+	}
+	return path
+}
+
 func loadTemplate(align string) (string, error) {
-	templatePath := fmt.Sprintf("templates/banner.%s.svg", align)
+	if !validAlignment(align) {
+		return "", fmt.Errorf("failed to load template: unknown alignment %q. Use: %s", align, strings.Join(alignments, ", "))
+	}
+	templatePath := builtinTemplatePath(align)
 
 	data, err := templateFS.ReadFile(templatePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to load template %s: %w", templatePath, err)
 	}
 
-	return string(data), nil
+	template, err := composeTemplate(string(data), templateResolver{})
+	if err != nil {
+		return "", fmt.Errorf("failed to load template %s: %w", templatePath, err)
+	}
+
+	return template, nil
 }
 
-func generateSVG(metadata *Metadata, theme *ThemePalette, align string, badges []string) (string, error) {
-	template, err := loadTemplate(align)
+// loadTemplateFile reads a user-supplied template, such as an SVG exported
+// from a design tool with id-based slots. It may extend a built-in template
+// and include partials relative to its own directory.
+func loadTemplateFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("failed to load template %s: %w", path, err)
 	}
 
-	vars := map[string]string{
-		"BG0":          theme.BG0,
-		"BG1":          theme.BG1,
-		"BG2":          theme.BG2,
-		"WAVE0":        theme.WAVE0,
-		"WAVE1":        theme.WAVE1,
-		"PROJECT_NAME": metadata.Name,
-		"TAGLINE":      metadata.Tagline,
-		"BADGE_1":      "",
-		"BADGE_2":      "",
-		"BADGE_3":      "",
+	template, err := composeTemplate(string(data), templateResolver{dir: filepath.Dir(path)})
+	if err != nil {
+		return "", fmt.Errorf("failed to load template %s: %w", path, err)
 	}
 
-	if len(badges) > 0 {
-		vars["BADGE_1"] = badges[0]
+	return template, nil
+}
+
+func generateSVG(metadata *Metadata, theme *ThemePalette, align string, badges []string, canvas Canvas) (string, error) {
+	if err := canvas.validate(); err != nil {
+		return "", err
 	}
-	if len(badges) > 1 {
-		vars["BADGE_2"] = badges[1]
+
+	template, err := loadTemplate(align)
+	if err != nil {
+		return "", err
 	}
-	if len(badges) > 2 {
-		vars["BADGE_3"] = badges[2]
+
+	return renderTemplate(template, metadata, theme, align, badges, canvas)
+}
+
+// renderTemplate fills a template in two passes: {{NAME}} placeholders are
+// substituted first, then id-based slots receive the metadata text and theme
+// colors. Templates may use either mechanism or both. Inline Markdown in the
+// title and tagline is styled in the slots and stripped from placeholders.
+func renderTemplate(template string, metadata *Metadata, theme *ThemePalette, align string, badges []string, canvas Canvas) (string, error) {
+	title := parseInlineMarkdown(metadata.Name)
+	tagline := parseInlineMarkdown(metadata.Tagline)
+	layout := computeLayout(canvas, align)
+	if metadata.Logo != nil {
+		layout.reserveLogo(metadata.Logo.Aspect)
 	}
+	layout.fitText(title, tagline, badges)
+	layout.placeLogo(title, tagline)
+	seed := backgroundSeed(metadata.Name, metadata.Seed)
+	layout.WavePath, layout.WaveLine = seededWaves(seed, layout)
+	vars := layout.vars()
+	vars["BG0"], vars["BG1"], vars["BG2"] = theme.backgroundColors()
+	vars["WAVE0"] = theme.WAVE0
+	vars["WAVE1"] = theme.WAVE1
+	vars["PROJECT_NAME"] = spansText(title)
+	vars["TAGLINE"] = spansText(tagline)
+	vars["BADGE_1"] = ""
+	vars["BADGE_2"] = ""
+	vars["BADGE_3"] = ""
 
-	svg := replaceVariables(template, vars)
+	for i := 0; i < len(badges) && i < 3; i++ {
+		vars[fmt.Sprintf("BADGE_%d", i+1)] = badges[i]
+	}
 
-	if strings.TrimSpace(vars["BADGE_1"]) == "" {
-		svg = stripBadge(svg, 1)
+	if err := addUserVars(vars, metadata.Vars); err != nil {
+		return "", err
 	}
-	if strings.TrimSpace(vars["BADGE_2"]) == "" {
-		svg = stripBadge(svg, 2)
+	if err := checkUndefinedVariables(template, vars); err != nil {
+		return "", err
 	}
-	if strings.TrimSpace(vars["BADGE_3"]) == "" {
-		svg = stripBadge(svg, 3)
+
+	doc, err := parseSVG(template)
+	if err != nil {
+		return "", err
 	}
 
-	return svg, nil
-}
+	replaceVariables(doc, vars)
+	applyThemeSlots(doc, theme)
+	setGradientSlot(doc, theme, layout)
 
-func convertSVGToPNG(svg string) ([]byte, error) {
-	// Try rsvg-convert first (fastest and most reliable)
-	if _, err := exec.LookPath("rsvg-convert"); err == nil {
-		return convertWithRsvgConvert([]byte(svg))
+	style, err := parseBackgroundStyle(metadata.Background)
+	if err != nil {
+		return "", err
+	}
+	setBackgroundSlot(doc, generateBackground(style, seed, layout))
+	setLogoSlot(doc, metadata.Logo, layout)
+	setSlotSpans(doc, "title", title)
+	setSlotSpans(doc, "tagline", tagline)
+	for n := 1; n <= 3; n++ {
+		badge := vars[fmt.Sprintf("BADGE_%d", n)]
+		if strings.TrimSpace(badge) == "" {
+			stripBadge(doc, n)
+		} else {
+			setSlotText(doc, badgeID(n), badge)
+		}
 	}
 
-	// Fallback to resvg-go (pure Go, no external dependencies)
-	return convertWithResvg([]byte(svg))
+	return doc.String(), nil
+}
+
+func convertWithRsvgConvert(ctx context.Context, svgData []byte, opts RenderOptions) ([]byte, error) {
+	return runRsvgConvert(ctx, svgData, "png", opts)
 }
 
-func convertWithRsvgConvert(svgData []byte) ([]byte, error) {
-	cmd := exec.Command("rsvg-convert", "-f", "png")
+// runRsvgConvert converts svgData to format ("png" or "pdf") with the system
+// rsvg-convert. The process is killed when ctx is done.
+func runRsvgConvert(ctx context.Context, svgData []byte, format string, opts RenderOptions) ([]byte, error) {
+	args := []string{"-f", format}
+	if opts.Width > 0 && opts.Height > 0 {
+		args = append(args, "-w", strconv.Itoa(opts.Width), "-h", strconv.Itoa(opts.Height))
+	}
+	if opts.DPI > 0 {
+		dpi := strconv.FormatFloat(opts.DPI, 'f', -1, 64)
+		args = append(args, "-d", dpi, "-p", dpi)
+	}
+
+	cmd := exec.CommandContext(ctx, "rsvg-convert", args...)
 	cmd.Stdin = bytes.NewReader(svgData)
+	// rsvg-convert finds fonts through fontconfig, so the embedded and
+	// extra fonts are given with a config file of their own.
+	conf, cleanup, err := rsvgFontconfig(opts.Fonts)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	cmd.Env = append(os.Environ(), "FONTCONFIG_FILE="+conf)
+	// Do not wait for children that inherited the pipes after a kill.
+	cmd.WaitDelay = time.Second
 
 	var out bytes.Buffer
 	var stderr bytes.Buffer
@@ -91,53 +190,143 @@ func convertWithRsvgConvert(svgData []byte) ([]byte, error) {
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("rsvg-convert stopped: %w", ctx.Err())
+		}
 		return nil, fmt.Errorf("rsvg-convert failed: %w (stderr: %s)", err, stderr.String())
 	}
 
 	return out.Bytes(), nil
 }
 
-func convertWithResvg(svgData []byte) ([]byte, error) {
-	ctx := context.Background()
-	resvgCtx, err := resvg.NewContext(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create resvg context: %w", err)
-	}
-	defer resvgCtx.Close()
+// convertWithResvg renders with the process-wide resvg WASM pool.
+func convertWithResvg(ctx context.Context, svgData []byte, opts RenderOptions) ([]byte, error) {
+	return defaultResvgPool.render(ctx, svgData, opts)
+}
 
-	renderer, err := resvgCtx.NewRenderer()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create renderer: %w", err)
+// bannerFile is an encoded output waiting to be written.
+type bannerFile struct {
+	path   string
+	format string
+	data   []byte
+	raster *Raster
+}
+
+// writeBannerFiles writes banner.svg and each raster in every format, named
+// with the @2x convention for scales other than 1. Vector formats are
+// encoded once from the SVG, and from the scale-1 raster (or the first one)
+// only when that fails. Multi-size formats such as ICO are encoded once from
+// the largest raster. Everything is encoded before the first file is
+// written, so a run stopped through ctx leaves the previous files
+// untouched, and each file is replaced atomically.
+func writeBannerFiles(ctx context.Context, projectDir, svg string, rasters []Raster, formats []string, opts EncodeOptions) error {
+	files := []bannerFile{{path: filepath.Join(projectDir, "banner.svg"), format: "svg", data: []byte(svg)}}
+
+	vector := map[string]bool{}
+	for _, name := range formats {
+		format, ok := formatRegistry[name].(vectorFormat)
+		if !ok {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("encoding stopped: %w", err)
+		}
+		vector[name] = true
+		data, ok, err := format.EncodeVector(ctx, svg, opts)
+		if err != nil {
+			return err
+		}
+		if ok {
+			files = append(files, bannerFile{path: filepath.Join(projectDir, "banner."+format.Ext()), format: name, data: data})
+			delete(vector, name)
+		}
 	}
-	defer renderer.Close()
 
-	if err := renderer.LoadSystemFonts(); err != nil {
-		return nil, fmt.Errorf("failed to load system fonts: %w", err)
+	base := 0
+	for i, raster := range rasters {
+		if raster.Scale == 1 {
+			base = i
+			break
+		}
+	}
+	largest := 0
+	for i, raster := range rasters {
+		if raster.Scale > rasters[largest].Scale {
+			largest = i
+		}
+	}
+	for i := range rasters {
+		raster := &rasters[i]
+		src := &EncodeSource{SVG: svg, Raster: *raster}
+		for _, name := range formats {
+			_, isVector := formatRegistry[name].(vectorFormat)
+			if isVector && (!vector[name] || i != base) {
+				continue
+			}
+			_, isMultiSize := formatRegistry[name].(multiSizeFormat)
+			if isMultiSize && i != largest {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("encoding stopped: %w", err)
+			}
+			format := formatRegistry[name]
+			data, err := format.Encode(ctx, src, opts)
+			if err != nil {
+				return err
+			}
+			scale := raster.Scale
+			if isVector || isMultiSize {
+				scale = 1
+			}
+			path := filepath.Join(projectDir, rasterFileName("banner", scale, format.Ext()))
+			files = append(files, bannerFile{path: path, format: name, data: data, raster: raster})
+		}
 	}
 
-	pngData, err := renderer.Render(svgData)
-	if err != nil {
-		return nil, fmt.Errorf("resvg rendering failed: %w", err)
+	for _, file := range files {
+		if err := writeFileAtomic(file.path, file.data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", strings.ToUpper(file.format), err)
+		}
+		if file.raster == nil {
+			fmt.Printf("Generated: %s\n", file.path)
+			continue
+		}
+		fmt.Printf("Generated: %s (renderer: %s)\n", file.path, file.raster.Renderer)
+		if file.format == "png" && opts.optimizePNG() {
+			fmt.Printf("Optimized: %s %s -> %s\n", file.path, formatKB(len(file.raster.PNG)), formatKB(len(file.data)))
+			if opts.MaxPNGKB > 0 && len(file.data) > opts.MaxPNGKB*1024 {
+				fmt.Fprintf(os.Stderr, "Warning: %s is %s, over the %d KB budget\n", file.path, formatKB(len(file.data)), opts.MaxPNGKB)
+			}
+		}
 	}
 
-	return pngData, nil
+	return nil
 }
 
-func writeBannerFiles(projectDir, svg string, png []byte) error {
-	svgPath := filepath.Join(projectDir, "banner.svg")
-	pngPath := filepath.Join(projectDir, "banner.png")
-
-	if err := os.WriteFile(svgPath, []byte(svg), 0644); err != nil {
-		return fmt.Errorf("failed to write SVG: %w", err)
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
 	}
-	fmt.Printf("Generated: %s\n", svgPath)
+	defer os.Remove(tmp.Name())
 
-	if png != nil {
-		if err := os.WriteFile(pngPath, png, 0644); err != nil {
-			return fmt.Errorf("failed to write PNG: %w", err)
-		}
-		fmt.Printf("Generated: %s\n", pngPath)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
 	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
-	return nil
+func formatKB(n int) string {
+	return fmt.Sprintf("%.1f KB", float64(n)/1024)
 }
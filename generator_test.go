@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
 	"strings"
@@ -50,8 +54,10 @@ func TestLoadTemplate(t *testing.T) {
 				require.NoError(t, err)
 				assert.NotEmpty(t, result)
 				assert.Contains(t, result, "<svg")
-				assert.Contains(t, result, "{{BG0}}")
-				assert.Contains(t, result, "{{PROJECT_NAME}}")
+				assert.Contains(t, result, "{{WIDTH}}")
+				assert.Contains(t, result, `id="theme-gradients"`)
+				assert.Contains(t, result, `id="title"`)
+				assert.Contains(t, result, `id="tagline"`)
 			}
 		})
 	}
@@ -66,11 +72,11 @@ func TestGenerateSVG(t *testing.T) {
 			Tagline: "A test tagline",
 		}
 
-		svg, err := generateSVG(metadata, lightTheme, "center", []string{})
+		svg, err := generateSVG(metadata, lightTheme, "center", []string{}, defaultCanvas)
 		require.NoError(t, err)
 		assert.NotEmpty(t, svg)
 		assert.Contains(t, svg, "<svg")
-		assert.Contains(t, svg, lightTheme.BG0)
+		assert.Contains(t, svg, lightTheme.Background.Stops[0].Color)
 		assert.Contains(t, svg, "Test Project")
 		assert.Contains(t, svg, "A test tagline")
 	})
@@ -81,7 +87,7 @@ func TestGenerateSVG(t *testing.T) {
 			Tagline: "Fast & Furious",
 		}
 
-		svg, err := generateSVG(metadata, lightTheme, "center", []string{})
+		svg, err := generateSVG(metadata, lightTheme, "center", []string{}, defaultCanvas)
 		require.NoError(t, err)
 		assert.Contains(t, svg, "&#128640;")
 		assert.Contains(t, svg, "&amp;")
@@ -94,7 +100,7 @@ func TestGenerateSVG(t *testing.T) {
 		}
 		badges := []string{"badge1", "badge2", "badge3"}
 
-		svg, err := generateSVG(metadata, lightTheme, "center", badges)
+		svg, err := generateSVG(metadata, lightTheme, "center", badges, defaultCanvas)
 		require.NoError(t, err)
 		assert.Contains(t, svg, "badge1")
 		assert.Contains(t, svg, "badge2")
@@ -107,11 +113,11 @@ func TestGenerateSVG(t *testing.T) {
 			Tagline: "Tagline",
 		}
 
-		svg, err := generateSVG(metadata, lightTheme, "center", []string{})
+		svg, err := generateSVG(metadata, lightTheme, "center", []string{}, defaultCanvas)
 		require.NoError(t, err)
-		assert.NotContains(t, svg, "BADGE1_START")
-		assert.NotContains(t, svg, "BADGE2_START")
-		assert.NotContains(t, svg, "BADGE3_START")
+		assert.NotContains(t, svg, `id="badge-1"`)
+		assert.NotContains(t, svg, `id="badge-2"`)
+		assert.NotContains(t, svg, `id="badge-3"`)
 	})
 
 	t.Run("SVG generation with partial badges", func(t *testing.T) {
@@ -121,11 +127,12 @@ func TestGenerateSVG(t *testing.T) {
 		}
 		badges := []string{"only-first"}
 
-		svg, err := generateSVG(metadata, lightTheme, "center", badges)
+		svg, err := generateSVG(metadata, lightTheme, "center", badges, defaultCanvas)
 		require.NoError(t, err)
 		assert.Contains(t, svg, "only-first")
-		assert.NotContains(t, svg, "BADGE2_START")
-		assert.NotContains(t, svg, "BADGE3_START")
+		assert.Contains(t, svg, `id="badge-1"`)
+		assert.NotContains(t, svg, `id="badge-2"`)
+		assert.NotContains(t, svg, `id="badge-3"`)
 	})
 
 	t.Run("SVG generation with all alignments", func(t *testing.T) {
@@ -136,32 +143,116 @@ func TestGenerateSVG(t *testing.T) {
 
 		alignments := []string{"center", "left", "right"}
 		for _, align := range alignments {
-			svg, err := generateSVG(metadata, lightTheme, align, []string{})
+			svg, err := generateSVG(metadata, lightTheme, align, []string{}, defaultCanvas)
 			require.NoError(t, err, "alignment: %s", align)
 			assert.NotEmpty(t, svg)
 			assert.Contains(t, svg, "<svg")
 		}
 	})
 
+	t.Run("SVG generation with custom canvas", func(t *testing.T) {
+		metadata := &Metadata{
+			Name:    "Test",
+			Tagline: "Test",
+		}
+
+		svg, err := generateSVG(metadata, lightTheme, "center", []string{}, Canvas{Width: 1200, Height: 400})
+		require.NoError(t, err)
+		assert.Contains(t, svg, `width="1200" height="400" viewBox="0 0 1200 400"`)
+		assert.NotContains(t, svg, "{{")
+		assert.NotContains(t, svg, "1600")
+	})
+
+	t.Run("invalid canvas", func(t *testing.T) {
+		metadata := &Metadata{
+			Name:    "Test",
+			Tagline: "Test",
+		}
+
+		svg, err := generateSVG(metadata, lightTheme, "center", []string{}, Canvas{Width: 0, Height: 400})
+		assert.Error(t, err)
+		assert.Empty(t, svg)
+	})
+
 	t.Run("invalid alignment", func(t *testing.T) {
 		metadata := &Metadata{
 			Name:    "Test",
 			Tagline: "Test",
 		}
 
-		svg, err := generateSVG(metadata, lightTheme, "invalid", []string{})
+		svg, err := generateSVG(metadata, lightTheme, "invalid", []string{}, defaultCanvas)
 		assert.Error(t, err)
 		assert.Empty(t, svg)
 	})
 }
 
+// designerSVG mimics an Inkscape export: namespaced editor attributes, paint
+// in style attributes and text wrapped in positioned tspans.
+const designerSVG = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" width="800" height="300" viewBox="0 0 800 300">
+  <defs>
+    <linearGradient id="linearGradient1">
+      <stop id="bg-stop-0" offset="0" style="stop-color:#000000;stop-opacity:1"/>
+      <stop id="bg-stop-1" offset="1" style="stop-color:#111111;stop-opacity:1"/>
+    </linearGradient>
+  </defs>
+  <g inkscape:label="Layer 1" inkscape:groupmode="layer">
+    <rect width="800" height="300" style="fill:url(#linearGradient1)"/>
+    <text id="title" x="40" y="120"><tspan x="40" y="120">Placeholder Title</tspan><tspan x="40" y="160">second line</tspan></text>
+    <text id="tagline" x="40" y="200"><tspan x="40" y="200">Placeholder tagline</tspan></text>
+    <g id="badge-1"><rect width="100" height="30"/><text><tspan>badge</tspan></text></g>
+    <g id="badge-2"><rect width="100" height="30"/><text><tspan>badge</tspan></text></g>
+  </g>
+</svg>`
+
+func TestRenderTemplateDesignerSVG(t *testing.T) {
+	darkTheme, _ := getTheme("dark")
+	metadata := &Metadata{
+		Name:    "Designed",
+		Tagline: "Straight from Inkscape",
+	}
+
+	svg, err := renderTemplate(designerSVG, metadata, darkTheme, "left", []string{"v1.0"}, defaultCanvas)
+	require.NoError(t, err)
+
+	assert.Contains(t, svg, `<tspan x="40" y="120">Designed</tspan></text>`)
+	assert.NotContains(t, svg, "second line")
+	assert.Contains(t, svg, `<tspan x="40" y="200">Straight from Inkscape</tspan>`)
+	assert.Contains(t, svg, "<tspan>v1.0</tspan>")
+	assert.NotContains(t, svg, `id="badge-2"`)
+	assert.Contains(t, svg, "stop-color:"+darkTheme.Background.Stops[0].Color+";stop-opacity:1")
+	assert.Contains(t, svg, "stop-color:"+darkTheme.Background.Stops[1].Color+";stop-opacity:1")
+	assert.Contains(t, svg, `inkscape:label="Layer 1"`)
+	assert.Contains(t, svg, `width="800" height="300"`)
+}
+
+func TestLoadTemplateFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	t.Run("existing file", func(t *testing.T) {
+		path := filepath.Join(tempDir, "design.svg")
+		require.NoError(t, os.WriteFile(path, []byte(designerSVG), 0644))
+
+		template, err := loadTemplateFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, designerSVG, template)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		template, err := loadTemplateFile(filepath.Join(tempDir, "missing.svg"))
+		assert.Error(t, err)
+		assert.Empty(t, template)
+		assert.Contains(t, err.Error(), "failed to load template")
+	})
+}
+
 func TestConvertSVGToPNG(t *testing.T) {
 	simpleSVG := `<svg xmlns="http://www.w3.org/2000/svg" width="100" height="100">
 		<rect width="100" height="100" fill="red"/>
 	</svg>`
 
 	t.Run("PNG conversion succeeds", func(t *testing.T) {
-		png, err := convertSVGToPNG(simpleSVG)
+		png, renderer, err := convertSVGToPNG(context.Background(), simpleSVG, defaultRendererOrder, RenderOptions{})
 
 		if err != nil {
 			t.Logf("PNG conversion failed (may be expected if no renderer available): %v", err)
@@ -169,6 +260,7 @@ func TestConvertSVGToPNG(t *testing.T) {
 		}
 
 		require.NotNil(t, png)
+		assert.Contains(t, defaultRendererOrder, renderer)
 		assert.Greater(t, len(png), 0)
 		assert.Equal(t, byte(0x89), png[0])
 		assert.Equal(t, byte('P'), png[1])
@@ -178,7 +270,7 @@ func TestConvertSVGToPNG(t *testing.T) {
 
 	t.Run("invalid SVG", func(t *testing.T) {
 		invalidSVG := "not an svg"
-		png, err := convertSVGToPNG(invalidSVG)
+		png, _, err := convertSVGToPNG(context.Background(), invalidSVG, defaultRendererOrder, RenderOptions{})
 
 		if err == nil {
 			t.Skip("Renderer accepted invalid SVG (renderer-specific behavior)")
@@ -191,12 +283,14 @@ func TestConvertSVGToPNG(t *testing.T) {
 
 func TestWriteBannerFiles(t *testing.T) {
 	tempDir := t.TempDir()
+	pngOnly := []string{"png"}
+	encodeOpts := EncodeOptions{Quality: defaultQuality}
 
 	t.Run("write SVG and PNG", func(t *testing.T) {
 		svg := "<svg>test</svg>"
 		png := []byte{0x89, 'P', 'N', 'G'}
 
-		err := writeBannerFiles(tempDir, svg, png)
+		err := writeBannerFiles(context.Background(), tempDir, svg, []Raster{{Scale: 1, PNG: png, Renderer: "test"}}, pngOnly, encodeOpts)
 		require.NoError(t, err)
 
 		svgPath := filepath.Join(tempDir, "banner.svg")
@@ -218,7 +312,7 @@ func TestWriteBannerFiles(t *testing.T) {
 
 		svg := "<svg>only svg</svg>"
 
-		err = writeBannerFiles(subDir, svg, nil)
+		err = writeBannerFiles(context.Background(), subDir, svg, nil, pngOnly, encodeOpts)
 		require.NoError(t, err)
 
 		svgPath := filepath.Join(subDir, "banner.svg")
@@ -231,11 +325,109 @@ func TestWriteBannerFiles(t *testing.T) {
 		assert.True(t, os.IsNotExist(err))
 	})
 
+	t.Run("write scaled PNGs", func(t *testing.T) {
+		subDir := filepath.Join(tempDir, "scaled")
+		require.NoError(t, os.MkdirAll(subDir, 0755))
+
+		rasters := []Raster{
+			{Scale: 1, PNG: []byte("1x"), Renderer: "test"},
+			{Scale: 2, PNG: []byte("2x"), Renderer: "test"},
+			{Scale: 1.5, PNG: []byte("1.5x"), Renderer: "test"},
+		}
+		require.NoError(t, writeBannerFiles(context.Background(), subDir, "<svg/>", rasters, pngOnly, encodeOpts))
+
+		for name, expected := range map[string]string{"banner.png": "1x", "banner@2x.png": "2x", "banner@1.5x.png": "1.5x"} {
+			content, err := os.ReadFile(filepath.Join(subDir, name))
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(content))
+		}
+	})
+
+	t.Run("write one PDF", func(t *testing.T) {
+		subDir := filepath.Join(tempDir, "pdf")
+		require.NoError(t, os.MkdirAll(subDir, 0755))
+
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 32, 12))))
+		rasters := []Raster{
+			{Scale: 2, PNG: buf.Bytes(), Renderer: "test"},
+			{Scale: 1, PNG: buf.Bytes(), Renderer: "test"},
+		}
+		svg := `<svg xmlns="http://www.w3.org/2000/svg" width="32" height="12"/>`
+		require.NoError(t, writeBannerFiles(context.Background(), subDir, svg, rasters, []string{"png", "pdf"}, encodeOpts))
+
+		assert.FileExists(t, filepath.Join(subDir, "banner.pdf"))
+		assert.NoFileExists(t, filepath.Join(subDir, "banner@2x.pdf"))
+		assert.FileExists(t, filepath.Join(subDir, "banner@2x.png"))
+
+		// Without rasters, e.g. after a failed render, the PDF still comes
+		// from the SVG where rsvg-convert is installed.
+		require.NoError(t, os.Remove(filepath.Join(subDir, "banner.pdf")))
+		require.NoError(t, writeBannerFiles(context.Background(), subDir, svg, nil, []string{"pdf"}, encodeOpts))
+		_, err := os.Stat(filepath.Join(subDir, "banner.pdf"))
+		assert.Equal(t, (rsvgConvertRenderer{}).Available(), err == nil)
+	})
+
+	t.Run("write every format", func(t *testing.T) {
+		subDir := filepath.Join(tempDir, "formats")
+		require.NoError(t, os.MkdirAll(subDir, 0755))
+
+		var small, large bytes.Buffer
+		require.NoError(t, png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 32, 12))))
+		require.NoError(t, png.Encode(&large, testImage(64, 24)))
+		rasters := []Raster{
+			{Scale: 1, PNG: small.Bytes(), Renderer: "test"},
+			{Scale: 2, PNG: large.Bytes(), Renderer: "test"},
+		}
+		formats := []string{"png", "webp", "jpeg", "gif", "ico"}
+		require.NoError(t, writeBannerFiles(context.Background(), subDir, "<svg/>", rasters, formats, encodeOpts))
+
+		for _, name := range []string{"banner.png", "banner.webp", "banner.jpg", "banner.gif", "banner.ico", "banner@2x.webp"} {
+			info, err := os.Stat(filepath.Join(subDir, name))
+			require.NoError(t, err, name)
+			assert.Greater(t, info.Size(), int64(0), name)
+		}
+
+		// The ICO holds its own sizes, so it is written once, from the
+		// largest raster.
+		assert.NoFileExists(t, filepath.Join(subDir, "banner@2x.ico"))
+		ico, err := os.ReadFile(filepath.Join(subDir, "banner.ico"))
+		require.NoError(t, err)
+		want, err := icoFormat{}.Encode(context.Background(), &EncodeSource{Raster: rasters[1]}, encodeOpts)
+		require.NoError(t, err)
+		assert.Equal(t, want, ico)
+	})
+
+	t.Run("optimized PNG", func(t *testing.T) {
+		subDir := filepath.Join(tempDir, "optimized")
+		require.NoError(t, os.MkdirAll(subDir, 0755))
+
+		var buf bytes.Buffer
+		require.NoError(t, (&png.Encoder{CompressionLevel: png.NoCompression}).Encode(&buf, image.NewRGBA(image.Rect(0, 0, 32, 12))))
+		rasters := []Raster{{Scale: 1, PNG: buf.Bytes(), Renderer: "test"}}
+		opts := EncodeOptions{Quality: defaultQuality, OptimizePNG: true}
+		require.NoError(t, writeBannerFiles(context.Background(), subDir, "<svg/>", rasters, pngOnly, opts))
+
+		data, err := os.ReadFile(filepath.Join(subDir, "banner.png"))
+		require.NoError(t, err)
+		assert.Less(t, len(data), buf.Len())
+	})
+
+	t.Run("undecodable raster", func(t *testing.T) {
+		subDir := filepath.Join(tempDir, "undecodable")
+		require.NoError(t, os.MkdirAll(subDir, 0755))
+
+		rasters := []Raster{{Scale: 1, PNG: []byte("not a png"), Renderer: "test"}}
+		err := writeBannerFiles(context.Background(), subDir, "<svg/>", rasters, []string{"webp"}, encodeOpts)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to decode rendered PNG")
+	})
+
 	t.Run("write to non-existent directory", func(t *testing.T) {
 		nonExistentDir := filepath.Join(tempDir, "does-not-exist")
 		svg := "<svg>test</svg>"
 
-		err := writeBannerFiles(nonExistentDir, svg, nil)
+		err := writeBannerFiles(context.Background(), nonExistentDir, svg, nil, pngOnly, encodeOpts)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to write SVG")
 	})
@@ -245,7 +437,7 @@ func TestWriteBannerFiles(t *testing.T) {
 		err := os.MkdirAll(subDir, 0755)
 		require.NoError(t, err)
 
-		err = writeBannerFiles(subDir, "", nil)
+		err = writeBannerFiles(context.Background(), subDir, "", nil, pngOnly, encodeOpts)
 		require.NoError(t, err)
 
 		svgPath := filepath.Join(subDir, "banner.svg")
@@ -253,6 +445,47 @@ func TestWriteBannerFiles(t *testing.T) {
 		require.NoError(t, err)
 		assert.Empty(t, content)
 	})
+
+	t.Run("canceled run leaves existing files", func(t *testing.T) {
+		subDir := filepath.Join(tempDir, "canceled")
+		require.NoError(t, os.MkdirAll(subDir, 0755))
+		svgPath := filepath.Join(subDir, "banner.svg")
+		require.NoError(t, os.WriteFile(svgPath, []byte("previous"), 0644))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		rasters := []Raster{{Scale: 1, PNG: []byte("png"), Renderer: "test"}}
+		err := writeBannerFiles(ctx, subDir, "<svg/>", rasters, pngOnly, encodeOpts)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		content, err := os.ReadFile(svgPath)
+		require.NoError(t, err)
+		assert.Equal(t, "previous", string(content))
+		_, err = os.Stat(filepath.Join(subDir, "banner.png"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "banner.png")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0600))
+
+	require.NoError(t, writeFileAtomic(path, []byte("new"), 0644))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.Error(t, writeFileAtomic(filepath.Join(dir, "missing", "banner.png"), []byte("x"), 0644))
 }
 
 func TestConvertWithRsvgConvert(t *testing.T) {
@@ -260,7 +493,7 @@ func TestConvertWithRsvgConvert(t *testing.T) {
 		<rect width="100" height="100" fill="blue"/>
 	</svg>`)
 
-	png, err := convertWithRsvgConvert(simpleSVG)
+	png, err := convertWithRsvgConvert(context.Background(), simpleSVG, RenderOptions{})
 
 	if err != nil {
 		if strings.Contains(err.Error(), "executable file not found") {
@@ -284,7 +517,7 @@ func TestConvertWithResvg(t *testing.T) {
 		<rect width="100" height="100" fill="green"/>
 	</svg>`)
 
-	png, err := convertWithResvg(simpleSVG)
+	png, err := convertWithResvg(context.Background(), simpleSVG, RenderOptions{})
 
 	if err != nil {
 		t.Logf("resvg conversion error (may be environment-specific): %v", err)
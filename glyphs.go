@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// glyphSlots are the text slots whose characters are checked before
+// rendering.
+var glyphSlots = []string{"title", "tagline", "badge-1", "badge-2", "badge-3"}
+
+// glyphIssue is a character of a text slot that no font in the slot's
+// font-family list can draw, which renders as a tofu box.
+type glyphIssue struct {
+	Slot string
+	// Position is the 1-based character position in the slot text.
+	Position int
+	Rune     rune
+	// Fallback is the family added to the slot's font-family list, or
+	// empty when no configured font has the glyph.
+	Fallback string
+}
+
+func (i glyphIssue) String() string {
+	char := fmt.Sprintf("U+%04X", i.Rune)
+	if unicode.IsGraphic(i.Rune) && !unicode.Is(unicode.Co, i.Rune) {
+		char += fmt.Sprintf(" %q", i.Rune)
+	}
+	if i.Fallback != "" {
+		return fmt.Sprintf("%s: %s at character %d is not in its fonts, falling back to %q", i.Slot, char, i.Position, i.Fallback)
+	}
+	return fmt.Sprintf("%s: %s at character %d is not in any configured font and renders as a box; add a font with -font-file or -font-dir", i.Slot, char, i.Position)
+}
+
+// fontCoverage records which of the checked characters a font family has.
+type fontCoverage struct {
+	family string
+	runes  map[rune]bool
+}
+
+// checkGlyphs looks up every character of the text slots in the cmap tables
+// of the configured fonts. A character missing from the fonts of its
+// font-family list is reported; when another configured font has it, that
+// font's family is added to the list so renderers pick it up.
+func checkGlyphs(svg string, fonts Fonts) (string, []glyphIssue, error) {
+	doc, err := parseSVG(svg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	type slot struct {
+		id       string
+		text     *SVGNode
+		runes    []rune
+		families []string
+	}
+	var slots []slot
+	needed := map[rune]bool{}
+	for _, id := range glyphSlots {
+		text := slotTextElement(doc, id)
+		if text == nil {
+			continue
+		}
+		runes := []rune(text.TextContent())
+		for _, r := range runes {
+			if drawnRune(r) {
+				needed[r] = true
+			}
+		}
+		slots = append(slots, slot{id: id, text: text, runes: runes, families: splitFontFamilies(inheritedAttr(text, "font-family"))})
+	}
+	if len(slots) == 0 {
+		return svg, nil, nil
+	}
+
+	// The embedded font is the fallback of every list and covers most
+	// text, so other fonts are only read for the characters it lacks.
+	embedded, err := embeddedFonts()
+	if err != nil {
+		return "", nil, err
+	}
+	var coverage []fontCoverage
+	for _, f := range embedded {
+		coverage = append(coverage, fontDataCoverage(f.data, needed)...)
+	}
+	missing := map[rune]bool{}
+	for r := range needed {
+		if !covered(coverage, r, []string{embeddedFontFamily}) {
+			missing[r] = true
+		}
+	}
+	if len(missing) == 0 {
+		return svg, nil, nil
+	}
+	coverage = append(configuredFontCoverage(fonts, missing), coverage...)
+
+	var issues []glyphIssue
+	changed := false
+	for _, s := range slots {
+		// Renderers fall back to the embedded font, which the template
+		// does not need to list.
+		listed := append([]string(nil), s.families...)
+		check := append(append([]string(nil), s.families...), embeddedFontFamily)
+		added := false
+		for i, r := range s.runes {
+			if !drawnRune(r) || covered(coverage, r, check) {
+				continue
+			}
+			issue := glyphIssue{Slot: s.id, Position: i + 1, Rune: r}
+			if fallback := covering(coverage, r); fallback != "" {
+				issue.Fallback = fallback
+				listed = append(listed, fallback)
+				check = append(check, fallback)
+				added = true
+			}
+			issues = append(issues, issue)
+		}
+		if added {
+			quoted := make([]string, len(listed))
+			for i, family := range listed {
+				quoted[i] = quoteFontFamily(family)
+			}
+			setPaint(s.text, "font-family", strings.Join(quoted, ", "))
+			changed = true
+		}
+	}
+
+	if !changed {
+		return svg, issues, nil
+	}
+	return doc.String(), issues, nil
+}
+
+// slotTextElement returns the <text> element of a slot, like setSlotText.
+func slotTextElement(doc *SVGDocument, id string) *SVGNode {
+	slot := doc.Root.FindByID(id)
+	if slot == nil || slot.Name == "text" || slot.Name == "tspan" {
+		return slot
+	}
+	if texts := slot.FindByName("text"); len(texts) > 0 {
+		return texts[0]
+	}
+	return nil
+}
+
+// inheritedAttr returns a presentation attribute of n or its closest
+// ancestor that sets it, as attribute or style property.
+func inheritedAttr(n *SVGNode, name string) string {
+	for ; n != nil; n = n.Parent {
+		if style, ok := n.Attr("style"); ok {
+			for _, decl := range strings.Split(style, ";") {
+				property, value, found := strings.Cut(decl, ":")
+				if found && strings.TrimSpace(property) == name {
+					return strings.TrimSpace(value)
+				}
+			}
+		}
+		if value, ok := n.Attr(name); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// drawnRune reports whether r needs a glyph. Spaces, controls, variation
+// selectors and joiners are not drawn on their own.
+func drawnRune(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsControl(r) &&
+		!unicode.Is(unicode.Variation_Selector, r) && !unicode.Is(unicode.Join_Control, r)
+}
+
+// covered reports whether a font of one of families has r. Nil families
+// accept any font.
+func covered(coverage []fontCoverage, r rune, families []string) bool {
+	for _, c := range coverage {
+		if c.runes[r] && (families == nil || containsFold(families, c.family)) {
+			return true
+		}
+	}
+	return false
+}
+
+// covering returns the family of the first font that has r.
+func covering(coverage []fontCoverage, r rune) string {
+	for _, c := range coverage {
+		if c.runes[r] {
+			return c.family
+		}
+	}
+	return ""
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// configuredFontCoverage reads the extra fonts, then the system fonts when
+// they are preferred. Both renderers load exactly these fonts besides the
+// embedded ones, so rsvg-convert does not see system fonts either unless
+// they are preferred. Files that are not fonts or cannot be parsed are
+// skipped.
+func configuredFontCoverage(fonts Fonts, runes map[rune]bool) []fontCoverage {
+	var coverage []fontCoverage
+	read := func(path string) {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			coverage = append(coverage, fontDataCoverage(data, runes)...)
+		}
+	}
+	walk := func(dir string) {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && isFontFile(path) {
+				read(path)
+			}
+			return nil
+		})
+	}
+
+	for _, file := range fonts.Files {
+		read(file)
+	}
+	for _, dir := range fonts.Dirs {
+		walk(dir)
+	}
+	if fonts.System {
+		for _, dir := range systemFontDirs() {
+			walk(dir)
+		}
+	}
+	return coverage
+}
+
+func isFontFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ttf", ".otf", ".ttc", ".otc":
+		return true
+	}
+	return false
+}
+
+// fontDataCoverage returns, for every font in a font file or collection, the
+// subset of runes it has a glyph for.
+func fontDataCoverage(data []byte, runes map[rune]bool) []fontCoverage {
+	collection, err := sfnt.ParseCollection(data)
+	if err != nil {
+		return nil
+	}
+	var coverage []fontCoverage
+	var buf sfnt.Buffer
+	for i := 0; i < collection.NumFonts(); i++ {
+		f, err := collection.Font(i)
+		if err != nil {
+			continue
+		}
+		family, err := f.Name(&buf, sfnt.NameIDTypographicFamily)
+		if err != nil || family == "" {
+			if family, err = f.Name(&buf, sfnt.NameIDFamily); err != nil {
+				continue
+			}
+		}
+		c := fontCoverage{family: family, runes: map[rune]bool{}}
+		for r := range runes {
+			if glyph, err := f.GlyphIndex(&buf, r); err == nil && glyph != 0 {
+				c.runes[r] = true
+			}
+		}
+		coverage = append(coverage, c)
+	}
+	return coverage
+}
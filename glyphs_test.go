@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckGlyphs(t *testing.T) {
+	const mono = "/usr/share/fonts/truetype/dejavu/DejaVuSansMono.ttf"
+	if _, err := os.Stat(mono); err != nil {
+		t.Skip("DejaVu Sans Mono is not installed")
+	}
+	theme, err := getTheme("light")
+	require.NoError(t, err)
+	banner := func(t *testing.T, title string, badges ...string) string {
+		t.Helper()
+		svg, err := generateSVG(&Metadata{Name: title, Tagline: "Tagline"}, theme, "center", badges, defaultCanvas)
+		require.NoError(t, err)
+		return svg
+	}
+
+	t.Run("covered text is unchanged", func(t *testing.T) {
+		svg := banner(t, "Café ⚡ 100%")
+		out, issues, err := checkGlyphs(svg, Fonts{})
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+		assert.Equal(t, svg, out)
+	})
+
+	t.Run("missing glyph is reported with its position", func(t *testing.T) {
+		svg := banner(t, "Go \uf09b", "v1")
+		out, issues, err := checkGlyphs(svg, Fonts{})
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, glyphIssue{Slot: "title", Position: 4, Rune: 0xf09b}, issues[0])
+		assert.Contains(t, issues[0].String(), "title: U+F09B at character 4 is not in any configured font")
+		assert.Equal(t, svg, out)
+	})
+
+	t.Run("fallback font is added to the list", func(t *testing.T) {
+		svg := banner(t, "Zilde", "APL ⍬")
+		out, issues, err := checkGlyphs(svg, Fonts{Files: []string{mono}})
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, glyphIssue{Slot: "badge-1", Position: 5, Rune: '⍬', Fallback: "DejaVu Sans Mono"}, issues[0])
+		assert.Contains(t, issues[0].String(), `falling back to "DejaVu Sans Mono"`)
+
+		doc, err := parseSVG(out)
+		require.NoError(t, err)
+		badge, _ := slotTextElement(doc, "badge-1").Attr("font-family")
+		assert.Equal(t, "'Hack Nerd Font', 'HackNerdFont', 'DejaVu Sans', 'Arial', 'DejaVu Sans Mono'", badge)
+		title, _ := slotTextElement(doc, "title").Attr("font-family")
+		assert.NotContains(t, title, "Mono")
+	})
+
+	t.Run("font directories and system fonts are searched", func(t *testing.T) {
+		// U+1D25 is only in DejaVu Serif.
+		svg := banner(t, "\u1d25")
+		_, issues, err := checkGlyphs(svg, Fonts{Dirs: []string{filepath.Dir(mono)}})
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, "DejaVu Serif", issues[0].Fallback)
+
+		// Neither renderer loads the system fonts unless they are
+		// preferred, so they only count then.
+		_, issues, err = checkGlyphs(svg, Fonts{})
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Empty(t, issues[0].Fallback)
+
+		_, issues, err = checkGlyphs(svg, Fonts{System: true})
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, "DejaVu Serif", issues[0].Fallback)
+	})
+
+	t.Run("embedded monospace font is a fallback", func(t *testing.T) {
+		_, issues, err := checkGlyphs(banner(t, "⍬"), Fonts{})
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, embeddedMonoFamily, issues[0].Fallback)
+	})
+
+	t.Run("templates without slots", func(t *testing.T) {
+		svg := `<svg xmlns="http://www.w3.org/2000/svg"><text>` + "\uf09b" + `</text></svg>`
+		out, issues, err := checkGlyphs(svg, Fonts{})
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+		assert.Equal(t, svg, out)
+	})
+}
+
+func TestDrawnRune(t *testing.T) {
+	for _, r := range []rune{' ', '\t', '\u200d', '\ufe0f'} {
+		assert.False(t, drawnRune(r), "%U", r)
+	}
+	for _, r := range []rune{'a', '⚡', '\uf09b'} {
+		assert.True(t, drawnRune(r), "%U", r)
+	}
+}
@@ -1,15 +1,17 @@
 module github.com/chhlga/banner-kit-go
 
-go 1.21
+go 1.22.2
 
 require (
-	github.com/kanrichan/resvg-go v0.0.1
+	github.com/HugoSmits86/nativewebp v1.2.1
 	github.com/stretchr/testify v1.11.1
+	github.com/tetratelabs/wazero v1.4.0
+	golang.org/x/image v0.24.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/tetratelabs/wazero v1.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/text v0.22.0 // indirect
 )
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// gradientTypes are the theme background gradients. SVG has no conic
+// gradient, so conic ones are drawn as a pattern of thin wedges.
+var gradientTypes = []string{"linear", "radial", "conic"}
+
+// conicWedges is the number of wedges a conic gradient is drawn with, each
+// three degrees wide.
+const conicWedges = 120
+
+// Gradient is a theme's background: any number of color stops along a
+// linear, radial or conic gradient.
+type Gradient struct {
+	Type string `yaml:"type"`
+	// Angle is in degrees, clockwise from the top, as in CSS: the direction
+	// of a linear gradient, in the canvas's own proportions so 135 runs
+	// corner to corner, or where a conic gradient starts. Radial gradients
+	// ignore it.
+	Angle float64        `yaml:"angle"`
+	Stops []GradientStop `yaml:"stops"`
+}
+
+// GradientStop is a color at an offset from 0 to 100 percent along the
+// gradient.
+type GradientStop struct {
+	Color  string
+	Offset float64
+}
+
+// UnmarshalYAML reads a stop written like CSS, "#E40303 20%". Stops
+// without an offset are spread evenly between their neighbours.
+func (s *GradientStop) UnmarshalYAML(value *yaml.Node) error {
+	var text string
+	if err := value.Decode(&text); err != nil {
+		return err
+	}
+	stop, err := parseGradientStop(text)
+	if err != nil {
+		return err
+	}
+	*s = stop
+	return nil
+}
+
+func parseGradientStop(s string) (GradientStop, error) {
+	fields := strings.Fields(s)
+	// rgb() colors may contain spaces, so the offset is the last field.
+	offset := -1.0
+	if n := len(fields); n > 1 && strings.HasSuffix(fields[n-1], "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(fields[n-1], "%"), 64)
+		if err != nil {
+			return GradientStop{}, fmt.Errorf("invalid gradient stop %q: %w", s, err)
+		}
+		offset = v
+		fields = fields[:n-1]
+	}
+	stop := GradientStop{Color: strings.Join(fields, " "), Offset: offset}
+	if _, ok := parseColor(stop.Color); !ok {
+		return GradientStop{}, fmt.Errorf("invalid gradient stop %q: use a hex or rgb() color and an optional offset such as 50%%", s)
+	}
+	return stop, nil
+}
+
+// normalize defaults the type to linear and places stops without an offset:
+// the first at 0%, the last at 100% and the others evenly in between.
+func (g *Gradient) normalize() {
+	if g.Type == "" {
+		g.Type = "linear"
+	}
+	n := len(g.Stops)
+	if n == 0 {
+		return
+	}
+	if g.Stops[0].Offset < 0 {
+		g.Stops[0].Offset = 0
+	}
+	if g.Stops[n-1].Offset < 0 {
+		g.Stops[n-1].Offset = 100
+	}
+	for i := 1; i < n; {
+		if g.Stops[i].Offset >= 0 {
+			i++
+			continue
+		}
+		j := i
+		for g.Stops[j].Offset < 0 {
+			j++
+		}
+		from, to := g.Stops[i-1].Offset, g.Stops[j].Offset
+		for k := i; k < j; k++ {
+			g.Stops[k].Offset = from + (to-from)*float64(k-i+1)/float64(j-i+1)
+		}
+		i = j
+	}
+}
+
+func (g Gradient) validate() error {
+	known := false
+	for _, t := range gradientTypes {
+		known = known || g.Type == t
+	}
+	if !known {
+		return fmt.Errorf("unknown gradient type %q. Use: %s", g.Type, strings.Join(gradientTypes, ", "))
+	}
+	if len(g.Stops) < 2 {
+		return fmt.Errorf("a gradient needs at least 2 stops")
+	}
+	for i, stop := range g.Stops {
+		if _, ok := parseColor(stop.Color); !ok {
+			return fmt.Errorf("invalid gradient color %q", stop.Color)
+		}
+		if stop.Offset < 0 || stop.Offset > 100 {
+			return fmt.Errorf("gradient stop offset %s%% is not between 0%% and 100%%", formatNumber(stop.Offset))
+		}
+		if i > 0 && stop.Offset < g.Stops[i-1].Offset {
+			return fmt.Errorf("gradient stop offsets must not decrease: %s%% follows %s%%", formatNumber(stop.Offset), formatNumber(g.Stops[i-1].Offset))
+		}
+	}
+	return nil
+}
+
+// colorAt interpolates the gradient's color at offset percent.
+func (g Gradient) colorAt(offset float64) color.NRGBA {
+	first, _ := parseColor(g.Stops[0].Color)
+	if offset <= g.Stops[0].Offset {
+		return first
+	}
+	for i := 1; i < len(g.Stops); i++ {
+		if offset > g.Stops[i].Offset {
+			continue
+		}
+		a, _ := parseColor(g.Stops[i-1].Color)
+		b, _ := parseColor(g.Stops[i].Color)
+		t := (offset - g.Stops[i-1].Offset) / (g.Stops[i].Offset - g.Stops[i-1].Offset)
+		mix := func(x, y uint8) uint8 {
+			return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t))
+		}
+		return color.NRGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 255}
+	}
+	last, _ := parseColor(g.Stops[len(g.Stops)-1].Color)
+	return last
+}
+
+// gradientNode returns the element drawing g, with id, over a canvas of
+// l's size: a <linearGradient> or <radialGradient>, or a <pattern> of
+// wedges for a conic gradient.
+func gradientNode(id string, g Gradient, l Layout) *SVGNode {
+	switch g.Type {
+	case "radial":
+		// In bounding box units the circle becomes an ellipse that
+		// reaches the corners of the canvas.
+		node := svgElement("radialGradient", "id", id, "cx", "0.5", "cy", "0.5", "r", formatNumber(math.Sqrt2/2))
+		appendStops(node, g.Stops)
+		return node
+	case "conic":
+		return conicPattern(id, g, l)
+	}
+	// The gradient line of CSS: through the center, long enough that the
+	// corners reach the first and last stops.
+	dx, dy := math.Sin(g.Angle*math.Pi/180), -math.Cos(g.Angle*math.Pi/180)
+	half := (math.Abs(dx) + math.Abs(dy)) / 2
+	node := svgElement("linearGradient", "id", id,
+		"x1", formatNumber(0.5-dx*half), "y1", formatNumber(0.5-dy*half),
+		"x2", formatNumber(0.5+dx*half), "y2", formatNumber(0.5+dy*half))
+	appendStops(node, g.Stops)
+	return node
+}
+
+func appendStops(node *SVGNode, stops []GradientStop) {
+	for _, stop := range stops {
+		node.AppendChild(svgElement("stop", "offset", formatNumber(stop.Offset)+"%", "stop-color", stop.Color))
+	}
+}
+
+// conicPattern draws a conic gradient as wedges around the center of the
+// canvas, each filled with the color at its middle. Wedges overlap the next
+// one slightly so no seams show, and neighbours of the same color are
+// merged.
+func conicPattern(id string, g Gradient, l Layout) *SVGNode {
+	cx, cy := l.Width/2, l.Height/2
+	// Twice the distance to the corners, so even the chord across a
+	// quarter turn lies outside the canvas.
+	r := math.Hypot(l.Width, l.Height)
+	point := func(turn float64) string {
+		a := (g.Angle + 360*turn) * math.Pi / 180
+		return formatNumber(cx+r*math.Sin(a)) + " " + formatNumber(cy-r*math.Cos(a))
+	}
+
+	pattern := svgElement("pattern", "id", id, "patternUnits", "userSpaceOnUse", "width", formatNumber(l.Width), "height", formatNumber(l.Height))
+	overlap := 0.5 / 360
+	for start := 0; start < conicWedges; {
+		fill := hexColor(g.colorAt((float64(start) + 0.5) * 100 / conicWedges))
+		end := start + 1
+		for end < conicWedges && hexColor(g.colorAt((float64(end)+0.5)*100/conicWedges)) == fill {
+			end++
+		}
+		from := float64(start) / conicWedges
+		to := float64(end)/conicWedges + overlap
+		var d strings.Builder
+		fmt.Fprintf(&d, "M%s %sL%s", formatNumber(cx), formatNumber(cy), point(from))
+		// Wedges wider than a quarter turn get corners so they still
+		// cover the canvas.
+		for corner := math.Floor(from*4) + 1; corner/4 < to; corner++ {
+			fmt.Fprintf(&d, "L%s", point(corner/4))
+		}
+		fmt.Fprintf(&d, "L%sZ", point(to))
+		pattern.AppendChild(svgElement("path", "d", d.String(), "fill", fill))
+		start = end
+	}
+	return pattern
+}
+
+// setGradientSlot draws the theme's background and wave gradients in the
+// template's theme-gradients slot, as bgGradient and waveGradient.
+func setGradientSlot(doc *SVGDocument, theme *ThemePalette, l Layout) {
+	slot := doc.Root.FindByID("theme-gradients")
+	if slot == nil {
+		return
+	}
+	slot.AppendChild(gradientNode("bgGradient", theme.Background, l))
+
+	waves := svgElement("linearGradient", "id", "waveGradient", "x1", "0", "y1", "0", "x2", "1", "y2", "0")
+	waves.AppendChild(svgElement("stop", "offset", "0%", "stop-color", theme.WAVE0, "stop-opacity", "0.35"))
+	waves.AppendChild(svgElement("stop", "offset", "100%", "stop-color", theme.WAVE1, "stop-opacity", "0.35"))
+	slot.AppendChild(waves)
+}
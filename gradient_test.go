@@ -0,0 +1,130 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGradientStop(t *testing.T) {
+	tests := []struct {
+		input string
+		want  GradientStop
+	}{
+		{"#E40303", GradientStop{Color: "#E40303", Offset: -1}},
+		{"#E40303 20%", GradientStop{Color: "#E40303", Offset: 20}},
+		{" rgb(228, 3, 3) 12.5% ", GradientStop{Color: "rgb(228, 3, 3)", Offset: 12.5}},
+	}
+	for _, tt := range tests {
+		stop, err := parseGradientStop(tt.input)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, stop, tt.input)
+	}
+
+	for _, input := range []string{"", "red", "#E40303 x%", "20%"} {
+		_, err := parseGradientStop(input)
+		assert.ErrorContains(t, err, "invalid gradient stop", input)
+	}
+}
+
+func TestGradientNormalize(t *testing.T) {
+	g := Gradient{Stops: []GradientStop{{"#000", -1}, {"#111", -1}, {"#222", 40}, {"#333", -1}, {"#444", -1}, {"#555", -1}}}
+	g.normalize()
+	assert.Equal(t, "linear", g.Type)
+	var offsets []float64
+	for _, stop := range g.Stops {
+		offsets = append(offsets, stop.Offset)
+	}
+	assert.Equal(t, []float64{0, 20, 40, 60, 80, 100}, offsets)
+}
+
+func TestGradientValidate(t *testing.T) {
+	stops := []GradientStop{{"#000", 0}, {"#FFF", 100}}
+	assert.NoError(t, Gradient{Type: "conic", Stops: stops}.validate())
+
+	tests := []struct {
+		gradient Gradient
+		err      string
+	}{
+		{Gradient{Type: "diamond", Stops: stops}, `unknown gradient type "diamond". Use: linear, radial, conic`},
+		{Gradient{Type: "linear", Stops: stops[:1]}, "at least 2 stops"},
+		{Gradient{Type: "linear", Stops: []GradientStop{{"#000", 0}, {"red", 100}}}, `invalid gradient color "red"`},
+		{Gradient{Type: "linear", Stops: []GradientStop{{"#000", 0}, {"#FFF", 120}}}, "offset 120% is not between 0% and 100%"},
+		{Gradient{Type: "linear", Stops: []GradientStop{{"#000", 60}, {"#FFF", 40}}}, "must not decrease: 40% follows 60%"},
+	}
+	for _, tt := range tests {
+		assert.ErrorContains(t, tt.gradient.validate(), tt.err)
+	}
+}
+
+func TestGradientColorAt(t *testing.T) {
+	g := Gradient{Stops: []GradientStop{{"#000000", 20}, {"#FF0000", 60}, {"#0000FF", 60}, {"#0000FF", 100}}}
+	assert.Equal(t, color.NRGBA{0, 0, 0, 255}, g.colorAt(0))
+	assert.Equal(t, color.NRGBA{128, 0, 0, 255}, g.colorAt(40))
+	assert.Equal(t, color.NRGBA{255, 0, 0, 255}, g.colorAt(60), "the first of two stops at an offset wins there")
+	assert.Equal(t, color.NRGBA{0, 0, 255, 255}, g.colorAt(61))
+	assert.Equal(t, color.NRGBA{0, 0, 255, 255}, g.colorAt(100))
+}
+
+func TestGradientNode(t *testing.T) {
+	l := computeLayout(defaultCanvas, "center")
+	stops := []GradientStop{{"#000000", 0}, {"#FFFFFF", 100}}
+	render := func(g Gradient) string {
+		return (&SVGDocument{Root: gradientNode("bg", g, l)}).String()
+	}
+
+	t.Run("linear angles", func(t *testing.T) {
+		tests := map[float64]string{
+			135: `x1="0" y1="0" x2="1" y2="1"`,
+			90:  `x1="0" y1="0.5" x2="1" y2="0.5"`,
+			180: `x1="0.5" y1="0" x2="0.5" y2="1"`,
+			0:   `x1="0.5" y1="1" x2="0.5" y2="0"`,
+			225: `x1="1" y1="0" x2="0" y2="1"`,
+		}
+		for angle, coords := range tests {
+			assert.Contains(t, render(Gradient{Type: "linear", Angle: angle, Stops: stops}), `<linearGradient id="bg" `+coords+`><stop offset="0%" stop-color="#000000"/><stop offset="100%" stop-color="#FFFFFF"/></linearGradient>`)
+		}
+	})
+
+	t.Run("radial", func(t *testing.T) {
+		assert.Contains(t, render(Gradient{Type: "radial", Angle: 90, Stops: stops}), `<radialGradient id="bg" cx="0.5" cy="0.5" r="0.71"><stop offset="0%"`)
+	})
+
+	t.Run("conic wedges cover a full turn", func(t *testing.T) {
+		node := gradientNode("bg", Gradient{Type: "conic", Stops: stops}, l)
+		assert.Equal(t, "pattern", node.Name)
+		width, _ := node.Attr("width")
+		assert.Equal(t, "1600", width)
+		require.Len(t, node.Children, conicWedges)
+		first, _ := node.Children[0].Attr("fill")
+		last, _ := node.Children[conicWedges-1].Attr("fill")
+		assert.Equal(t, "#010101", first)
+		assert.Equal(t, "#FEFEFE", last)
+		d, _ := node.Children[0].Attr("d")
+		assert.True(t, strings.HasPrefix(d, "M800 300L800 -1408.8L"), d)
+	})
+
+	t.Run("conic wedges of one color are merged", func(t *testing.T) {
+		node := gradientNode("bg", Gradient{Type: "conic", Angle: 90, Stops: []GradientStop{{"#FF0000", 0}, {"#FF0000", 50}, {"#0000FF", 50}, {"#0000FF", 100}}}, l)
+		require.Len(t, node.Children, 2)
+		// Each half turn gets its corners, so the halves still cover the
+		// canvas.
+		d, _ := node.Children[0].Attr("d")
+		assert.Equal(t, "M800 300L2508.8 300L800 2008.8L-908.8 300L-908.74 285.09Z", d)
+		fill, _ := node.Children[1].Attr("fill")
+		assert.Equal(t, "#0000FF", fill)
+	})
+}
+
+func TestSetGradientSlot(t *testing.T) {
+	theme, err := getTheme("pride")
+	require.NoError(t, err)
+
+	svg, err := generateSVG(&Metadata{Name: "Kit"}, theme, "center", nil, defaultCanvas)
+	require.NoError(t, err)
+	assert.Contains(t, svg, `<defs id="theme-gradients"><linearGradient id="bgGradient" x1="0.5" y1="0" x2="0.5" y2="1"><stop offset="0%" stop-color="#E40303"/><stop offset="20%" stop-color="#A85C00"/>`)
+	assert.Contains(t, svg, `<stop offset="100%" stop-color="#732982"/></linearGradient><linearGradient id="waveGradient" x1="0" y1="0" x2="1" y2="0"><stop offset="0%" stop-color="#F58600" stop-opacity="0.35"/><stop offset="100%" stop-color="#A63BBC" stop-opacity="0.35"/></linearGradient></defs>`)
+}
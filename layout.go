@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Canvas is the size of the generated banner in pixels.
+type Canvas struct {
+	Width  int
+	Height int
+}
+
+// defaultCanvas is the size the built-in templates were designed at. All
+// layout proportions are derived from it.
+var defaultCanvas = Canvas{Width: 1600, Height: 600}
+
+func parseCanvas(s string) (Canvas, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "x")
+	if len(parts) != 2 {
+		return Canvas{}, fmt.Errorf("invalid size %q. Use WIDTHxHEIGHT, e.g. 1200x400", s)
+	}
+
+	width, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Canvas{}, fmt.Errorf("invalid width in size %q: %w", s, err)
+	}
+	height, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Canvas{}, fmt.Errorf("invalid height in size %q: %w", s, err)
+	}
+
+	canvas := Canvas{Width: width, Height: height}
+	if err := canvas.validate(); err != nil {
+		return Canvas{}, err
+	}
+	return canvas, nil
+}
+
+func (c Canvas) validate() error {
+	if c.Width <= 0 || c.Height <= 0 {
+		return fmt.Errorf("invalid canvas size %dx%d: width and height must be positive", c.Width, c.Height)
+	}
+	return nil
+}
+
+func (c Canvas) String() string {
+	return fmt.Sprintf("%dx%d", c.Width, c.Height)
+}
+
+// Layout holds the computed coordinates of every positioned element in a
+// banner template.
+type Layout struct {
+	Width  float64
+	Height float64
+
+	CardX  float64
+	CardY  float64
+	CardW  float64
+	CardH  float64
+	CardRX float64
+
+	BadgeX        [3]float64
+	BadgeY        float64
+	BadgeW        float64
+	BadgeH        float64
+	BadgeRX       float64
+	BadgeTextY    float64
+	BadgeFontSize float64
+
+	TextX           float64
+	TextAnchor      string
+	TitleY          float64
+	TitleFontSize   float64
+	TaglineY        float64
+	TaglineFontSize float64
+	// TextMaxW and BadgeTextMaxW are the widths text must fit in.
+	TextMaxW      float64
+	BadgeTextMaxW float64
+
+	// LogoX, LogoY, LogoW and LogoH are the box of the project logo, all
+	// zero when there is none.
+	LogoX float64
+	LogoY float64
+	LogoW float64
+	LogoH float64
+
+	StrokeWidth float64
+	// WavePath and WaveLine are the seeded waves, set by renderTemplate.
+	WavePath string
+	WaveLine string
+}
+
+// computeLayout positions the card, badges and text for the given canvas.
+// Horizontal positions follow the canvas width, while sizes scale with the
+// smaller of the two axes so text never overflows a short or narrow banner.
+// Content is vertically centered so tall canvases stay balanced.
+func computeLayout(canvas Canvas, align string) Layout {
+	w := float64(canvas.Width)
+	h := float64(canvas.Height)
+	s := math.Min(w/float64(defaultCanvas.Width), h/float64(defaultCanvas.Height))
+
+	l := Layout{
+		Width:           w,
+		Height:          h,
+		CardH:           300 * s,
+		CardRX:          44 * s,
+		BadgeH:          54 * s,
+		BadgeRX:         18 * s,
+		BadgeFontSize:   26 * s,
+		TitleFontSize:   88 * s,
+		TaglineFontSize: 36 * s,
+		StrokeWidth:     6 * s,
+	}
+
+	padding := 60 * s
+	gap := 20 * s
+	l.CardW = w * 0.775
+	l.BadgeW = 240 * s
+	if align == "center" {
+		l.CardW = w * 0.7
+		l.BadgeW = 220 * s
+	}
+	l.TextMaxW = l.CardW - 2*padding
+	l.BadgeTextMaxW = l.BadgeW - 2*gap
+	l.CardX = (w - l.CardW) / 2
+	l.CardY = (h - l.CardH) / 2
+
+	l.BadgeY = l.CardY + 25*s
+	l.BadgeTextY = l.CardY + 62*s
+	l.TitleY = l.CardY + 175*s
+	l.TaglineY = l.CardY + 245*s
+
+	badgesW := 3*l.BadgeW + 2*gap
+	var badgesX float64
+	switch align {
+	case "left":
+		badgesX = l.CardX + padding
+		l.TextX = l.CardX + padding
+		l.TextAnchor = "start"
+	case "right":
+		badgesX = l.CardX + l.CardW - padding - badgesW
+		l.TextX = l.CardX + l.CardW - padding
+		l.TextAnchor = "end"
+	default:
+		badgesX = (w - badgesW) / 2
+		l.TextX = w / 2
+		l.TextAnchor = "middle"
+	}
+	for i := range l.BadgeX {
+		l.BadgeX[i] = badgesX + float64(i)*(l.BadgeW+gap)
+	}
+
+	return l
+}
+
+// fitText shrinks the title, tagline and badge font sizes so the text stays
+// inside the card and badges. Text is measured with the embedded fonts. All
+// badges share one size so they stay uniform.
+func (l *Layout) fitText(title, tagline []inlineSpan, badges []string) {
+	l.TitleFontSize = fitSpansSize(title, l.TitleFontSize, l.TextMaxW, true)
+	l.TaglineFontSize = fitSpansSize(tagline, l.TaglineFontSize, l.TextMaxW, false)
+	size := l.BadgeFontSize
+	for _, badge := range badges[:min(len(badges), len(l.BadgeX))] {
+		size = math.Min(size, fitFontSize(badge, l.BadgeFontSize, l.BadgeTextMaxW, true))
+	}
+	l.BadgeFontSize = size
+}
+
+// logoGap is the space between the logo and the text at the default canvas.
+const logoGap = 32
+
+// scale is the factor from the default canvas to the layout's sizes.
+func (l Layout) scale() float64 {
+	return math.Min(l.Width/float64(defaultCanvas.Width), l.Height/float64(defaultCanvas.Height))
+}
+
+// reserveLogo sizes a logo with the given width to height ratio, centered
+// on the title and tagline, and takes its room from the text width. Wide
+// logos are limited to twice their height. placeLogo positions it once the
+// text is fitted.
+func (l *Layout) reserveLogo(aspect float64) {
+	s := l.scale()
+	l.LogoH = 130 * s
+	l.LogoW = l.LogoH * aspect
+	if maxW := 2 * l.LogoH; l.LogoW > maxW {
+		l.LogoW = maxW
+		l.LogoH = maxW / aspect
+	}
+	l.LogoY = l.CardY + 178*s - l.LogoH/2
+	l.TextMaxW -= l.LogoW + logoGap*s
+}
+
+// placeLogo puts the reserved logo left of the text, or right of it for
+// right aligned text, and moves the text next to it. Centered text and
+// logo are centered together, so the text is measured at its fitted size.
+func (l *Layout) placeLogo(title, tagline []inlineSpan) {
+	if l.LogoW == 0 {
+		return
+	}
+	gap := logoGap * l.scale()
+	switch l.TextAnchor {
+	case "start":
+		l.LogoX = l.TextX
+		l.TextX += l.LogoW + gap
+	case "end":
+		l.LogoX = l.TextX - l.LogoW
+		l.TextX -= l.LogoW + gap
+	default:
+		titleW, _ := measureSpans(title, l.TitleFontSize, true)
+		taglineW, _ := measureSpans(tagline, l.TaglineFontSize, false)
+		textW := math.Max(titleW, taglineW)
+		l.LogoX = (l.Width - l.LogoW - gap - textW) / 2
+		l.TextX = l.LogoX + l.LogoW + gap + textW/2
+	}
+}
+
+// scalePath scales the absolute coordinates of a path made of M, C and L
+// commands. Coordinates are expected as "x y" pairs.
+func scalePath(d string, sx, sy float64) string {
+	var b strings.Builder
+	b.Grow(len(d))
+
+	axis := 0
+	i := 0
+	for i < len(d) {
+		c := d[i]
+		if c == '-' || c == '.' || (c >= '0' && c <= '9') {
+			j := i + 1
+			for j < len(d) && (d[j] == '.' || (d[j] >= '0' && d[j] <= '9')) {
+				j++
+			}
+			v, _ := strconv.ParseFloat(d[i:j], 64)
+			if axis == 0 {
+				v *= sx
+			} else {
+				v *= sy
+			}
+			axis = 1 - axis
+			b.WriteString(formatNumber(v))
+			i = j
+			continue
+		}
+		if c >= 'A' && c <= 'Z' {
+			axis = 0
+		}
+		b.WriteByte(c)
+		i++
+	}
+
+	return b.String()
+}
+
+// formatNumber renders a coordinate with at most two decimals and without
+// trailing zeros, so the default canvas produces integer coordinates.
+func formatNumber(v float64) string {
+	v = math.Round(v*100) / 100
+	if v == 0 {
+		v = 0 // normalize -0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func (l Layout) vars() map[string]string {
+	vars := map[string]string{
+		"WIDTH":             formatNumber(l.Width),
+		"HEIGHT":            formatNumber(l.Height),
+		"CARD_X":            formatNumber(l.CardX),
+		"CARD_Y":            formatNumber(l.CardY),
+		"CARD_W":            formatNumber(l.CardW),
+		"CARD_H":            formatNumber(l.CardH),
+		"CARD_RX":           formatNumber(l.CardRX),
+		"BADGE_Y":           formatNumber(l.BadgeY),
+		"BADGE_W":           formatNumber(l.BadgeW),
+		"BADGE_H":           formatNumber(l.BadgeH),
+		"BADGE_RX":          formatNumber(l.BadgeRX),
+		"BADGE_TEXT_Y":      formatNumber(l.BadgeTextY),
+		"BADGE_FONT_SIZE":   formatNumber(l.BadgeFontSize),
+		"TEXT_X":            formatNumber(l.TextX),
+		"TEXT_ANCHOR":       l.TextAnchor,
+		"TITLE_Y":           formatNumber(l.TitleY),
+		"TITLE_FONT_SIZE":   formatNumber(l.TitleFontSize),
+		"TAGLINE_Y":         formatNumber(l.TaglineY),
+		"TAGLINE_FONT_SIZE": formatNumber(l.TaglineFontSize),
+		"LOGO_X":            formatNumber(l.LogoX),
+		"LOGO_Y":            formatNumber(l.LogoY),
+		"LOGO_W":            formatNumber(l.LogoW),
+		"LOGO_H":            formatNumber(l.LogoH),
+		"STROKE_WIDTH":      formatNumber(l.StrokeWidth),
+		"WAVE_PATH":         l.WavePath,
+		"WAVE_LINE":         l.WaveLine,
+	}
+
+	for i, x := range l.BadgeX {
+		vars[fmt.Sprintf("BADGE%d_X", i+1)] = formatNumber(x)
+		vars[fmt.Sprintf("BADGE%d_TEXT_X", i+1)] = formatNumber(x + l.BadgeW/2)
+	}
+
+	return vars
+}
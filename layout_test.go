@@ -0,0 +1,180 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCanvas(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    Canvas
+		expectError bool
+	}{
+		{
+			name:     "default size",
+			input:    "1600x600",
+			expected: Canvas{Width: 1600, Height: 600},
+		},
+		{
+			name:     "uppercase separator and spaces",
+			input:    " 1200X400 ",
+			expected: Canvas{Width: 1200, Height: 400},
+		},
+		{
+			name:        "missing height",
+			input:       "1200",
+			expectError: true,
+		},
+		{
+			name:        "non-numeric width",
+			input:       "wide x400",
+			expectError: true,
+		},
+		{
+			name:        "zero height",
+			input:       "1200x0",
+			expectError: true,
+		},
+		{
+			name:        "negative width",
+			input:       "-10x400",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canvas, err := parseCanvas(tt.input)
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, canvas)
+			}
+		})
+	}
+}
+
+func TestComputeLayout(t *testing.T) {
+	t.Run("default canvas matches original design", func(t *testing.T) {
+		l := computeLayout(defaultCanvas, "center")
+		assert.Equal(t, 240.0, l.CardX)
+		assert.Equal(t, 150.0, l.CardY)
+		assert.Equal(t, 1120.0, l.CardW)
+		assert.Equal(t, 300.0, l.CardH)
+		assert.Equal(t, [3]float64{450, 690, 930}, l.BadgeX)
+		assert.Equal(t, 800.0, l.TextX)
+		assert.Equal(t, "middle", l.TextAnchor)
+		assert.Equal(t, 325.0, l.TitleY)
+		assert.Equal(t, 395.0, l.TaglineY)
+	})
+
+	t.Run("left and right alignment", func(t *testing.T) {
+		left := computeLayout(defaultCanvas, "left")
+		assert.Equal(t, 180.0, left.CardX)
+		assert.Equal(t, 240.0, left.TextX)
+		assert.Equal(t, "start", left.TextAnchor)
+		assert.Equal(t, [3]float64{240, 500, 760}, left.BadgeX)
+
+		right := computeLayout(defaultCanvas, "right")
+		assert.Equal(t, 1360.0, right.TextX)
+		assert.Equal(t, "end", right.TextAnchor)
+		assert.Equal(t, [3]float64{600, 860, 1120}, right.BadgeX)
+	})
+
+	t.Run("content stays inside smaller canvas", func(t *testing.T) {
+		for _, canvas := range []Canvas{{1200, 400}, {800, 800}, {2000, 300}} {
+			for _, align := range []string{"center", "left", "right"} {
+				l := computeLayout(canvas, align)
+				assert.GreaterOrEqual(t, l.CardX, 0.0)
+				assert.GreaterOrEqual(t, l.CardY, 0.0)
+				assert.LessOrEqual(t, l.CardX+l.CardW, l.Width)
+				assert.LessOrEqual(t, l.CardY+l.CardH, l.Height)
+				assert.GreaterOrEqual(t, l.BadgeX[0], l.CardX)
+				assert.LessOrEqual(t, l.BadgeX[2]+l.BadgeW, l.CardX+l.CardW)
+				assert.Less(t, l.TaglineY, l.CardY+l.CardH)
+			}
+		}
+	})
+
+	t.Run("long text shrinks to fit", func(t *testing.T) {
+		l := computeLayout(defaultCanvas, "center")
+		l.fitText(parseInlineMarkdown("Banner Kit"), parseInlineMarkdown("Short tagline"), []string{"Go", "MIT"})
+		assert.Equal(t, 88.0, l.TitleFontSize)
+		assert.Equal(t, 36.0, l.TaglineFontSize)
+		assert.Equal(t, 26.0, l.BadgeFontSize)
+
+		l.fitText(parseInlineMarkdown("an-unusually-long-project-name-for-a-banner"), parseInlineMarkdown("Short tagline"), []string{"Go", "a very long badge label", "MIT"})
+		assert.Less(t, l.TitleFontSize, 88.0)
+		assert.Equal(t, 36.0, l.TaglineFontSize)
+		assert.Less(t, l.BadgeFontSize, 26.0)
+		width, err := measureText("an-unusually-long-project-name-for-a-banner", l.TitleFontSize, true)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, width, l.TextMaxW)
+	})
+}
+
+func TestLayoutLogo(t *testing.T) {
+	title := parseInlineMarkdown("Banner Kit")
+	tagline := parseInlineMarkdown("Short tagline")
+	place := func(align string, aspect float64) Layout {
+		l := computeLayout(defaultCanvas, align)
+		l.reserveLogo(aspect)
+		l.fitText(title, tagline, nil)
+		l.placeLogo(title, tagline)
+		return l
+	}
+
+	t.Run("left and right of the text", func(t *testing.T) {
+		left := place("left", 1)
+		assert.Equal(t, 240.0, left.LogoX)
+		assert.Equal(t, 263.0, left.LogoY)
+		assert.Equal(t, 130.0, left.LogoW)
+		assert.Equal(t, 130.0, left.LogoH)
+		assert.Equal(t, 402.0, left.TextX)
+		assert.Equal(t, 1240-2*60-162.0, left.TextMaxW)
+
+		right := place("right", 1)
+		assert.Equal(t, 1230.0, right.LogoX)
+		assert.Equal(t, 1198.0, right.TextX)
+	})
+
+	t.Run("centered together with the text", func(t *testing.T) {
+		l := place("center", 1)
+		textW, err := measureText("Banner Kit", l.TitleFontSize, true)
+		require.NoError(t, err)
+		assert.InDelta(t, 800, (l.LogoX+l.TextX+textW/2)/2, 1e-9)
+		assert.InDelta(t, l.LogoX+l.LogoW+logoGap, l.TextX-textW/2, 1e-9)
+	})
+
+	t.Run("wide logos are limited to twice their height", func(t *testing.T) {
+		l := place("left", 4)
+		assert.Equal(t, 260.0, l.LogoW)
+		assert.Equal(t, 65.0, l.LogoH)
+		assert.Equal(t, 328-l.LogoH/2, l.LogoY)
+	})
+
+	t.Run("no logo", func(t *testing.T) {
+		l := computeLayout(defaultCanvas, "center")
+		l.placeLogo(title, tagline)
+		assert.Equal(t, 800.0, l.TextX)
+		assert.Equal(t, "0", l.vars()["LOGO_W"])
+	})
+}
+
+func TestScalePath(t *testing.T) {
+	assert.Equal(t, "M0 215 C 130 180 L800 300 Z", scalePath("M0 430 C 260 360 L1600 600 Z", 0.5, 0.5))
+	assert.Equal(t, "M0 430 C 260 360, 520 520", scalePath("M0 430 C 260 360, 520 520", 1, 1))
+	assert.Equal(t, "M0 286.67 L1200 400", scalePath("M0 430 L1600 600", 0.75, 400.0/600.0))
+}
+
+func TestFormatNumber(t *testing.T) {
+	assert.Equal(t, "240", formatNumber(240))
+	assert.Equal(t, "66.67", formatNumber(200.0/3.0))
+	assert.Equal(t, "0", formatNumber(-0.001))
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// logoFileNames are looked up, in order, in each of logoDirs when the project
+// does not name a logo.
+var (
+	logoFileNames = []string{"logo.svg", "logo.png"}
+	logoDirs      = []string{".", "assets", "docs", ".github"}
+)
+
+// Logo is a project logo ready to be embedded in the banner.
+type Logo struct {
+	// Node is a nested <svg> or an <image> with a data URI, without a
+	// position.
+	Node *SVGNode
+	// Aspect is the logo's width divided by its height.
+	Aspect float64
+	// Image is the decoded PNG or JPEG logo, which resvg-wasm cannot draw,
+	// so it is composited onto its output. It is nil for SVG logos.
+	Image image.Image
+}
+
+// findLogo returns the first logo.svg or logo.png in the project, or "".
+func findLogo(projectDir string) string {
+	for _, dir := range logoDirs {
+		for _, name := range logoFileNames {
+			path := filepath.Join(projectDir, dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// resolveLogoPath picks the logo to draw: the flag, relative to the working
+// directory, then the config and the README's banner-logo marker, relative
+// to the project, and finally a logo file found in the project. It returns
+// "" when there is none.
+func resolveLogoPath(projectDir string, config *Config, metadata *Metadata, flagPath string) string {
+	if flagPath != "" {
+		return flagPath
+	}
+	for _, path := range []string{config.Logo, metadata.LogoPath} {
+		if path == "" {
+			continue
+		}
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(projectDir, path)
+	}
+	return findLogo(projectDir)
+}
+
+// loadLogo reads an SVG, PNG or JPEG logo. SVG logos are inlined so they stay
+// vector; images are embedded as a base64 data URI. Either way the banner
+// does not reference other files.
+func loadLogo(path string) (*Logo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logo: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".svg":
+		return parseSVGLogo(data, path)
+	case ".png", ".jpg", ".jpeg":
+		return parseImageLogo(data, path)
+	default:
+		return nil, fmt.Errorf("unsupported logo format %q: use .svg, .png or .jpg", ext)
+	}
+}
+
+// parseSVGLogo turns an SVG logo into a nested <svg>. Ids are prefixed so
+// they do not clash with the banner's, and editor metadata in other
+// namespaces is dropped, since the banner does not declare them.
+func parseSVGLogo(data []byte, path string) (*Logo, error) {
+	doc, err := parseSVG(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid logo %s: %w", path, err)
+	}
+	viewBox, ok := svgViewBox(doc.Root)
+	if !ok {
+		return nil, fmt.Errorf("invalid logo %s: no viewBox or size", path)
+	}
+	fields := strings.FieldsFunc(viewBox, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid logo %s: invalid viewBox %q", path, viewBox)
+	}
+	w, errW := strconv.ParseFloat(fields[2], 64)
+	h, errH := strconv.ParseFloat(fields[3], 64)
+	if errW != nil || errH != nil || w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid logo %s: invalid viewBox %q", path, viewBox)
+	}
+
+	node := &SVGNode{Kind: ElementNode, Name: "svg", Attrs: []SVGAttr{{Name: "viewBox", Value: viewBox}}}
+	for _, attr := range doc.Root.Attrs {
+		switch attr.Name {
+		case "viewBox", "width", "height", "x", "y", "id", "version":
+		default:
+			node.Attrs = append(node.Attrs, attr)
+		}
+	}
+	for _, child := range doc.Root.Children {
+		node.AppendChild(child.Clone())
+	}
+	prefixIDs(node, "logo-")
+	stripForeignMarkup(node)
+	return &Logo{Node: node, Aspect: w / h}, nil
+}
+
+// stripForeignMarkup removes scripts, event handlers, <metadata> and the
+// elements and attributes of namespaces other than xml below n.
+func stripForeignMarkup(n *SVGNode) {
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		if child.Kind == ElementNode && (strings.Contains(child.Name, ":") || child.Name == "script" || child.Name == "metadata") {
+			continue
+		}
+		stripForeignMarkup(child)
+		children = append(children, child)
+	}
+	n.Children = children
+
+	attrs := n.Attrs[:0]
+	for _, attr := range n.Attrs {
+		if (strings.Contains(attr.Name, ":") && !strings.HasPrefix(attr.Name, "xml:")) || strings.HasPrefix(attr.Name, "on") {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	n.Attrs = attrs
+}
+
+// parseImageLogo embeds a PNG or JPEG logo as a data URI <image>.
+func parseImageLogo(data []byte, path string) (*Logo, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid logo %s: %w", path, err)
+	}
+	size := img.Bounds().Size()
+	if size.X <= 0 || size.Y <= 0 {
+		return nil, fmt.Errorf("invalid logo %s: empty image", path)
+	}
+
+	uri := "data:image/" + format + ";base64," + base64.StdEncoding.EncodeToString(data)
+	node := &SVGNode{Kind: ElementNode, Name: "image", Attrs: []SVGAttr{
+		{Name: "href", Value: uri},
+		{Name: "preserveAspectRatio", Value: "xMidYMid meet"},
+	}}
+	return &Logo{Node: node, Aspect: float64(size.X) / float64(size.Y), Image: img}, nil
+}
+
+// setLogoSlot draws logo in the template's logo slot, at the layout's logo
+// box. Without a logo the slot is removed.
+func setLogoSlot(doc *SVGDocument, logo *Logo, l Layout) {
+	slot := doc.Root.FindByID("logo")
+	if slot == nil {
+		return
+	}
+	if logo == nil {
+		slot.Remove()
+		return
+	}
+
+	node := logo.Node.Clone()
+	node.SetAttr("x", formatNumber(l.LogoX))
+	node.SetAttr("y", formatNumber(l.LogoY))
+	node.SetAttr("width", formatNumber(l.LogoW))
+	node.SetAttr("height", formatNumber(l.LogoH))
+	slot.AppendChild(node)
+}
+
+// logoBox is where the logo slot draws a raster logo, in user units of a
+// viewport ViewW by ViewH.
+type logoBox struct {
+	X, Y, W, H   float64
+	ViewW, ViewH float64
+}
+
+// findLogoBox returns the box of the <image> in the logo slot of svg. It is
+// read before minification, which drops the slot's id. Transforms around
+// the slot in custom templates are not applied.
+func findLogoBox(svg string) (logoBox, bool) {
+	doc, err := parseSVG(svg)
+	if err != nil {
+		return logoBox{}, false
+	}
+	slot := doc.Root.FindByID("logo")
+	if slot == nil {
+		return logoBox{}, false
+	}
+	images := slot.FindByName("image")
+	if len(images) == 0 {
+		return logoBox{}, false
+	}
+	var box logoBox
+	var ok bool
+	if box.ViewW, box.ViewH, ok = svgUserSize(doc.Root); !ok {
+		return logoBox{}, false
+	}
+	for _, field := range []struct {
+		name  string
+		value *float64
+	}{{"x", &box.X}, {"y", &box.Y}, {"width", &box.W}, {"height", &box.H}} {
+		value, _ := images[0].Attr(field.name)
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return logoBox{}, false
+		}
+		*field.value = v
+	}
+	return box, box.W > 0 && box.H > 0
+}
+
+// svgUserSize is the size of the root viewport in user units: its viewBox,
+// or its width and height in pixels.
+func svgUserSize(root *SVGNode) (float64, float64, bool) {
+	if viewBox, ok := root.Attr("viewBox"); ok {
+		fields := strings.FieldsFunc(viewBox, func(r rune) bool { return r == ',' || r == ' ' })
+		if len(fields) == 4 {
+			w, errW := strconv.ParseFloat(fields[2], 64)
+			h, errH := strconv.ParseFloat(fields[3], 64)
+			if errW == nil && errH == nil && w > 0 && h > 0 {
+				return w, h, true
+			}
+		}
+	}
+	w, okW := parsePixels(root, "width")
+	h, okH := parsePixels(root, "height")
+	return w, h, okW && okH
+}
+
+// compositeLogo draws img over a rendered PNG at box, scaled to the PNG's
+// size and fitted like preserveAspectRatio="xMidYMid meet".
+func compositeLogo(data []byte, img image.Image, box logoBox) ([]byte, error) {
+	rendered, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to draw logo: %w", err)
+	}
+	bounds := rendered.Bounds()
+	canvas := image.NewNRGBA(bounds)
+	draw.Draw(canvas, bounds, rendered, bounds.Min, draw.Src)
+
+	sx := float64(bounds.Dx()) / box.ViewW
+	sy := float64(bounds.Dy()) / box.ViewH
+	x, y, w, h := box.X*sx, box.Y*sy, box.W*sx, box.H*sy
+	size := img.Bounds().Size()
+	if aspect := float64(size.X) / float64(size.Y); w/h > aspect {
+		x += (w - h*aspect) / 2
+		w = h * aspect
+	} else {
+		y += (h - w/aspect) / 2
+		h = w / aspect
+	}
+	target := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h))).Add(bounds.Min)
+	xdraw.CatmullRom.Scale(canvas, target, img, img.Bounds(), xdraw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to draw logo: %w", err)
+	}
+	return buf.Bytes(), nil
+}
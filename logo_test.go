@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLogoSVG = `<?xml version="1.0"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" width="64" height="32" fill="none" inkscape:version="1.0">` +
+	`<metadata>editor data</metadata><inkscape:grid/>` +
+	`<defs><linearGradient id="g"><stop offset="0" stop-color="#F0A"/></linearGradient></defs>` +
+	`<rect id="r" width="64" height="32" fill="url(#g)" inkscape:label="bg" onclick="alert(1)"/><use xlink:href="#r"/><script>alert(1)</script></svg>`
+
+func writeTestPNG(t *testing.T, path string, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	return buf.Bytes()
+}
+
+func TestFindLogo(t *testing.T) {
+	projectDir := t.TempDir()
+	assert.Empty(t, findLogo(projectDir))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(projectDir, ".github"), 0755))
+	writeTestPNG(t, filepath.Join(projectDir, ".github", "logo.png"), 2, 2)
+	assert.Equal(t, filepath.Join(projectDir, ".github", "logo.png"), findLogo(projectDir))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(projectDir, "assets"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "assets", "logo.svg"), []byte(testLogoSVG), 0644))
+	assert.Equal(t, filepath.Join(projectDir, "assets", "logo.svg"), findLogo(projectDir))
+
+	// A directory named like a logo is skipped.
+	require.NoError(t, os.Mkdir(filepath.Join(projectDir, "logo.svg"), 0755))
+	assert.Equal(t, filepath.Join(projectDir, "assets", "logo.svg"), findLogo(projectDir))
+}
+
+func TestResolveLogoPath(t *testing.T) {
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "logo.svg"), []byte(testLogoSVG), 0644))
+	metadata := &Metadata{LogoPath: "docs/mark.svg"}
+
+	assert.Equal(t, "flag.svg", resolveLogoPath(projectDir, &Config{Logo: "brand.svg"}, metadata, "flag.svg"))
+	assert.Equal(t, filepath.Join(projectDir, "brand.svg"), resolveLogoPath(projectDir, &Config{Logo: "brand.svg"}, metadata, ""))
+	assert.Equal(t, "/opt/brand.svg", resolveLogoPath(projectDir, &Config{Logo: "/opt/brand.svg"}, metadata, ""))
+	assert.Equal(t, filepath.Join(projectDir, "docs", "mark.svg"), resolveLogoPath(projectDir, &Config{}, metadata, ""))
+	assert.Equal(t, filepath.Join(projectDir, "logo.svg"), resolveLogoPath(projectDir, &Config{}, &Metadata{}, ""))
+	assert.Empty(t, resolveLogoPath(t.TempDir(), &Config{}, &Metadata{}, ""))
+}
+
+func TestLoadLogo(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("svg is nested with prefixed ids", func(t *testing.T) {
+		path := filepath.Join(dir, "logo.svg")
+		require.NoError(t, os.WriteFile(path, []byte(testLogoSVG), 0644))
+
+		logo, err := loadLogo(path)
+		require.NoError(t, err)
+		assert.Equal(t, 2.0, logo.Aspect)
+		assert.Nil(t, logo.Image)
+		assert.Equal(t, `<svg viewBox="0 0 64 32" fill="none">`+
+			`<defs><linearGradient id="logo-g"><stop offset="0" stop-color="#F0A"/></linearGradient></defs>`+
+			`<rect id="logo-r" width="64" height="32" fill="url(#logo-g)"/><use href="#logo-r"/></svg>`,
+			strings.TrimSpace((&SVGDocument{Root: logo.Node}).String()))
+	})
+
+	t.Run("style sheets follow the prefixed ids and classes", func(t *testing.T) {
+		// As exported by Illustrator: the gradient is only referenced from CSS.
+		path := filepath.Join(dir, "illustrator.svg")
+		require.NoError(t, os.WriteFile(path, []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">`+
+			`<defs><style>.cls-1{fill:url(#linear-gradient);}.cls-2,#mark>.cls-1{fill:#fff;stroke:url('#missing')}`+
+			`@media (min-width:1px){.cls-3{opacity:.5}}</style>`+
+			`<linearGradient id="linear-gradient"><stop offset="0" stop-color="#F0A"/></linearGradient></defs>`+
+			`<g id="mark"><path class="cls-1 cls-2" d="M0 0h10v10z"/></g></svg>`), 0644))
+
+		logo, err := loadLogo(path)
+		require.NoError(t, err)
+		assert.Equal(t, `<svg viewBox="0 0 10 10">`+
+			`<defs><style>.logo-cls-1{fill:url(#logo-linear-gradient);}.logo-cls-2,#logo-mark&gt;.logo-cls-1{fill:#fff;stroke:url(&apos;#missing&apos;)}`+
+			`@media (min-width:1px){.logo-cls-3{opacity:.5}}</style>`+
+			`<linearGradient id="logo-linear-gradient"><stop offset="0" stop-color="#F0A"/></linearGradient></defs>`+
+			`<g id="logo-mark"><path class="logo-cls-1 logo-cls-2" d="M0 0h10v10z"/></g></svg>`,
+			strings.TrimSpace((&SVGDocument{Root: logo.Node}).String()))
+	})
+
+	t.Run("png is a data uri", func(t *testing.T) {
+		path := filepath.Join(dir, "logo.png")
+		data := writeTestPNG(t, path, 30, 20)
+
+		logo, err := loadLogo(path)
+		require.NoError(t, err)
+		assert.Equal(t, 1.5, logo.Aspect)
+		assert.NotNil(t, logo.Image)
+		assert.Equal(t, "image", logo.Node.Name)
+		href, _ := logo.Node.Attr("href")
+		assert.Equal(t, "data:image/png;base64,"+base64.StdEncoding.EncodeToString(data), href)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := loadLogo(filepath.Join(dir, "missing.svg"))
+		assert.ErrorContains(t, err, "failed to read logo")
+
+		gif := filepath.Join(dir, "logo.gif")
+		require.NoError(t, os.WriteFile(gif, []byte("GIF89a"), 0644))
+		_, err = loadLogo(gif)
+		assert.ErrorContains(t, err, `unsupported logo format ".gif"`)
+
+		sizeless := filepath.Join(dir, "sizeless.svg")
+		require.NoError(t, os.WriteFile(sizeless, []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), 0644))
+		_, err = loadLogo(sizeless)
+		assert.ErrorContains(t, err, "no viewBox or size")
+
+		broken := filepath.Join(dir, "broken.png")
+		require.NoError(t, os.WriteFile(broken, []byte("not a png"), 0644))
+		_, err = loadLogo(broken)
+		assert.ErrorContains(t, err, "invalid logo")
+	})
+}
+
+func TestSetLogoSlot(t *testing.T) {
+	theme, err := getTheme("light")
+	require.NoError(t, err)
+	logo := &Logo{Node: &SVGNode{Kind: ElementNode, Name: "image", Attrs: []SVGAttr{{Name: "href", Value: "data:image/png;base64,AA=="}}}, Aspect: 1}
+
+	t.Run("logo is drawn in the slot", func(t *testing.T) {
+		svg, err := generateSVG(&Metadata{Name: "Kit", Logo: logo}, theme, "left", nil, defaultCanvas)
+		require.NoError(t, err)
+		assert.Contains(t, svg, `<g id="logo"><image href="data:image/png;base64,AA==" x="240" y="263" width="130" height="130"/></g>`)
+		assert.Contains(t, svg, `x="402" y="325"`)
+	})
+
+	t.Run("slot is removed without a logo", func(t *testing.T) {
+		svg, err := generateSVG(&Metadata{Name: "Kit"}, theme, "left", nil, defaultCanvas)
+		require.NoError(t, err)
+		assert.NotContains(t, svg, `id="logo"`)
+	})
+}
+
+func TestCompositeLogo(t *testing.T) {
+	white := image.NewNRGBA(image.Rect(0, 0, 100, 50))
+	for i := range white.Pix {
+		white.Pix[i] = 255
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, white))
+	logo := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			logo.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	// At twice the viewport size, the square logo is centered in the
+	// 80x40 pixel box.
+	data, err := compositeLogo(buf.Bytes(), logo, logoBox{X: 10, Y: 10, W: 40, H: 20, ViewW: 50, ViewH: 25})
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	at := func(x, y int) color.NRGBA { return color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA) }
+	assert.Equal(t, color.NRGBA{255, 0, 0, 255}, at(41, 21))
+	assert.Equal(t, color.NRGBA{255, 0, 0, 255}, at(78, 38))
+	assert.Equal(t, color.NRGBA{255, 255, 255, 255}, at(30, 40))
+	assert.Equal(t, color.NRGBA{255, 255, 255, 255}, at(90, 40))
+}
+
+func TestFindLogoBox(t *testing.T) {
+	box, ok := findLogoBox(`<svg width="800" height="300" viewBox="0 0 1600 600"><g id="logo"><image href="data:image/png;base64,AA==" x="240" y="263" width="130" height="130"/></g></svg>`)
+	require.True(t, ok)
+	assert.Equal(t, logoBox{X: 240, Y: 263, W: 130, H: 130, ViewW: 1600, ViewH: 600}, box)
+
+	_, ok = findLogoBox(`<svg width="800" height="300"><g id="logo"><svg viewBox="0 0 1 1"/></g></svg>`)
+	assert.False(t, ok, "SVG logos are drawn by the renderer")
+	_, ok = findLogoBox(`<svg width="800" height="300"/>`)
+	assert.False(t, ok)
+}
@@ -1,20 +1,171 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// Options controls a single banner generation run.
+type Options struct {
+	Theme      string
+	Align      string
+	Canvas     Canvas
+	Vars       map[string]string
+	ConfigPath string
+	Template   string
+	// Renderer is a comma-separated renderer fallback list, or "auto".
+	Renderer string
+	// Width and Height set the PNG size at scale 1. Zero follows the canvas.
+	Width  int
+	Height int
+	// Scales lists the PNGs to render, e.g. 1 and 2 for banner@2x.png.
+	Scales []float64
+	DPI    float64
+	// RenderTimeout bounds each renderer attempt. Zero disables it.
+	RenderTimeout time.Duration
+	// Format is a comma-separated list of output formats, e.g. "png,webp".
+	Format  string
+	Quality int
+	// OptimizePNG and MaxPNGKB control the lossless PNG pass and the
+	// optional size budget that allows palette quantization.
+	OptimizePNG bool
+	MaxPNGKB    int
+	// Minify writes a minified banner.svg and renders from it.
+	Minify bool
+	// FontDirs and FontFiles add fonts to the renderers, after the fonts
+	// from the config file.
+	FontDirs  []string
+	FontFiles []string
+	// SystemFonts prefers the installed fonts over the embedded ones.
+	SystemFonts bool
+	// ColorEmoji draws the emoji of the text slots with SVG glyphs, from
+	// EmojiDir or the embedded set. Setting EmojiDir implies ColorEmoji.
+	ColorEmoji bool
+	EmojiDir   string
+	// Logo is an SVG, PNG or JPEG drawn next to the title. Without it the
+	// config, the README's banner-logo marker and a logo file in the
+	// project are tried, unless NoLogo is set.
+	Logo   string
+	NoLogo bool
+	// Background is the generated background style and Seed the text its
+	// shapes are derived from. Both default to the config, then to seeded
+	// waves and the project name.
+	Background string
+	Seed       string
+}
+
+func defaultOptions() Options {
+	return Options{
+		Theme:         "light",
+		Align:         "center",
+		Canvas:        defaultCanvas,
+		Vars:          make(map[string]string),
+		Scales:        []float64{1},
+		Quality:       defaultQuality,
+		RenderTimeout: defaultRenderTimeout,
+	}
+}
+
+// varFlags collects repeated -var NAME=value flags.
+type varFlags map[string]string
+
+func (v varFlags) String() string {
+	pairs := make([]string, 0, len(v))
+	for name, value := range v {
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v varFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("expected NAME=value, got %q", s)
+	}
+	key, err := normalizeVarName(strings.TrimSpace(name))
+	if err != nil {
+		return err
+	}
+	v[key] = value
+	return nil
+}
+
+// listFlags collects a repeatable flag such as -font-dir.
+type listFlags struct{ values *[]string }
+
+func (l listFlags) String() string {
+	if l.values == nil {
+		return ""
+	}
+	return strings.Join(*l.values, ",")
+}
+
+func (l listFlags) Set(s string) error {
+	*l.values = append(*l.values, s)
+	return nil
+}
+
 func main() {
+	opts := defaultOptions()
+
+	size := flag.String("size", opts.Canvas.String(), "Banner size as WIDTHxHEIGHT")
+	flag.StringVar(&opts.ConfigPath, "config", "", "Path to config file (default: <project-dir>/"+configFileName+")")
+	flag.Var(varFlags(opts.Vars), "var", "Template variable as NAME=value (repeatable)")
+	flag.StringVar(&opts.Renderer, "renderer", "", "PNG renderer fallback list, e.g. rsvg-convert,resvg-wasm (default: auto)")
+	flag.StringVar(&opts.Template, "template", "", "Custom SVG template file (replaces the built-in alignment templates)")
+	flag.IntVar(&opts.Width, "width", 0, "PNG width in pixels at scale 1 (default: canvas width)")
+	flag.IntVar(&opts.Height, "height", 0, "PNG height in pixels at scale 1 (default: canvas height)")
+	scales := flag.String("scale", "1", "PNG scales as a comma-separated list, e.g. 1,2 for banner.png and banner@2x.png")
+	flag.Float64Var(&opts.DPI, "dpi", 0, "PNG resolution for physical units and metadata (default: 96, not recorded)")
+	flag.StringVar(&opts.Format, "format", "png", "Output formats as a comma-separated list: png, webp, jpeg, gif, ico, pdf")
+	flag.IntVar(&opts.Quality, "quality", opts.Quality, "JPEG quality from 1 to 100; other formats are lossless")
+	flag.BoolVar(&opts.OptimizePNG, "optimize-png", false, "Losslessly optimize PNG output: strip metadata, pick the best filters and compression")
+	flag.IntVar(&opts.MaxPNGKB, "max-png-kb", 0, "PNG size budget in KB; implies -optimize-png and quantizes to a palette with dithering if needed")
+	flag.DurationVar(&opts.RenderTimeout, "render-timeout", opts.RenderTimeout, "Time limit per renderer attempt before falling back to the next one, e.g. 30s (0 disables)")
+	flag.BoolVar(&opts.Minify, "minify", false, "Minify banner.svg: strip comments and whitespace, shorten numbers, drop redundant attributes and empty groups")
+	flag.Var(listFlags{&opts.FontDirs}, "font-dir", "Font directory loaded by the renderers in addition to the system fonts (repeatable)")
+	flag.Var(listFlags{&opts.FontFiles}, "font-file", "Font file loaded by the renderers in addition to the system fonts (repeatable)")
+	flag.BoolVar(&opts.SystemFonts, "system-fonts", false, "Prefer installed fonts over the embedded DejaVu Sans (output then depends on the machine)")
+	flag.BoolVar(&opts.ColorEmoji, "color-emoji", false, "Draw emoji in the title, tagline and badges with the embedded Twemoji 14.0.2 color SVG glyphs instead of fonts")
+	flag.StringVar(&opts.EmojiDir, "emoji-dir", "", "Directory of color emoji SVGs named like Twemoji or Noto Emoji, e.g. 1f680.svg; implies -color-emoji")
+	flag.StringVar(&opts.Logo, "logo", "", "Logo image (.svg, .png or .jpg) drawn next to the title (default: banner-logo marker or logo.svg/logo.png in the project)")
+	flag.BoolVar(&opts.NoLogo, "no-logo", false, "Do not draw a project logo")
+	flag.StringVar(&opts.Background, "background", "", "Background style: "+strings.Join(backgroundStyles, "|")+" (default: "+defaultBackground+")")
+	flag.StringVar(&opts.Seed, "seed", "", "Seed for the background shapes (default: the project name)")
+	noCache := flag.Bool("no-cache", false, "Do not read or write the on-disk resvg-wasm compilation cache")
+
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s <project-dir> [theme] [align]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <project-dir> [theme] [align]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s cache clear\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Arguments:\n")
 		fmt.Fprintf(os.Stderr, "  project-dir   Path to project directory containing README.md\n")
-		fmt.Fprintf(os.Stderr, "  theme         Theme name: light|muted|dark (default: light)\n")
+		fmt.Fprintf(os.Stderr, "  theme         Theme name: light|muted|dark|pride|from-logo, or one from the config (default: light)\n")
 		fmt.Fprintf(os.Stderr, "  align         Alignment: center|left|right (default: center)\n\n")
-		fmt.Fprintf(os.Stderr, "Example:\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		fmt.Fprintf(os.Stderr, "  cache clear   Remove the resvg-wasm compilation cache\n\n")
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExample:\n")
 		fmt.Fprintf(os.Stderr, "  %s ./my-project dark left\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -size 1200x400 ./my-project\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -var VERSION=1.2.0 ./my-project\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -template design.svg ./my-project dark\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -renderer resvg-wasm ./my-project\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -scale 1,2 ./my-project\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -format png,webp,jpeg -quality 85 ./my-project\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -max-png-kb 200 ./my-project\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -font-dir ./fonts ./my-project\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -color-emoji ./my-project\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -logo assets/logo.svg ./my-project left\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -background dots -seed v2 ./my-project\n", os.Args[0])
 	}
 
 	flag.Parse()
@@ -25,44 +176,275 @@ func main() {
 		os.Exit(1)
 	}
 
+	if args[0] == "cache" {
+		if err := runCacheCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *noCache {
+		defaultResvgPool.cacheDir = ""
+	}
+
 	projectDir := args[0]
-	theme := "light"
-	align := "center"
 
 	if len(args) > 1 {
-		theme = args[1]
+		opts.Theme = args[1]
 	}
 	if len(args) > 2 {
-		align = args[2]
+		opts.Align = args[2]
+	}
+
+	canvas, err := parseCanvas(*size)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
+	opts.Canvas = canvas
 
-	if err := generateBanner(projectDir, theme, align); err != nil {
+	opts.Scales, err = parseScales(*scales)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// An interrupt cancels rendering and leaves existing files untouched. A
+	// second interrupt kills the process as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := generateBanner(ctx, projectDir, opts); err != nil {
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintf(os.Stderr, "Interrupted: no files were changed\n")
+			os.Exit(130)
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func generateBanner(projectDir, themeStr, align string) error {
-	theme, err := getTheme(themeStr)
+// runCacheCommand handles "cache <subcommand>". A project directory named
+// cache can still be passed as ./cache.
+func runCacheCommand(args []string) error {
+	if len(args) != 1 || args[0] != "clear" {
+		return fmt.Errorf("unknown cache command %q. Use: cache clear", strings.Join(args, " "))
+	}
+
+	dir, err := clearResvgCache()
 	if err != nil {
 		return err
 	}
+	fmt.Printf("Cleared: %s\n", dir)
+	return nil
+}
+
+// generateBanner writes the banner files for projectDir. Rendering stops when
+// ctx is done, without touching existing files.
+func generateBanner(ctx context.Context, projectDir string, opts Options) error {
+	config, err := readProjectConfig(projectDir, opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	// The from-logo palette is derived once the logo is loaded.
+	var theme *ThemePalette
+	if opts.Theme != fromLogoTheme {
+		theme, err = resolveTheme(opts.Theme, config.Themes)
+		if err != nil {
+			return err
+		}
+	}
+
+	rendererList := opts.Renderer
+	if rendererList == "" {
+		rendererList = config.Renderer
+	}
+	renderers, err := parseRendererList(rendererList)
+	if err != nil {
+		return err
+	}
+
+	fonts, err := resolveFonts(projectDir, config, opts.FontDirs, opts.FontFiles)
+	if err != nil {
+		return err
+	}
+	fonts.System = opts.SystemFonts || config.SystemFonts
+
+	formats, err := parseFormatList(opts.Format)
+	if err != nil {
+		return err
+	}
+	encodeOpts := EncodeOptions{Quality: opts.Quality, OptimizePNG: opts.OptimizePNG, MaxPNGKB: opts.MaxPNGKB}
+	if err := encodeOpts.validate(); err != nil {
+		return err
+	}
 
 	metadata, err := readProjectMetadata(projectDir)
 	if err != nil {
 		return err
 	}
+	metadata.Vars = mergeVars(config.Vars, metadata.Vars, opts.Vars)
+	metadata.Background = opts.Background
+	if metadata.Background == "" {
+		metadata.Background = config.Background
+	}
+	metadata.Seed = opts.Seed
+	if metadata.Seed == "" {
+		metadata.Seed = config.Seed
+	}
 
-	svg, err := generateSVG(metadata, theme, align, []string{})
+	if !opts.NoLogo {
+		if logoPath := resolveLogoPath(projectDir, config, metadata, opts.Logo); logoPath != "" {
+			metadata.Logo, err = loadLogo(logoPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if opts.Theme == fromLogoTheme {
+		if metadata.Logo == nil {
+			return fmt.Errorf("theme %s needs a logo: add <!-- banner-logo: PATH --> to README.md or a logo.svg or logo.png to the project, or pass -logo", fromLogoTheme)
+		}
+		theme, err = logoTheme(metadata.Logo)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Shortcodes are expanded to code points before the text reaches the
+	// template, where it is escaped.
+	badges := []string{}
+	warnings, err := expandTextShortcodes(metadata, badges)
 	if err != nil {
 		return err
 	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
 
-	png, err := convertSVGToPNG(svg)
+	templatePath := opts.Template
+	if templatePath == "" && config.Template != "" {
+		templatePath = config.Template
+		if !filepath.IsAbs(templatePath) {
+			templatePath = filepath.Join(projectDir, templatePath)
+		}
+	}
+
+	var svg string
+	if templatePath != "" {
+		template, err := loadTemplateFile(templatePath)
+		if err != nil {
+			return err
+		}
+		svg, err = renderTemplate(template, metadata, theme, opts.Align, badges, opts.Canvas)
+		if err != nil {
+			return err
+		}
+	} else {
+		svg, err = generateSVG(metadata, theme, opts.Align, badges, opts.Canvas)
+		if err != nil {
+			return err
+		}
+	}
+
+	emojiDir := opts.EmojiDir
+	if emojiDir == "" && config.EmojiDir != "" {
+		emojiDir = config.EmojiDir
+		if !filepath.IsAbs(emojiDir) {
+			emojiDir = filepath.Join(projectDir, emojiDir)
+		}
+	}
+	if opts.ColorEmoji || config.ColorEmoji || emojiDir != "" {
+		glyphs, err := emojiGlyphSet(emojiDir)
+		if err != nil {
+			return err
+		}
+		var warnings []string
+		svg, warnings, err = replaceColorEmoji(svg, glyphs)
+		if err != nil {
+			return err
+		}
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+	}
+
+	// Missing glyphs are found before rendering, when a fallback font can
+	// still be added to the SVG.
+	svg, issues, err := checkGlyphs(svg, fonts)
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", issue)
+	}
+
+	var logoBox logoBox
+	var hasLogoBox bool
+	if metadata.Logo != nil && metadata.Logo.Image != nil {
+		logoBox, hasLogoBox = findLogoBox(svg)
+	}
+
+	if opts.Minify {
+		svg, err = minifySVG(svg)
+		if err != nil {
+			return err
+		}
+	}
+
+	renderOpts, err := rasterOptions(svg, opts.Width, opts.Height, opts.Scales, opts.DPI, opts.RenderTimeout)
 	if err != nil {
+		return err
+	}
+	for i := range renderOpts {
+		renderOpts[i].Fonts = fonts
+	}
+	// Vector formats are encoded once, at the size of the scale-1 raster.
+	encodeOpts.Render = renderOpts[0]
+	for i, scale := range opts.Scales {
+		if scale == 1 {
+			encodeOpts.Render = renderOpts[i]
+			break
+		}
+	}
+
+	rasters, err := renderRasters(ctx, svg, renderers, opts.Scales, renderOpts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		// A renderer chosen explicitly (e.g. pinned in CI) must not silently
+		// degrade to an SVG-only run.
+		if rendererList != "" && rendererList != "auto" {
+			return err
+		}
 		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
-		png = nil
+		rasters = nil
+	}
+	// The embedded resvg build has no PNG or JPEG decoder, so a raster logo
+	// is drawn onto its output instead.
+	if hasLogoBox {
+		for i := range rasters {
+			if rasters[i].Renderer != "resvg-wasm" {
+				continue
+			}
+			rasters[i].PNG, err = compositeLogo(rasters[i].PNG, metadata.Logo.Image, logoBox)
+			if err != nil {
+				return err
+			}
+			// Re-encoding drops the pHYs chunk renderRasters added.
+			if dpi := rasters[i].Options.DPI; dpi > 0 {
+				if rasters[i].PNG, err = setPNGDPI(rasters[i].PNG, dpi); err != nil {
+					return err
+				}
+			}
+		}
 	}
 
-	return writeBannerFiles(projectDir, svg, png)
+	return writeBannerFiles(ctx, projectDir, svg, rasters, formats, encodeOpts)
 }
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,7 +32,7 @@ func TestGenerateBanner(t *testing.T) {
 		err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 		require.NoError(t, err)
 
-		err = generateBanner(projectDir, "light", "center")
+		err = generateBanner(context.Background(), projectDir, testOptions("light", "center"))
 		require.NoError(t, err)
 
 		svgPath := filepath.Join(projectDir, "banner.svg")
@@ -51,7 +58,7 @@ func TestGenerateBanner(t *testing.T) {
 		err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 		require.NoError(t, err)
 
-		err = generateBanner(projectDir, "dark", "left")
+		err = generateBanner(context.Background(), projectDir, testOptions("dark", "left"))
 		require.NoError(t, err)
 
 		svgPath := filepath.Join(projectDir, "banner.svg")
@@ -59,7 +66,7 @@ func TestGenerateBanner(t *testing.T) {
 		require.NoError(t, err)
 
 		darkTheme, _ := getTheme("dark")
-		assert.Contains(t, string(svgContent), darkTheme.BG0)
+		assert.Contains(t, string(svgContent), darkTheme.Background.Stops[0].Color)
 		assert.Contains(t, string(svgContent), "&#128640;")
 	})
 
@@ -75,7 +82,7 @@ func TestGenerateBanner(t *testing.T) {
 		err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 		require.NoError(t, err)
 
-		err = generateBanner(projectDir, "muted", "right")
+		err = generateBanner(context.Background(), projectDir, testOptions("muted", "right"))
 		require.NoError(t, err)
 
 		svgPath := filepath.Join(projectDir, "banner.svg")
@@ -83,7 +90,7 @@ func TestGenerateBanner(t *testing.T) {
 		require.NoError(t, err)
 
 		mutedTheme, _ := getTheme("muted")
-		assert.Contains(t, string(svgContent), mutedTheme.BG0)
+		assert.Contains(t, string(svgContent), mutedTheme.Background.Stops[0].Color)
 	})
 
 	t.Run("banner generation without tagline", func(t *testing.T) {
@@ -97,7 +104,7 @@ func TestGenerateBanner(t *testing.T) {
 		err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 		require.NoError(t, err)
 
-		err = generateBanner(projectDir, "light", "center")
+		err = generateBanner(context.Background(), projectDir, testOptions("light", "center"))
 		require.NoError(t, err)
 
 		svgPath := filepath.Join(projectDir, "banner.svg")
@@ -117,7 +124,7 @@ func TestGenerateBanner(t *testing.T) {
 		err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 		require.NoError(t, err)
 
-		err = generateBanner(projectDir, "invalid-theme", "center")
+		err = generateBanner(context.Background(), projectDir, testOptions("invalid-theme", "center"))
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "unknown theme")
 	})
@@ -127,7 +134,7 @@ func TestGenerateBanner(t *testing.T) {
 		err := os.MkdirAll(projectDir, 0755)
 		require.NoError(t, err)
 
-		err = generateBanner(projectDir, "light", "center")
+		err = generateBanner(context.Background(), projectDir, testOptions("light", "center"))
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to read README.md")
 	})
@@ -142,7 +149,7 @@ func TestGenerateBanner(t *testing.T) {
 		err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 		require.NoError(t, err)
 
-		err = generateBanner(projectDir, "light", "center")
+		err = generateBanner(context.Background(), projectDir, testOptions("light", "center"))
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "no banner-title found")
 	})
@@ -158,7 +165,7 @@ func TestGenerateBanner(t *testing.T) {
 		err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 		require.NoError(t, err)
 
-		err = generateBanner(projectDir, "light", "invalid-align")
+		err = generateBanner(context.Background(), projectDir, testOptions("light", "invalid-align"))
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to load template")
 	})
@@ -175,7 +182,7 @@ func TestGenerateBanner(t *testing.T) {
 		err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 		require.NoError(t, err)
 
-		err = generateBanner(projectDir, "light", "center")
+		err = generateBanner(context.Background(), projectDir, testOptions("light", "center"))
 		require.NoError(t, err)
 
 		svgPath := filepath.Join(projectDir, "banner.svg")
@@ -195,7 +202,7 @@ func TestGenerateBanner(t *testing.T) {
 		err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 		require.NoError(t, err)
 
-		err = generateBanner(projectDir, "light", "center")
+		err = generateBanner(context.Background(), projectDir, testOptions("light", "center"))
 		require.NoError(t, err)
 
 		svgPath := filepath.Join(projectDir, "banner.svg")
@@ -208,6 +215,127 @@ func TestGenerateBanner(t *testing.T) {
 	})
 }
 
+func TestGenerateBannerVars(t *testing.T) {
+	tempDir := t.TempDir()
+
+	t.Run("variables from config, README and flags", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "vars-ok")
+		err := os.MkdirAll(projectDir, 0755)
+		require.NoError(t, err)
+
+		readmeContent := `<!-- banner-title: Vars -->
+<!-- banner-var-VERSION: 1.0 -->`
+		err = os.WriteFile(filepath.Join(projectDir, "README.md"), []byte(readmeContent), 0644)
+		require.NoError(t, err)
+		err = os.WriteFile(filepath.Join(projectDir, configFileName), []byte("vars:\n  author: Config\n"), 0644)
+		require.NoError(t, err)
+
+		opts := testOptions("light", "center")
+		opts.Vars["URL"] = "https://example.com"
+
+		err = generateBanner(context.Background(), projectDir, opts)
+		require.NoError(t, err)
+	})
+
+	t.Run("error on reserved variable", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "vars-reserved")
+		err := os.MkdirAll(projectDir, 0755)
+		require.NoError(t, err)
+
+		readmeContent := `<!-- banner-title: Vars -->
+<!-- banner-var-BG0: #000000 -->`
+		err = os.WriteFile(filepath.Join(projectDir, "README.md"), []byte(readmeContent), 0644)
+		require.NoError(t, err)
+
+		err = generateBanner(context.Background(), projectDir, testOptions("light", "center"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "reserved")
+	})
+
+	t.Run("error on invalid config", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "vars-bad-config")
+		err := os.MkdirAll(projectDir, 0755)
+		require.NoError(t, err)
+
+		err = os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Vars -->"), 0644)
+		require.NoError(t, err)
+		err = os.WriteFile(filepath.Join(projectDir, configFileName), []byte("vars: [broken"), 0644)
+		require.NoError(t, err)
+
+		err = generateBanner(context.Background(), projectDir, testOptions("light", "center"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse config")
+	})
+}
+
+func TestGenerateBannerCustomTemplate(t *testing.T) {
+	tempDir := t.TempDir()
+	template := `<svg xmlns="http://www.w3.org/2000/svg" width="400" height="100">
+  <stop id="bg-stop-0" stop-color="#000000"/>
+  <text id="title">Title</text>
+  <text>{{VERSION}}</text>
+</svg>`
+
+	t.Run("template flag", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "flag")
+		require.NoError(t, os.MkdirAll(projectDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Custom -->\n<!-- banner-var-VERSION: 2.0 -->"), 0644))
+		templatePath := filepath.Join(tempDir, "design.svg")
+		require.NoError(t, os.WriteFile(templatePath, []byte(template), 0644))
+
+		opts := testOptions("dark", "center")
+		opts.Template = templatePath
+		err := generateBanner(context.Background(), projectDir, opts)
+		require.NoError(t, err)
+
+		svgContent, err := os.ReadFile(filepath.Join(projectDir, "banner.svg"))
+		require.NoError(t, err)
+		darkTheme, _ := getTheme("dark")
+		assert.Contains(t, string(svgContent), `<text id="title">Custom</text>`)
+		assert.Contains(t, string(svgContent), "<text>2.0</text>")
+		assert.Contains(t, string(svgContent), darkTheme.Background.Stops[0].Color)
+	})
+
+	t.Run("template from config is relative to project", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "config")
+		require.NoError(t, os.MkdirAll(projectDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Configured -->"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, "design.svg"), []byte(template), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, configFileName), []byte("template: design.svg\nvars:\n  version: 3.0\n"), 0644))
+
+		err := generateBanner(context.Background(), projectDir, testOptions("light", "center"))
+		require.NoError(t, err)
+
+		svgContent, err := os.ReadFile(filepath.Join(projectDir, "banner.svg"))
+		require.NoError(t, err)
+		assert.Contains(t, string(svgContent), `<text id="title">Configured</text>`)
+		assert.Contains(t, string(svgContent), "<text>3.0</text>")
+	})
+
+	t.Run("undefined template variable", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "undefined")
+		require.NoError(t, os.MkdirAll(projectDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Missing -->"), 0644))
+
+		opts := testOptions("light", "center")
+		opts.Template = filepath.Join(tempDir, "design.svg")
+		err := generateBanner(context.Background(), projectDir, opts)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "undefined variables: VERSION")
+	})
+}
+
+func TestVarFlags(t *testing.T) {
+	vars := varFlags{}
+	require.NoError(t, vars.Set("version=1.2.0"))
+	require.NoError(t, vars.Set("URL=https://example.com/?a=b"))
+	assert.Equal(t, "1.2.0", vars["VERSION"])
+	assert.Equal(t, "https://example.com/?a=b", vars["URL"])
+
+	assert.Error(t, vars.Set("NOVALUE"))
+	assert.Error(t, vars.Set("bad-name=x"))
+}
+
 func TestGenerateBannerPNGOutput(t *testing.T) {
 	tempDir := t.TempDir()
 	projectDir := filepath.Join(tempDir, "png-test")
@@ -221,7 +349,7 @@ func TestGenerateBannerPNGOutput(t *testing.T) {
 	err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 	require.NoError(t, err)
 
-	err = generateBanner(projectDir, "light", "center")
+	err = generateBanner(context.Background(), projectDir, testOptions("light", "center"))
 	require.NoError(t, err)
 
 	pngPath := filepath.Join(projectDir, "banner.png")
@@ -262,7 +390,7 @@ func TestGenerateBannerIntegration(t *testing.T) {
 				err = os.WriteFile(readmePath, []byte(readmeContent), 0644)
 				require.NoError(t, err)
 
-				err = generateBanner(projectDir, theme, align)
+				err = generateBanner(context.Background(), projectDir, testOptions(theme, align))
 				require.NoError(t, err)
 
 				svgPath := filepath.Join(projectDir, "banner.svg")
@@ -277,3 +405,339 @@ func TestGenerateBannerIntegration(t *testing.T) {
 		}
 	}
 }
+
+func testOptions(theme, align string) Options {
+	opts := defaultOptions()
+	opts.Theme = theme
+	opts.Align = align
+	return opts
+}
+
+func TestRunCacheCommand(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	dir, err := resvgCacheDir()
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+
+	require.NoError(t, runCacheCommand([]string{"clear"}))
+	assert.NoDirExists(t, dir)
+
+	assert.Error(t, runCacheCommand(nil))
+	assert.Error(t, runCacheCommand([]string{"purge"}))
+}
+
+func TestGenerateBannerFormats(t *testing.T) {
+	withRenderers(t, fakeRenderer{name: "good", available: true})
+
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Formats -->"), 0644))
+
+	opts := testOptions("light", "center")
+	opts.Renderer = "good"
+	opts.Format = "webp,jpg"
+	require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+
+	for name, exists := range map[string]bool{"banner.svg": true, "banner.webp": true, "banner.jpg": true, "banner.png": false} {
+		_, err := os.Stat(filepath.Join(projectDir, name))
+		assert.Equal(t, exists, err == nil, name)
+	}
+
+	opts.Format = "bmp"
+	assert.ErrorContains(t, generateBanner(context.Background(), projectDir, opts), "unknown format")
+
+	opts.Format = "jpeg"
+	opts.Quality = 0
+	assert.ErrorContains(t, generateBanner(context.Background(), projectDir, opts), "invalid quality")
+}
+
+func TestGenerateBannerMinify(t *testing.T) {
+	withRenderers(t, fakeRenderer{name: "good", available: true})
+
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Minified -->"), 0644))
+
+	opts := testOptions("dark", "left")
+	opts.Renderer = "good"
+	require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+	full, err := os.ReadFile(filepath.Join(projectDir, "banner.svg"))
+	require.NoError(t, err)
+
+	opts.Minify = true
+	require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+	minified, err := os.ReadFile(filepath.Join(projectDir, "banner.svg"))
+	require.NoError(t, err)
+
+	assert.Less(t, len(minified), len(full))
+	assert.NotContains(t, string(minified), "<!--")
+	assert.Contains(t, string(minified), ">Minified</text>")
+
+	// Identical inputs give identical bytes.
+	require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+	again, err := os.ReadFile(filepath.Join(projectDir, "banner.svg"))
+	require.NoError(t, err)
+	assert.Equal(t, minified, again)
+}
+
+func TestGenerateBannerFonts(t *testing.T) {
+	var last RenderOptions
+	withRenderers(t, fakeRenderer{name: "good", available: true, opts: &last})
+
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Fonts -->"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(projectDir, "fonts"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, configFileName), []byte("font_dirs: [fonts]\n"), 0644))
+	extra := filepath.Join(t.TempDir(), "Extra.ttf")
+	require.NoError(t, os.WriteFile(extra, []byte("font"), 0644))
+
+	opts := testOptions("light", "center")
+	opts.Renderer = "good"
+	opts.FontFiles = []string{extra}
+	require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+	assert.Equal(t, Fonts{Dirs: []string{filepath.Join(projectDir, "fonts")}, Files: []string{extra}}, last.Fonts)
+
+	opts.SystemFonts = true
+	require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+	assert.True(t, last.Fonts.System)
+
+	opts.FontFiles = []string{filepath.Join(projectDir, "missing.ttf")}
+	err := generateBanner(context.Background(), projectDir, opts)
+	assert.ErrorContains(t, err, "invalid font file")
+}
+
+func TestGenerateBannerShortcodes(t *testing.T) {
+	withRenderers(t, fakeRenderer{name: "good", available: true})
+
+	projectDir := t.TempDir()
+	readme := "<!-- banner-title: :rocket: Launch -->\n<!-- banner-tagline: Built with :nf-dev-go: & :heart: -->"
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte(readme), 0644))
+
+	opts := testOptions("light", "center")
+	opts.Renderer = "good"
+	require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+
+	svg, err := os.ReadFile(filepath.Join(projectDir, "banner.svg"))
+	require.NoError(t, err)
+	// Non-ASCII text is written as character references.
+	assert.Contains(t, string(svg), ">&#128640; Launch</text>")
+	assert.Contains(t, string(svg), ">Built with &#59172; &amp; &#10084;&#65039;</text>")
+}
+
+func TestGenerateBannerColorEmoji(t *testing.T) {
+	withRenderers(t, fakeRenderer{name: "good", available: true})
+
+	projectDir := t.TempDir()
+	readme := "<!-- banner-title: :rocket: Launch -->\n<!-- banner-tagline: Made with :heart: -->"
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte(readme), 0644))
+	readSVG := func(t *testing.T) string {
+		t.Helper()
+		svg, err := os.ReadFile(filepath.Join(projectDir, "banner.svg"))
+		require.NoError(t, err)
+		return string(svg)
+	}
+
+	t.Run("embedded glyphs", func(t *testing.T) {
+		opts := testOptions("light", "center")
+		opts.Renderer = "good"
+		opts.ColorEmoji = true
+		require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+
+		svg := readSVG(t)
+		assert.Contains(t, svg, `<symbol id="emoji-1f680" viewBox="0 0 36 36">`)
+		assert.Contains(t, svg, `<use href="#emoji-1f680"`)
+		assert.Contains(t, svg, `<use href="#emoji-2764"`)
+		assert.NotContains(t, svg, "&#128640;")
+	})
+
+	t.Run("emoji directory from config", func(t *testing.T) {
+		glyphDir := filepath.Join(projectDir, "glyphs")
+		require.NoError(t, os.Mkdir(glyphDir, 0755))
+		glyph := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 72 72"><circle cx="36" cy="36" r="30" fill="#ABCDEF"/></svg>`
+		require.NoError(t, os.WriteFile(filepath.Join(glyphDir, "1f680.svg"), []byte(glyph), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, configFileName), []byte("emoji_dir: glyphs\n"), 0644))
+		t.Cleanup(func() { os.Remove(filepath.Join(projectDir, configFileName)) })
+
+		opts := testOptions("light", "center")
+		opts.Renderer = "good"
+		require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+
+		svg := readSVG(t)
+		assert.Contains(t, svg, `<symbol id="emoji-1f680" viewBox="0 0 72 72">`)
+		assert.Contains(t, svg, `fill="#ABCDEF"`)
+		// The directory has no heart, which stays text.
+		assert.Contains(t, svg, "Made with &#10084;&#65039;")
+	})
+
+	t.Run("missing emoji directory", func(t *testing.T) {
+		opts := testOptions("light", "center")
+		opts.Renderer = "good"
+		opts.EmojiDir = filepath.Join(projectDir, "missing")
+		err := generateBanner(context.Background(), projectDir, opts)
+		assert.ErrorContains(t, err, "invalid emoji directory")
+	})
+}
+
+func TestGenerateBannerCanceled(t *testing.T) {
+	withRenderers(t, fakeRenderer{name: "stuck", available: true, hang: true})
+
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Canceled -->"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	// Even in auto mode, where render failures are only warnings, an
+	// interrupt stops the run before any file is written.
+	opts := testOptions("light", "center")
+	opts.Renderer = "auto"
+	saved := defaultRendererOrder
+	t.Cleanup(func() { defaultRendererOrder = saved })
+	defaultRendererOrder = []string{"stuck"}
+
+	err := generateBanner(ctx, projectDir, opts)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = os.Stat(filepath.Join(projectDir, "banner.svg"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGenerateBannerLogo(t *testing.T) {
+	withRenderers(t, fakeRenderer{name: "good", available: true})
+
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Kit -->"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "logo.svg"), []byte(testLogoSVG), 0644))
+	readSVG := func(t *testing.T) string {
+		t.Helper()
+		svg, err := os.ReadFile(filepath.Join(projectDir, "banner.svg"))
+		require.NoError(t, err)
+		return string(svg)
+	}
+
+	t.Run("logo is detected and inlined", func(t *testing.T) {
+		opts := testOptions("light", "right")
+		opts.Renderer = "good"
+		require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+		assert.Contains(t, readSVG(t), `<g id="logo"><svg viewBox="0 0 64 32" fill="none" x="1100" y="263" width="260" height="130">`)
+	})
+
+	t.Run("no-logo skips it", func(t *testing.T) {
+		opts := testOptions("light", "right")
+		opts.Renderer = "good"
+		opts.NoLogo = true
+		require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+		assert.NotContains(t, readSVG(t), `id="logo"`)
+	})
+
+	t.Run("theme from the logo", func(t *testing.T) {
+		opts := testOptions(fromLogoTheme, "left")
+		opts.Renderer = "good"
+		require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+		logo, err := loadLogo(filepath.Join(projectDir, "logo.svg"))
+		require.NoError(t, err)
+		theme, err := logoTheme(logo)
+		require.NoError(t, err)
+		assert.Contains(t, readSVG(t), `<linearGradient id="bgGradient" x1="0" y1="0" x2="1" y2="1"><stop offset="0%" stop-color="`+theme.Background.Stops[0].Color+`"/>`)
+
+		opts.NoLogo = true
+		err = generateBanner(context.Background(), projectDir, opts)
+		assert.ErrorContains(t, err, "theme from-logo needs a logo")
+	})
+
+	t.Run("png logo is drawn onto resvg-wasm output", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("<!-- banner-title: Kit -->"), 0644))
+		logo := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				logo.Set(x, y, color.NRGBA{R: 255, A: 255})
+			}
+		}
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, logo))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "logo.png"), buf.Bytes(), 0644))
+
+		opts := testOptions("light", "left")
+		opts.Renderer = "resvg-wasm"
+		opts.Minify = true
+		require.NoError(t, generateBanner(context.Background(), dir, opts))
+		data, err := os.ReadFile(filepath.Join(dir, "banner.png"))
+		require.NoError(t, err)
+		img, err := png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		// The logo box is 240,263 130x130.
+		for _, p := range []image.Point{{245, 268}, {305, 328}, {365, 388}} {
+			assert.Equal(t, color.NRGBA{255, 0, 0, 255}, color.NRGBAModel.Convert(img.At(p.X, p.Y)), p)
+		}
+
+		// The resolution survives drawing the logo.
+		opts.DPI = 300
+		require.NoError(t, generateBanner(context.Background(), dir, opts))
+		data, err = os.ReadFile(filepath.Join(dir, "banner.png"))
+		require.NoError(t, err)
+		i := bytes.Index(data, []byte("pHYs"))
+		require.Positive(t, i)
+		assert.Equal(t, uint32(11811), binary.BigEndian.Uint32(data[i+4:i+8]))
+	})
+
+	t.Run("missing logo is an error", func(t *testing.T) {
+		opts := testOptions("light", "right")
+		opts.Renderer = "good"
+		opts.Logo = filepath.Join(projectDir, "missing.png")
+		err := generateBanner(context.Background(), projectDir, opts)
+		assert.ErrorContains(t, err, "failed to read logo")
+	})
+}
+
+func TestGenerateBannerBackground(t *testing.T) {
+	withRenderers(t, fakeRenderer{name: "good", available: true})
+
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Kit -->"), 0644))
+	generate := func(t *testing.T, opts Options) string {
+		t.Helper()
+		opts.Renderer = "good"
+		require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+		svg, err := os.ReadFile(filepath.Join(projectDir, "banner.svg"))
+		require.NoError(t, err)
+		return string(svg)
+	}
+
+	opts := testOptions("dark", "center")
+	waves := generate(t, opts)
+	assert.Equal(t, waves, generate(t, opts), "the same project gets the same background")
+
+	opts.Seed = "v2"
+	assert.NotEqual(t, waves, generate(t, opts))
+
+	opts.Background = "dots"
+	assert.Contains(t, generate(t, opts), `<g id="background-pattern"><g fill="#FFFFFF" fill-opacity="0.18"><circle`)
+
+	opts.Background = "stripes"
+	err := generateBanner(context.Background(), projectDir, opts)
+	assert.ErrorContains(t, err, `unknown background "stripes"`)
+}
+
+func TestGenerateBannerConfigTheme(t *testing.T) {
+	withRenderers(t, fakeRenderer{name: "good", available: true})
+
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), []byte("<!-- banner-title: Kit -->"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, ".banner.yml"), []byte(`themes:
+  brand:
+    background:
+      type: conic
+      stops: ["#FF5F6D", "#2C3E50"]
+`), 0644))
+
+	opts := testOptions("brand", "center")
+	opts.Renderer = "good"
+	require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+	svg, err := os.ReadFile(filepath.Join(projectDir, "banner.svg"))
+	require.NoError(t, err)
+	assert.Contains(t, string(svg), `<pattern id="bgGradient" patternUnits="userSpaceOnUse" width="1600" height="600"><path d="M800 300L800 -1408.8L`)
+
+	opts.Theme = "sunset"
+	err = generateBanner(context.Background(), projectDir, opts)
+	assert.ErrorContains(t, err, `unknown theme "sunset". Use: brand, dark, light, muted, pride`)
+}
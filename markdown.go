@@ -0,0 +1,448 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// inlineSpan is a run of text with the inline Markdown styles applied to it.
+type inlineSpan struct {
+	Text   string
+	Bold   bool
+	Italic bool
+	Code   bool
+}
+
+// markdownEscapes are the characters a backslash makes literal.
+const markdownEscapes = "\\`*_"
+
+// Code spans get a rounded highlight behind them, padded horizontally and
+// covering the ascent and descent, in em.
+const (
+	codePadding = 0.15
+	codeAscent  = 0.95
+	codeDescent = 0.3
+)
+
+// parseInlineMarkdown parses the inline subset used in README titles and
+// taglines: **bold** and __bold__, *emphasis* and _emphasis_, `code` and
+// backslash escapes. Emphasis nests; code is literal. Delimiters without a
+// match stay text, and _ inside words, as in snake_case, is not emphasis.
+func parseInlineMarkdown(s string) []inlineSpan {
+	var spans []inlineSpan
+	parseInline(s, inlineSpan{}, &spans)
+	return spans
+}
+
+func parseInline(s string, style inlineSpan, spans *[]inlineSpan) {
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			span := style
+			span.Text = text.String()
+			appendSpan(spans, span)
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(markdownEscapes, s[i+1]) >= 0:
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				flush()
+				code := style
+				code.Code = true
+				code.Text = s[i+1 : i+1+end]
+				appendSpan(spans, code)
+				i += end + 2
+				continue
+			}
+		case c == '*' || c == '_':
+			n := 1
+			if i+1 < len(s) && s[i+1] == c {
+				n = 2
+			}
+			if end := closingDelimiter(s, i, n); end > 0 {
+				flush()
+				inner := style
+				if n == 2 {
+					inner.Bold = true
+				} else {
+					inner.Italic = true
+				}
+				parseInline(s[i+n:end], inner, spans)
+				i = end + n
+				continue
+			}
+			text.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+		text.WriteByte(c)
+		i++
+	}
+	flush()
+}
+
+// closingDelimiter returns the index of the delimiter that closes the n
+// delimiter characters at open, or -1. In a run of three, as in ***text***,
+// the inner emphasis closes first.
+func closingDelimiter(s string, open, n int) int {
+	c := s[open]
+	start := open + n
+	if start >= len(s) || isMarkdownSpace(s[start]) || (c == '_' && open > 0 && isWordByte(s[open-1])) {
+		return -1
+	}
+	for j := start + 1; j < len(s); {
+		switch s[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			if end := strings.IndexByte(s[j+1:], '`'); end >= 0 {
+				j += end + 2
+				continue
+			}
+		case c:
+			run := 1
+			for j+run < len(s) && s[j+run] == c {
+				run++
+			}
+			after := j + run
+			closes := !isMarkdownSpace(s[j-1]) && (c != '_' || after == len(s) || !isWordByte(s[after]))
+			if closes && run >= n && (n == 2 || run != 2) {
+				return after - n
+			}
+			j = after
+			continue
+		}
+		j++
+	}
+	return -1
+}
+
+func isMarkdownSpace(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+// isWordByte reports whether b is part of a word. Bytes of multi-byte
+// characters count as letters.
+func isWordByte(b byte) bool {
+	return b >= 0x80 || b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// appendSpan appends span, merging it into the previous span of the same
+// style.
+func appendSpan(spans *[]inlineSpan, span inlineSpan) {
+	if n := len(*spans); n > 0 {
+		last := &(*spans)[n-1]
+		if last.Bold == span.Bold && last.Italic == span.Italic && last.Code == span.Code {
+			last.Text += span.Text
+			return
+		}
+	}
+	*spans = append(*spans, span)
+}
+
+// spansText returns the text of spans without formatting.
+func spansText(spans []inlineSpan) string {
+	var b strings.Builder
+	for _, span := range spans {
+		b.WriteString(span.Text)
+	}
+	return b.String()
+}
+
+// styled reports whether any span carries a style.
+func styled(spans []inlineSpan) bool {
+	for _, span := range spans {
+		if span.Bold || span.Italic || span.Code {
+			return true
+		}
+	}
+	return false
+}
+
+// attrs returns the <tspan> attributes of the span's styles. Code is set in
+// the embedded monospace font and marked with the "code" class for the
+// highlight.
+func (s inlineSpan) attrs() []SVGAttr {
+	var attrs []SVGAttr
+	if s.Code {
+		attrs = append(attrs, SVGAttr{Name: "class", Value: "code"}, SVGAttr{Name: "font-family", Value: quoteFontFamily(embeddedMonoFamily) + ", monospace"})
+	}
+	if s.Bold {
+		attrs = append(attrs, SVGAttr{Name: "font-weight", Value: "700"})
+	}
+	if s.Italic {
+		attrs = append(attrs, SVGAttr{Name: "font-style", Value: "italic"})
+	}
+	return attrs
+}
+
+// setSlotSpans fills a slot like setSlotText, with a <tspan> per styled
+// span. The spans are positioned with the text metrics so code highlights
+// line up; when the position cannot be read, they flow without highlights.
+func setSlotSpans(doc *SVGDocument, id string, spans []inlineSpan) {
+	if !styled(spans) {
+		setSlotText(doc, id, spansText(spans))
+		return
+	}
+	slot := slotTextTarget(doc, id)
+	if slot == nil {
+		return
+	}
+
+	runs := make([]textRun, len(spans))
+	for i, span := range spans {
+		runs[i] = textRun{Text: span.Text, Attrs: span.attrs()}
+	}
+	if err := layoutTextRuns(slot, runs); err == nil {
+		return
+	}
+	for _, child := range slot.Children {
+		child.Parent = nil
+	}
+	slot.Children = nil
+	for _, run := range runs {
+		if run.Attrs == nil {
+			slot.AppendChild(&SVGNode{Kind: TextNode, Text: run.Text})
+			continue
+		}
+		tspan := &SVGNode{Kind: ElementNode, Name: "tspan", Attrs: run.Attrs}
+		tspan.AppendChild(&SVGNode{Kind: TextNode, Text: run.Text})
+		slot.AppendChild(tspan)
+	}
+}
+
+// slotRuns reads back the runs of a slot element: its text, and a run with
+// the attributes of every <tspan> child, without their positions.
+func slotRuns(slot *SVGNode) []textRun {
+	var runs []textRun
+	for _, child := range slot.Children {
+		switch {
+		case child.Kind == TextNode:
+			runs = append(runs, textRun{Text: child.Text})
+		case child.Kind == ElementNode && child.Name == "tspan":
+			var attrs []SVGAttr
+			for _, attr := range child.Attrs {
+				switch attr.Name {
+				case "x", "y", "dx", "dy", "text-anchor":
+				default:
+					attrs = append(attrs, attr)
+				}
+			}
+			runs = append(runs, textRun{Text: child.TextContent(), Attrs: attrs})
+		}
+	}
+	return runs
+}
+
+// layoutTextRuns lays out runs from the position and anchor of a text
+// element. Text runs become <tspan>s with their own x, emoji runs <use>
+// elements after the enclosing <text>, and code runs get a highlight <rect>
+// before it. Text runs are set in the embedded font, which they are
+// measured with; runs it lacks a glyph for keep the template's fonts and an
+// estimated width. The elements of an earlier layout are replaced. The
+// element is left unchanged when its position cannot be read.
+func layoutTextRuns(slot *SVGNode, runs []textRun) error {
+	x, err := textPosition(slot, "x")
+	if err != nil {
+		return err
+	}
+	y, err := textPosition(slot, "y")
+	if err != nil {
+		return err
+	}
+	size := 16.0
+	if value := inheritedAttr(slot, "font-size"); value != "" {
+		size, err = strconv.ParseFloat(strings.TrimSuffix(value, "px"), 64)
+		if err != nil {
+			return fmt.Errorf("font-size %q is not a number", value)
+		}
+	}
+	bold := boldWeight(inheritedAttr(slot, "font-weight"))
+
+	// The spaces at the edges of a run are kept for the advance, but left
+	// out of code highlights.
+	type placed struct {
+		lead, width, advance float64
+		estimated            bool
+	}
+	measured := make([]placed, len(runs))
+	total := 0.0
+	for i, run := range runs {
+		if run.Emoji {
+			measured[i] = placed{width: emojiAdvance * size, advance: emojiAdvance * size}
+		} else {
+			face := metricFace{bold: bold}
+			for _, attr := range run.Attrs {
+				switch attr.Name {
+				case "font-weight":
+					face.bold = boldWeight(attr.Value)
+				case "font-family":
+					face.mono = strings.Contains(strings.ToLower(attr.Value), "mono")
+				}
+			}
+			measure := measureFace
+			if _, ok := missingGlyph(run.Text, face); ok {
+				// Another font draws the run, so its width can only be
+				// estimated.
+				measured[i].estimated = true
+				measure = estimateFace
+			}
+			trimmed := strings.TrimLeft(run.Text, " ")
+			if measured[i].lead, err = measure(run.Text[:len(run.Text)-len(trimmed)], size, face); err != nil {
+				return err
+			}
+			if measured[i].width, err = measure(strings.TrimRight(trimmed, " "), size, face); err != nil {
+				return err
+			}
+			if measured[i].advance, err = measure(run.Text, size, face); err != nil {
+				return err
+			}
+		}
+		total += measured[i].advance
+	}
+	anchor := inheritedAttr(slot, "text-anchor")
+	switch anchor {
+	case "middle":
+		x -= total / 2
+	case "end":
+		x -= total
+	}
+
+	container := slot
+	for container.Name != "text" && container.Parent != nil {
+		container = container.Parent
+	}
+	removeTextDecorations(container)
+	for _, child := range slot.Children {
+		child.Parent = nil
+	}
+	slot.Children = nil
+	// Every tspan starts a chunk of its own, anchored at its start. The
+	// element keeps its position and anchor for a later layout, and spaces
+	// at the edges of chunks are preserved.
+	slot.SetAttr("xml:space", "preserve")
+
+	fill := inheritedAttr(slot, "fill")
+	if fill == "" || fill == "none" {
+		fill = "#000000"
+	}
+	last := container
+	for i, run := range runs {
+		m := measured[i]
+		switch {
+		case run.Emoji:
+			inset := (emojiAdvance - 1) / 2 * size
+			use := &SVGNode{Kind: ElementNode, Name: "use", Attrs: []SVGAttr{
+				{Name: "href", Value: "#emoji-" + emojiKey(run.Text, false)},
+				{Name: "x", Value: formatNumber(x + inset)},
+				{Name: "y", Value: formatNumber(y - emojiAscent*size)},
+				{Name: "width", Value: formatNumber(size)},
+				{Name: "height", Value: formatNumber(size)},
+			}}
+			last.InsertAfter(use)
+			last = use
+		case strings.TrimSpace(run.Text) != "":
+			attrs := []SVGAttr{{Name: "x", Value: formatNumber(x)}}
+			if anchor == "middle" || anchor == "end" {
+				attrs = append(attrs, SVGAttr{Name: "text-anchor", Value: "start"})
+			}
+			// Measured runs are placed with the embedded font's metrics, so
+			// it has to draw them too; code runs already ask for its
+			// monospace face. Estimated runs keep the template's fonts.
+			if !m.estimated && !runHasAttr(run, "font-family") {
+				attrs = append(attrs, SVGAttr{Name: "font-family", Value: quoteFontFamily(embeddedFontFamily)})
+			}
+			tspan := &SVGNode{Kind: ElementNode, Name: "tspan", Attrs: append(attrs, run.Attrs...)}
+			tspan.AppendChild(&SVGNode{Kind: TextNode, Text: run.Text})
+			slot.AppendChild(tspan)
+			if runHasClass(run, "code") {
+				pad := codePadding * size
+				container.InsertBefore(&SVGNode{Kind: ElementNode, Name: "rect", Attrs: []SVGAttr{
+					{Name: "class", Value: "code-highlight"},
+					{Name: "x", Value: formatNumber(x + m.lead - pad)},
+					{Name: "y", Value: formatNumber(y - codeAscent*size)},
+					{Name: "width", Value: formatNumber(m.width + 2*pad)},
+					{Name: "height", Value: formatNumber((codeAscent + codeDescent) * size)},
+					{Name: "rx", Value: formatNumber(pad)},
+					{Name: "fill", Value: fill},
+					{Name: "fill-opacity", Value: "0.2"},
+				}})
+			}
+		}
+		x += m.advance
+	}
+	return nil
+}
+
+// removeTextDecorations removes the highlights and emoji placed around a
+// <text> by an earlier layout.
+func removeTextDecorations(text *SVGNode) {
+	parent := text.Parent
+	if parent == nil {
+		return
+	}
+	for {
+		var prev *SVGNode
+		for i := parent.childIndex(text) - 1; i >= 0; i-- {
+			if n := parent.Children[i]; n.Kind != TextNode || strings.TrimSpace(n.Text) != "" {
+				prev = n
+				break
+			}
+		}
+		if prev == nil || prev.Kind != ElementNode || prev.Name != "rect" || !prev.HasClass("code-highlight") {
+			break
+		}
+		prev.Remove()
+	}
+	idx := parent.childIndex(text)
+	for idx+1 < len(parent.Children) {
+		n := parent.Children[idx+1]
+		href, _ := n.Attr("href")
+		if n.Kind != ElementNode || n.Name != "use" || !strings.HasPrefix(href, "#emoji-") {
+			break
+		}
+		n.Remove()
+	}
+}
+
+func runHasAttr(run textRun, name string) bool {
+	for _, attr := range run.Attrs {
+		if attr.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func runHasClass(run textRun, class string) bool {
+	for _, attr := range run.Attrs {
+		if attr.Name == "class" {
+			for _, c := range strings.Fields(attr.Value) {
+				if c == class {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// boldWeight reports whether a font-weight selects the bold face.
+func boldWeight(weight string) bool {
+	switch weight {
+	case "bold", "bolder":
+		return true
+	}
+	w, err := strconv.Atoi(weight)
+	return err == nil && w >= 600
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInlineMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []inlineSpan
+	}{
+		{"plain text", "Banner Kit", []inlineSpan{{Text: "Banner Kit"}}},
+		{"bold", "Fast **and** small", []inlineSpan{{Text: "Fast "}, {Text: "and", Bold: true}, {Text: " small"}}},
+		{"underscore bold", "__Fast__", []inlineSpan{{Text: "Fast", Bold: true}}},
+		{"emphasis", "*really* fast", []inlineSpan{{Text: "really", Italic: true}, {Text: " fast"}}},
+		{"underscore emphasis", "_really_ fast", []inlineSpan{{Text: "really", Italic: true}, {Text: " fast"}}},
+		{"code", "Run `go test`", []inlineSpan{{Text: "Run "}, {Text: "go test", Code: true}}},
+		{"code is literal", "`**x**`", []inlineSpan{{Text: "**x**", Code: true}}},
+		{"bold inside emphasis", "*very **bold** claim*", []inlineSpan{{Text: "very ", Italic: true}, {Text: "bold", Bold: true, Italic: true}, {Text: " claim", Italic: true}}},
+		{"bold and emphasis", "***both***", []inlineSpan{{Text: "both", Bold: true, Italic: true}}},
+		{"code inside bold", "**use `make`**", []inlineSpan{{Text: "use ", Bold: true}, {Text: "make", Bold: true, Code: true}}},
+		{"snake case", "my_cool_tool", []inlineSpan{{Text: "my_cool_tool"}}},
+		{"unmatched delimiters", "5 * 3 and **open", []inlineSpan{{Text: "5 * 3 and **open"}}},
+		{"opening before space", "a ** b **", []inlineSpan{{Text: "a ** b **"}}},
+		{"closing after space", "*a *", []inlineSpan{{Text: "*a *"}}},
+		{"escapes", `\*not\* \_emphasis\_ \` + "`x`", []inlineSpan{{Text: "*not* _emphasis_ `x`"}}},
+		{"unclosed code", "a ` b", []inlineSpan{{Text: "a ` b"}}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseInlineMarkdown(tt.text))
+		})
+	}
+}
+
+func TestSetSlotSpans(t *testing.T) {
+	theme, err := getTheme("light")
+	require.NoError(t, err)
+
+	t.Run("styled tspans and code highlight", func(t *testing.T) {
+		metadata := &Metadata{Name: "Banner Kit", Tagline: "Made *fast* with `go`"}
+		svg, err := generateSVG(metadata, theme, "center", nil, defaultCanvas)
+		require.NoError(t, err)
+		doc, err := parseSVG(svg)
+		require.NoError(t, err)
+
+		tagline := doc.Root.FindByID("tagline")
+		space, _ := tagline.Attr("xml:space")
+		assert.Equal(t, "preserve", space)
+		assert.Equal(t, "Made fast with go", tagline.TextContent())
+		tspans := tagline.FindByName("tspan")
+		require.Len(t, tspans, 4)
+		style, _ := tspans[1].Attr("font-style")
+		assert.Equal(t, "italic", style)
+		assert.True(t, tspans[3].HasClass("code"))
+		family, _ := tspans[3].Attr("font-family")
+		assert.Equal(t, "'DejaVu Sans Mono', monospace", family)
+
+		// The runs are centered as a whole, each at its measured offset.
+		size := computeLayout(defaultCanvas, "center").TaglineFontSize
+		total, err := measureSpans(parseInlineMarkdown(metadata.Tagline), size, false)
+		require.NoError(t, err)
+		start := float64(defaultCanvas.Width)/2 - total/2
+		x, _ := tspans[0].Attr("x")
+		assert.Equal(t, formatNumber(start), x)
+		code, err := measureText("Made fast with ", size, false)
+		require.NoError(t, err)
+		x, _ = tspans[3].Attr("x")
+		assert.Equal(t, formatNumber(start+code), x)
+
+		highlights := doc.Root.FindByClass("code-highlight")
+		require.Len(t, highlights, 1)
+		assert.Equal(t, tagline, nextElement(highlights[0]))
+		hx, _ := highlights[0].Attr("x")
+		assert.Equal(t, formatNumber(start+code-codePadding*size), hx)
+
+		// The title has no Markdown and stays a single text node.
+		title := doc.Root.FindByID("title")
+		assert.Empty(t, title.FindByName("tspan"))
+		assert.Equal(t, "Banner Kit", title.TextContent())
+	})
+
+	t.Run("placeholders get plain text", func(t *testing.T) {
+		template := `<svg xmlns="http://www.w3.org/2000/svg"><title>{{PROJECT_NAME}}</title><desc>{{TAGLINE}}</desc></svg>`
+		svg, err := renderTemplate(template, &Metadata{Name: "**Kit**", Tagline: "`go` tool"}, theme, "center", nil, defaultCanvas)
+		require.NoError(t, err)
+		assert.Contains(t, svg, "<title>Kit</title><desc>go tool</desc>")
+	})
+
+	t.Run("unpositioned text flows", func(t *testing.T) {
+		template := `<svg xmlns="http://www.w3.org/2000/svg"><text id="tagline">x</text></svg>`
+		svg, err := renderTemplate(template, &Metadata{Tagline: "a **b** `c`"}, theme, "center", nil, defaultCanvas)
+		require.NoError(t, err)
+		assert.Contains(t, svg, `<text id="tagline">a <tspan font-weight="700">b</tspan> <tspan class="code" font-family="'DejaVu Sans Mono', monospace">c</tspan></text>`)
+		assert.NotContains(t, svg, "code-highlight")
+	})
+}
+
+func TestLayoutTextRuns(t *testing.T) {
+	theme, err := getTheme("dark")
+	require.NoError(t, err)
+	svg, err := generateSVG(&Metadata{Name: "Kit", Tagline: "\U0001F680 Ship `v2` today"}, theme, "left", nil, defaultCanvas)
+	require.NoError(t, err)
+
+	glyphs, err := emojiGlyphSet("")
+	require.NoError(t, err)
+	out, warnings, err := replaceColorEmoji(svg, glyphs)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	doc, err := parseSVG(out)
+	require.NoError(t, err)
+	tagline := doc.Root.FindByID("tagline")
+	assert.Equal(t, " Ship v2 today", tagline.TextContent())
+	code := tagline.FindByClass("code")
+	require.Len(t, code, 1)
+
+	// The highlight moved with the code, which now follows the emoji.
+	highlights := doc.Root.FindByClass("code-highlight")
+	require.Len(t, highlights, 1)
+	codeX, _ := code[0].Attr("x")
+	highlightX, _ := highlights[0].Attr("x")
+	size := computeLayout(defaultCanvas, "left").TaglineFontSize
+	assert.InDelta(t, parseNumber(t, codeX)-codePadding*size, parseNumber(t, highlightX), 0.011)
+	assert.Len(t, doc.Root.FindByName("use"), 1)
+	tspans := tagline.FindByName("tspan")
+	family, _ := tspans[0].Attr("font-family")
+	assert.Equal(t, "'DejaVu Sans'", family, "drawn with the font it was measured with")
+
+	// Laying out once more replaces the previous highlights and glyphs.
+	require.NoError(t, layoutTextRuns(tagline, slotRuns(tagline)))
+	assert.Len(t, doc.Root.FindByClass("code-highlight"), 1)
+	assert.Empty(t, doc.Root.FindByName("use"))
+
+	// Text the embedded font cannot draw keeps the template's fonts and an
+	// estimated width of one em per CJK character.
+	runs := []textRun{{Text: "今日 "}, {Text: "Ship"}}
+	require.NoError(t, layoutTextRuns(tagline, runs))
+	tspans = tagline.FindByName("tspan")
+	require.Len(t, tspans, 2)
+	_, pinned := tspans[0].Attr("font-family")
+	assert.False(t, pinned)
+	space, err := measureText(" ", size, false)
+	require.NoError(t, err)
+	x0, _ := tspans[0].Attr("x")
+	x1, _ := tspans[1].Attr("x")
+	assert.InDelta(t, 2*size+space, parseNumber(t, x1)-parseNumber(t, x0), 0.011)
+}
+
+func TestBoldWeight(t *testing.T) {
+	for weight, want := range map[string]bool{"": false, "400": false, "normal": false, "600": true, "700": true, "bold": true, "bolder": true} {
+		assert.Equal(t, want, boldWeight(weight), weight)
+	}
+}
+
+// nextElement returns the next element sibling of n.
+func nextElement(n *SVGNode) *SVGNode {
+	siblings := n.Parent.Children
+	for i := n.Parent.childIndex(n) + 1; i < len(siblings); i++ {
+		if siblings[i].Kind == ElementNode {
+			return siblings[i]
+		}
+	}
+	return nil
+}
+
+func parseNumber(t *testing.T, s string) float64 {
+	t.Helper()
+	var v float64
+	_, err := fmt.Sscan(s, &v)
+	require.NoError(t, err)
+	return v
+}
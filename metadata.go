@@ -11,11 +11,22 @@ import (
 type Metadata struct {
 	Name    string
 	Tagline string
+	Vars    map[string]string
+	// LogoPath is the banner-logo marker, relative to the project directory.
+	LogoPath string
+	// Logo is the loaded project logo, if any.
+	Logo *Logo
+	// Background is the generated background style, and Seed the text its
+	// shapes are derived from instead of the name.
+	Background string
+	Seed       string
 }
 
 func parseReadmeMetadata(content string) (*Metadata, error) {
 	titleRe := regexp.MustCompile(`<!--\s*banner-title:\s*(.+?)\s*-->`)
 	taglineRe := regexp.MustCompile(`<!--\s*banner-tagline:\s*(.+?)\s*-->`)
+	logoRe := regexp.MustCompile(`<!--\s*banner-logo:\s*(.+?)\s*-->`)
+	varRe := regexp.MustCompile(`<!--\s*banner-var-([A-Za-z0-9_]+):\s*(.*?)\s*-->`)
 
 	titleMatch := titleRe.FindStringSubmatch(content)
 	taglineMatch := taglineRe.FindStringSubmatch(content)
@@ -27,12 +38,27 @@ func parseReadmeMetadata(content string) (*Metadata, error) {
 	metadata := &Metadata{
 		Name:    strings.TrimSpace(titleMatch[1]),
 		Tagline: "",
+		Vars:    make(map[string]string),
 	}
 
 	if taglineMatch != nil {
 		metadata.Tagline = strings.TrimSpace(taglineMatch[1])
 	}
 
+	if logoMatch := logoRe.FindStringSubmatch(content); logoMatch != nil {
+		metadata.LogoPath = logoMatch[1]
+	}
+
+	for _, match := range varRe.FindAllStringSubmatch(content, -1) {
+		name, err := normalizeVarName(match[1])
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := metadata.Vars[name]; !ok {
+			metadata.Vars[name] = match[2]
+		}
+	}
+
 	return metadata, nil
 }
 
@@ -110,6 +110,52 @@ More content here`,
 	}
 }
 
+func TestParseReadmeMetadataLogo(t *testing.T) {
+	metadata, err := parseReadmeMetadata("<!-- banner-title: Project -->\n<!--  banner-logo:  assets/logo.svg  -->")
+	require.NoError(t, err)
+	assert.Equal(t, "assets/logo.svg", metadata.LogoPath)
+
+	metadata, err = parseReadmeMetadata("<!-- banner-title: Project -->")
+	require.NoError(t, err)
+	assert.Empty(t, metadata.LogoPath)
+}
+
+func TestParseReadmeMetadataVars(t *testing.T) {
+	t.Run("variables are collected and normalized", func(t *testing.T) {
+		content := `<!-- banner-title: Project -->
+<!-- banner-var-VERSION: 1.2.0 -->
+<!--   banner-var-author:   Jane & John   -->
+<!-- banner-var-URL: https://example.com -->`
+
+		metadata, err := parseReadmeMetadata(content)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"VERSION": "1.2.0",
+			"AUTHOR":  "Jane & John",
+			"URL":     "https://example.com",
+		}, metadata.Vars)
+	})
+
+	t.Run("first definition wins", func(t *testing.T) {
+		content := `<!-- banner-title: Project -->
+<!-- banner-var-VERSION: 1.0 -->
+<!-- banner-var-version: 2.0 -->`
+
+		metadata, err := parseReadmeMetadata(content)
+		require.NoError(t, err)
+		assert.Equal(t, "1.0", metadata.Vars["VERSION"])
+	})
+
+	t.Run("invalid variable name", func(t *testing.T) {
+		content := `<!-- banner-title: Project -->
+<!-- banner-var-1ST: value -->`
+
+		metadata, err := parseReadmeMetadata(content)
+		assert.Error(t, err)
+		assert.Nil(t, metadata)
+	})
+}
+
 func TestReadProjectMetadata(t *testing.T) {
 	// Create temporary directory for tests
 	tempDir := t.TempDir()
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// minifyPrecision is the number of decimals kept in numeric attributes.
+// Banners are laid out in whole pixels, so a thousandth is invisible.
+const minifyPrecision = 3
+
+// numericAttrs hold numbers, lengths or lists of them.
+var numericAttrs = map[string]bool{
+	"x": true, "y": true, "x1": true, "y1": true, "x2": true, "y2": true,
+	"cx": true, "cy": true, "r": true, "rx": true, "ry": true, "fx": true, "fy": true,
+	"dx": true, "dy": true, "width": true, "height": true, "viewBox": true,
+	"d": true, "points": true, "transform": true, "gradientTransform": true,
+	"offset": true, "opacity": true, "fill-opacity": true, "stroke-opacity": true,
+	"stop-opacity": true, "stroke-width": true, "font-size": true,
+	"letter-spacing": true, "stdDeviation": true,
+}
+
+// inheritedAttrs are presentation attributes that children inherit, so a
+// child repeating its parent's value is redundant.
+var inheritedAttrs = map[string]bool{
+	"fill": true, "fill-opacity": true, "stroke": true, "stroke-opacity": true,
+	"stroke-width": true, "font-family": true, "font-size": true,
+	"font-weight": true, "font-style": true, "text-anchor": true,
+	"letter-spacing": true,
+}
+
+// defaultAttrs are attribute values that equal the SVG initial value.
+// Inherited ones are only dropped when no ancestor sets the attribute.
+var defaultAttrs = map[string]string{
+	"opacity": "1", "fill-opacity": "1", "stroke-opacity": "1",
+	"stop-opacity": "1", "font-weight": "400", "font-style": "normal",
+	"text-anchor": "start",
+}
+
+// zeroPositioned are elements whose x and y default to 0. Others, such as
+// tspan and pattern, treat a missing position differently.
+var zeroPositioned = map[string]bool{"rect": true, "image": true, "use": true, "text": true}
+
+// removableWhenEmpty are elements that draw nothing without children.
+var removableWhenEmpty = map[string]bool{"g": true, "defs": true, "text": true}
+
+var (
+	numberPattern    = regexp.MustCompile(`[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+	pathSpacePattern = regexp.MustCompile(`\s*,\s*|\s+`)
+	pathLetterSpace  = regexp.MustCompile(`\s*([A-Za-z])\s*`)
+	pathMinusSpace   = regexp.MustCompile(` (-)`)
+	idRefPattern     = regexp.MustCompile(`#([A-Za-z_][\w.:-]*)`)
+)
+
+// minifySVG removes comments and formatting whitespace, shortens numbers,
+// drops attributes that repeat inherited or default values, hoists
+// attributes shared by all children of a group, and removes empty groups
+// such as unused badges. The output depends only on the input.
+func minifySVG(svg string) (string, error) {
+	doc, err := parseSVG(svg)
+	if err != nil {
+		return "", err
+	}
+
+	var prolog []*SVGNode
+	for _, node := range doc.Prolog {
+		if node.Kind != CommentNode {
+			prolog = append(prolog, node)
+		}
+	}
+	doc.Prolog = prolog
+
+	stripComments(doc.Root)
+	stripWhitespace(doc.Root)
+	doc.Root.Walk(func(n *SVGNode) bool {
+		for i, attr := range n.Attrs {
+			if numericAttrs[attr.Name] {
+				n.Attrs[i].Value = shortenNumbers(attr.Name, attr.Value)
+			}
+		}
+		return true
+	})
+	removeEmptyElements(doc.Root, referencedIDs(doc.Root))
+	hoistSharedAttrs(doc.Root)
+	dropRedundantAttrs(doc.Root, map[string]string{})
+
+	return doc.String(), nil
+}
+
+func stripComments(n *SVGNode) {
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		if child.Kind == CommentNode {
+			continue
+		}
+		stripComments(child)
+		children = append(children, child)
+	}
+	n.Children = children
+}
+
+// stripWhitespace removes whitespace-only text outside text content, where
+// it is only indentation.
+func stripWhitespace(n *SVGNode) {
+	if n.Name == "text" || n.Name == "style" {
+		return
+	}
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		if child.Kind == TextNode && strings.TrimSpace(child.Text) == "" {
+			continue
+		}
+		stripWhitespace(child)
+		children = append(children, child)
+	}
+	n.Children = children
+}
+
+// shortenNumbers rounds every number in value and drops redundant zeros.
+// Path data and point lists also lose separators that are not needed.
+func shortenNumbers(name, value string) string {
+	value = numberPattern.ReplaceAllStringFunc(value, shortenNumber)
+	if name == "d" || name == "points" {
+		value = strings.TrimSpace(pathSpacePattern.ReplaceAllString(value, " "))
+		value = pathLetterSpace.ReplaceAllString(value, "$1")
+		value = pathMinusSpace.ReplaceAllString(value, "$1")
+	}
+	return value
+}
+
+func shortenNumber(s string) string {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(v, 0) {
+		return s
+	}
+	scale := math.Pow10(minifyPrecision)
+	v = math.Round(v*scale) / scale
+	if v == 0 {
+		return "0"
+	}
+	out := strconv.FormatFloat(v, 'f', -1, 64)
+	if strings.HasPrefix(out, "0.") {
+		return out[1:]
+	}
+	if strings.HasPrefix(out, "-0.") {
+		return "-" + out[2:]
+	}
+	return out
+}
+
+// referencedIDs collects ids used through url(#id) or href="#id", so
+// elements they name are never removed.
+func referencedIDs(root *SVGNode) map[string]bool {
+	ids := make(map[string]bool)
+	root.Walk(func(n *SVGNode) bool {
+		for _, attr := range n.Attrs {
+			for _, m := range idRefPattern.FindAllStringSubmatch(attr.Value, -1) {
+				if attr.Name == "href" || attr.Name == "xlink:href" || strings.Contains(attr.Value, "url(") {
+					ids[m[1]] = true
+				}
+			}
+		}
+		return true
+	})
+	return ids
+}
+
+// removeEmptyElements removes childless groups, defs and text bottom-up, so
+// a group that only held empty groups goes too.
+func removeEmptyElements(n *SVGNode, referenced map[string]bool) {
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		removeEmptyElements(child, referenced)
+		if child.Kind == ElementNode && removableWhenEmpty[child.Name] && len(child.Children) == 0 {
+			if id, ok := child.Attr("id"); !ok || !referenced[id] {
+				child.Parent = nil
+				continue
+			}
+		}
+		children = append(children, child)
+	}
+	n.Children = children
+}
+
+// hoistSharedAttrs moves inherited attributes that every element child of a
+// group sets to the same value onto the group itself.
+func hoistSharedAttrs(n *SVGNode) {
+	for _, child := range n.Children {
+		hoistSharedAttrs(child)
+	}
+	if n.Name != "g" {
+		return
+	}
+
+	var elements []*SVGNode
+	for _, child := range n.Children {
+		if child.Kind == ElementNode {
+			elements = append(elements, child)
+		}
+	}
+	if len(elements) < 2 {
+		return
+	}
+
+	var hoisted []SVGAttr
+	for _, attr := range elements[0].Attrs {
+		if !inheritedAttrs[attr.Name] {
+			continue
+		}
+		if v, ok := n.Attr(attr.Name); ok && v != attr.Value {
+			continue
+		}
+		shared := true
+		for _, el := range elements[1:] {
+			if v, ok := el.Attr(attr.Name); !ok || v != attr.Value {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			hoisted = append(hoisted, attr)
+		}
+	}
+
+	for _, attr := range hoisted {
+		n.SetAttr(attr.Name, attr.Value)
+		for _, el := range elements {
+			el.RemoveAttr(attr.Name)
+		}
+	}
+}
+
+// dropRedundantAttrs removes inherited attributes equal to the value an
+// ancestor already set, and attributes equal to their initial value.
+func dropRedundantAttrs(n *SVGNode, inherited map[string]string) {
+	if n.Kind != ElementNode {
+		return
+	}
+
+	attrs := n.Attrs[:0]
+	for _, attr := range n.Attrs {
+		if isRedundantAttr(n.Name, attr, inherited) {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	n.Attrs = attrs
+
+	scope := inherited
+	copied := false
+	for _, attr := range n.Attrs {
+		if !inheritedAttrs[attr.Name] {
+			continue
+		}
+		if !copied {
+			scope = make(map[string]string, len(inherited)+1)
+			for k, v := range inherited {
+				scope[k] = v
+			}
+			copied = true
+		}
+		scope[attr.Name] = attr.Value
+	}
+	for _, child := range n.Children {
+		dropRedundantAttrs(child, scope)
+	}
+}
+
+func isRedundantAttr(element string, attr SVGAttr, inherited map[string]string) bool {
+	if (attr.Name == "x" || attr.Name == "y") && attr.Value == "0" {
+		return zeroPositioned[element]
+	}
+	parent, isSet := inherited[attr.Name]
+	if inheritedAttrs[attr.Name] && isSet {
+		return parent == attr.Value
+	}
+	value, ok := defaultAttrs[attr.Name]
+	return ok && value == attr.Value
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinifySVG(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "comments and indentation are removed",
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<!-- generated -->
+<svg width="10" height="10">
+  <!-- Background -->
+  <rect width="10" height="10" fill="red"/>
+</svg>`,
+			expected: `<?xml version="1.0" encoding="UTF-8"?>
+<svg width="10" height="10"><rect width="10" height="10" fill="red"/></svg>
+`,
+		},
+		{
+			name:     "whitespace inside text is kept",
+			input:    `<svg><text x="1"><tspan>a</tspan> <tspan>b</tspan></text></svg>`,
+			expected: `<svg><text x="1"><tspan>a</tspan> <tspan>b</tspan></text></svg>` + "\n",
+		},
+		{
+			name:     "numbers are rounded and zeros dropped",
+			input:    `<svg><rect x="10.00049" y="-0.5" width="0.20" height="1e2" fill-opacity="0.900"/></svg>`,
+			expected: `<svg><rect x="10" y="-.5" width=".2" height="100" fill-opacity=".9"/></svg>` + "\n",
+		},
+		{
+			name:     "path data loses separators",
+			input:    `<svg><path d="M0 430 C 260.0 360, 520 -20, 820 470 L 1600 600 Z"/></svg>`,
+			expected: `<svg><path d="M0 430C260 360 520-20 820 470L1600 600Z"/></svg>` + "\n",
+		},
+		{
+			name:     "default values are dropped",
+			input:    `<svg><rect x="0" y="0" width="5" height="5" opacity="1" fill-opacity="1"/></svg>`,
+			expected: `<svg><rect width="5" height="5"/></svg>` + "\n",
+		},
+		{
+			name:     "tspan keeps an explicit zero position",
+			input:    `<svg><text><tspan x="0">a</tspan></text></svg>`,
+			expected: `<svg><text><tspan x="0">a</tspan></text></svg>` + "\n",
+		},
+		{
+			name:     "inherited values repeated by children are dropped",
+			input:    `<svg fill="#fff"><g fill-opacity=".5"><rect fill="#fff" fill-opacity="1"/></g></svg>`,
+			expected: `<svg fill="#fff"><g fill-opacity=".5"><rect fill-opacity="1"/></g></svg>` + "\n",
+		},
+		{
+			name:     "attributes shared by all children move to the group",
+			input:    `<svg><g id="badge-1"><rect fill="#fff" rx="4"/><text fill="#fff" font-weight="600">v1</text></g></svg>`,
+			expected: `<svg><g id="badge-1" fill="#fff"><rect rx="4"/><text font-weight="600">v1</text></g></svg>` + "\n",
+		},
+		{
+			name: "empty badge groups are removed",
+			input: `<svg>
+  <g id="badges">
+    <!-- Badges -->
+    <g id="badge-1">
+    </g>
+  </g>
+  <text id="tagline"></text>
+  <rect width="1" height="1"/>
+</svg>`,
+			expected: `<svg><rect width="1" height="1"/></svg>` + "\n",
+		},
+		{
+			name:     "referenced empty elements are kept",
+			input:    `<svg><g id="empty"/><use href="#empty"/></svg>`,
+			expected: `<svg><g id="empty"/><use href="#empty"/></svg>` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := minifySVG(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+
+			// Minifying is stable.
+			again, err := minifySVG(result)
+			require.NoError(t, err)
+			assert.Equal(t, result, again)
+		})
+	}
+
+	_, err := minifySVG(`<svg>`)
+	assert.Error(t, err)
+}
+
+func TestMinifyGeneratedBanner(t *testing.T) {
+	theme, err := getTheme("dark")
+	require.NoError(t, err)
+	svg, err := generateSVG(&Metadata{Name: "Banner", Tagline: "Tagline"}, theme, "center", []string{"v1.0"}, defaultCanvas)
+	require.NoError(t, err)
+
+	minified, err := minifySVG(svg)
+	require.NoError(t, err)
+
+	assert.Less(t, len(minified), len(svg))
+	assert.NotContains(t, minified, "badge-2")
+	assert.Contains(t, minified, `id="badge-1"`)
+	assert.Contains(t, minified, "v1.0")
+	assert.Contains(t, minified, "url(#bgGradient)")
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Raster is a PNG rendered at one output scale.
+type Raster struct {
+	Scale    float64
+	PNG      []byte
+	Renderer string
+	// Options are the options the PNG was rendered with.
+	Options RenderOptions
+}
+
+// parseScales parses a comma-separated list of output scales such as "1,2".
+func parseScales(s string) ([]float64, error) {
+	var scales []float64
+	seen := make(map[float64]bool)
+	for _, part := range strings.Split(s, ",") {
+		scale, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(part), "x"), 64)
+		if err != nil || scale <= 0 || math.IsInf(scale, 0) {
+			return nil, fmt.Errorf("invalid scale %q. Use positive numbers, e.g. 1,2", part)
+		}
+		if seen[scale] {
+			return nil, fmt.Errorf("duplicate scale %q", part)
+		}
+		seen[scale] = true
+		scales = append(scales, scale)
+	}
+	return scales, nil
+}
+
+// rasterFileName follows the @2x convention: scale 1 keeps the plain name.
+func rasterFileName(base string, scale float64, ext string) string {
+	if scale == 1 {
+		return base + "." + ext
+	}
+	return fmt.Sprintf("%s@%sx.%s", base, formatNumber(scale), ext)
+}
+
+// outputSize returns the pixel size of a raster. Width and height set the
+// 1x size; when only one is given the other keeps the SVG aspect ratio. The
+// result is multiplied by scale. A zero size leaves the choice to the
+// renderer, which uses the intrinsic SVG size at dpi.
+func outputSize(svg string, width, height int, scale, dpi float64) (int, int, error) {
+	if width < 0 || height < 0 {
+		return 0, 0, fmt.Errorf("invalid output size %dx%d: width and height must not be negative", width, height)
+	}
+	if width == 0 && height == 0 && scale == 1 {
+		return 0, 0, nil
+	}
+
+	w, h := float64(width), float64(height)
+	if width == 0 || height == 0 {
+		iw, ih, err := intrinsicSize(svg, dpi)
+		if err != nil {
+			return 0, 0, err
+		}
+		switch {
+		case width == 0 && height == 0:
+			w, h = iw, ih
+		case width == 0:
+			w = h * iw / ih
+		default:
+			h = w * ih / iw
+		}
+	}
+
+	return int(math.Max(1, math.Round(w*scale))), int(math.Max(1, math.Round(h*scale))), nil
+}
+
+// intrinsicSize reads the size of the root element in pixels from its width
+// and height, converting physical units such as mm at dpi (96 when zero) as
+// the renderers do, or from its viewBox.
+func intrinsicSize(svg string, dpi float64) (float64, float64, error) {
+	doc, err := parseSVG(svg)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	width, okW := parseLength(doc.Root, "width", dpi)
+	height, okH := parseLength(doc.Root, "height", dpi)
+	if okW && okH {
+		return width, height, nil
+	}
+
+	if viewBox, ok := doc.Root.Attr("viewBox"); ok {
+		fields := strings.FieldsFunc(viewBox, func(r rune) bool { return r == ',' || r == ' ' })
+		if len(fields) == 4 {
+			vw, errW := strconv.ParseFloat(fields[2], 64)
+			vh, errH := strconv.ParseFloat(fields[3], 64)
+			if errW == nil && errH == nil && vw > 0 && vh > 0 {
+				return vw, vh, nil
+			}
+		}
+	}
+
+	return 0, 0, fmt.Errorf("cannot determine SVG size for scaling: set width and height in pixels or physical units, or a viewBox on the root element")
+}
+
+func parsePixels(n *SVGNode, attr string) (float64, bool) {
+	value, ok := n.Attr(attr)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// unitsPerInch are the physical length units of SVG and CSS.
+var unitsPerInch = map[string]float64{"in": 1, "cm": 2.54, "mm": 25.4, "Q": 101.6, "pt": 72, "pc": 6}
+
+// parseLength is parsePixels that also converts physical units at dpi.
+// Relative units such as % and em are not supported.
+func parseLength(n *SVGNode, attr string, dpi float64) (float64, bool) {
+	if v, ok := parsePixels(n, attr); ok {
+		return v, true
+	}
+	value, _ := n.Attr(attr)
+	value = strings.TrimSpace(value)
+	for unit, perInch := range unitsPerInch {
+		number, ok := strings.CutSuffix(value, unit)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil || v <= 0 {
+			return 0, false
+		}
+		if dpi <= 0 {
+			dpi = 96
+		}
+		return v / perInch * dpi, true
+	}
+	return 0, false
+}
+
+// validateRaster checks a renderer's output before it is written: it must
+// decode, have the requested size (or the SVG's own size, within a pixel of
+// rounding), and not be blank when the SVG draws something. An SVG with at
+// least two paints, or with text or images, must not render to one color.
+func validateRaster(svg string, data []byte, opts RenderOptions) error {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid output: %w", err)
+	}
+
+	size := img.Bounds().Size()
+	if opts.Width > 0 && opts.Height > 0 {
+		if size.X != opts.Width || size.Y != opts.Height {
+			return fmt.Errorf("invalid output: got %dx%d pixels, expected %dx%d", size.X, size.Y, opts.Width, opts.Height)
+		}
+	} else if w, h, err := intrinsicSize(svg, opts.DPI); err == nil {
+		if math.Abs(float64(size.X)-w) >= 1 || math.Abs(float64(size.Y)-h) >= 1 {
+			return fmt.Errorf("invalid output: got %dx%d pixels, expected %sx%s", size.X, size.Y, formatNumber(w), formatNumber(h))
+		}
+	}
+
+	paints := countPaints(svg)
+	if paints == 0 {
+		return nil
+	}
+	first, uniform := uniformColor(img)
+	switch {
+	case uniform && first.A == 0:
+		return fmt.Errorf("invalid output: image is fully transparent")
+	case uniform && paints > 1:
+		return fmt.Errorf("invalid output: image is a single color (#%02x%02x%02x)", first.R, first.G, first.B)
+	}
+	return nil
+}
+
+// countPaints returns the number of distinct fill, stroke and stop colors in
+// svg, counting text and images as one more each, capped at 2 since callers
+// only distinguish none, one and several.
+func countPaints(svg string) int {
+	doc, err := parseSVG(svg)
+	if err != nil {
+		return 0
+	}
+
+	paints := make(map[string]bool)
+	doc.Root.Walk(func(n *SVGNode) bool {
+		if n.Kind != ElementNode {
+			return true
+		}
+		switch n.Name {
+		case "text", "image":
+			paints["<"+n.Name+">"] = true
+		}
+		for _, attr := range n.Attrs {
+			switch attr.Name {
+			case "fill", "stroke", "stop-color":
+				addPaint(paints, attr.Value)
+			case "style":
+				for _, decl := range strings.Split(attr.Value, ";") {
+					name, value, ok := strings.Cut(decl, ":")
+					switch strings.TrimSpace(name) {
+					case "fill", "stroke", "stop-color":
+						if ok {
+							addPaint(paints, value)
+						}
+					}
+				}
+			}
+		}
+		return len(paints) < 2
+	})
+	return min(len(paints), 2)
+}
+
+// addPaint records a color. Gradients are counted through their stops.
+func addPaint(paints map[string]bool, value string) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case value == "", value == "none", value == "transparent", strings.HasPrefix(value, "url("):
+		return
+	}
+	paints[value] = true
+}
+
+// uniformColor reports whether every pixel of img has the same color, and
+// returns the first pixel.
+func uniformColor(img image.Image) (color.NRGBA, bool) {
+	b := img.Bounds()
+	first := color.NRGBAModel.Convert(img.At(b.Min.X, b.Min.Y)).(color.NRGBA)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			// Fully transparent pixels are equal whatever their color.
+			if c != first && (c.A != 0 || first.A != 0) {
+				return first, false
+			}
+		}
+	}
+	return first, true
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// setPNGDPI records the resolution in the pHYs chunk, replacing any existing
+// one, so both renderers produce the same metadata.
+func setPNGDPI(data []byte, dpi float64) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("failed to set DPI: not a PNG")
+	}
+
+	pixelsPerMeter := uint32(math.Round(dpi / 0.0254))
+	phys := make([]byte, 9)
+	binary.BigEndian.PutUint32(phys[0:4], pixelsPerMeter)
+	binary.BigEndian.PutUint32(phys[4:8], pixelsPerMeter)
+	phys[8] = 1 // unit: meter
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)+21))
+	out.Write(pngSignature)
+	for rest := data[len(pngSignature):]; len(rest) > 0; {
+		if len(rest) < 12 {
+			return nil, fmt.Errorf("failed to set DPI: truncated PNG")
+		}
+		length := binary.BigEndian.Uint32(rest[0:4])
+		if uint64(len(rest)) < 12+uint64(length) {
+			return nil, fmt.Errorf("failed to set DPI: truncated PNG")
+		}
+		chunk := rest[:12+length]
+		rest = rest[12+length:]
+
+		kind := string(chunk[4:8])
+		if kind == "pHYs" {
+			continue
+		}
+		out.Write(chunk)
+		if kind == "IHDR" {
+			writePNGChunk(out, "pHYs", phys)
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+func writePNGChunk(w *bytes.Buffer, kind string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	copy(header[4:8], kind)
+	w.Write(header[:])
+	w.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScales(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []float64
+		expectError bool
+	}{
+		{
+			name:     "single scale",
+			input:    "2",
+			expected: []float64{2},
+		},
+		{
+			name:     "list with x suffix and spaces",
+			input:    "1, 2x, 1.5",
+			expected: []float64{1, 2, 1.5},
+		},
+		{
+			name:        "zero",
+			input:       "0",
+			expectError: true,
+		},
+		{
+			name:        "not a number",
+			input:       "retina",
+			expectError: true,
+		},
+		{
+			name:        "duplicate",
+			input:       "2,2x",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scales, err := parseScales(tt.input)
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, scales)
+			}
+		})
+	}
+}
+
+func TestRasterFileName(t *testing.T) {
+	assert.Equal(t, "banner.png", rasterFileName("banner", 1, "png"))
+	assert.Equal(t, "banner@2x.png", rasterFileName("banner", 2, "png"))
+	assert.Equal(t, "banner@0.5x.png", rasterFileName("banner", 0.5, "png"))
+}
+
+func TestOutputSize(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="1600" height="600"/>`
+
+	tests := []struct {
+		name           string
+		svg            string
+		width, height  int
+		scale, dpi     float64
+		expectedWidth  int
+		expectedHeight int
+		expectError    bool
+	}{
+		{
+			name:  "defaults leave the intrinsic size to the renderer",
+			svg:   svg,
+			scale: 1,
+		},
+		{
+			name:           "scale",
+			svg:            svg,
+			scale:          2,
+			expectedWidth:  3200,
+			expectedHeight: 1200,
+		},
+		{
+			name:           "explicit width keeps aspect ratio",
+			svg:            svg,
+			width:          800,
+			scale:          1,
+			expectedWidth:  800,
+			expectedHeight: 300,
+		},
+		{
+			name:           "explicit height with scale",
+			svg:            svg,
+			height:         300,
+			scale:          2,
+			expectedWidth:  1600,
+			expectedHeight: 600,
+		},
+		{
+			name:           "explicit width and height",
+			svg:            `<svg/>`,
+			width:          1280,
+			height:         640,
+			scale:          1,
+			expectedWidth:  1280,
+			expectedHeight: 640,
+		},
+		{
+			name:           "size from viewBox",
+			svg:            `<svg viewBox="0 0 400 100"/>`,
+			scale:          1.5,
+			expectedWidth:  600,
+			expectedHeight: 150,
+		},
+		{
+			name:           "physical units at the DPI",
+			svg:            `<svg width="100mm" height="0.5in" viewBox="0 0 10 10"/>`,
+			scale:          2,
+			dpi:            192,
+			expectedWidth:  1512,
+			expectedHeight: 192,
+		},
+		{
+			name:           "physical units at the default DPI",
+			svg:            `<svg width="72pt" height="2.54cm"/>`,
+			scale:          2,
+			expectedWidth:  192,
+			expectedHeight: 192,
+		},
+		{
+			name:        "unknown intrinsic size",
+			svg:         `<svg width="100%" height="100%"/>`,
+			scale:       2,
+			expectError: true,
+		},
+		{
+			name:        "negative width",
+			svg:         svg,
+			width:       -1,
+			scale:       1,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h, err := outputSize(tt.svg, tt.width, tt.height, tt.scale, tt.dpi)
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedWidth, w)
+				assert.Equal(t, tt.expectedHeight, h)
+			}
+		})
+	}
+}
+
+func TestSetPNGDPI(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 2))))
+
+	data, err := setPNGDPI(buf.Bytes(), 144)
+	require.NoError(t, err)
+	// Setting it again replaces the chunk instead of adding another.
+	data, err = setPNGDPI(data, 300)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 4, img.Bounds().Dx())
+
+	assert.Equal(t, 1, bytes.Count(data, []byte("pHYs")))
+	i := bytes.Index(data, []byte("pHYs")) + 4
+	assert.Equal(t, uint32(11811), binary.BigEndian.Uint32(data[i:i+4]))
+	assert.Equal(t, uint32(11811), binary.BigEndian.Uint32(data[i+4:i+8]))
+	assert.Equal(t, byte(1), data[i+8])
+
+	_, err = setPNGDPI([]byte("not a png"), 96)
+	assert.Error(t, err)
+}
+
+func TestValidateRaster(t *testing.T) {
+	encode := func(img image.Image) []byte {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, img))
+		return buf.Bytes()
+	}
+	solid := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for i := 0; i < len(solid.Pix); i += 4 {
+		copy(solid.Pix[i:], []byte{0x24, 0x5a, 0x74, 0xff})
+	}
+	transparent := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	content := testImage(40, 20)
+
+	oneColor := `<svg width="40" height="20"><rect width="40" height="20" fill="#245A74"/></svg>`
+	withText := `<svg width="40" height="20"><rect width="40" height="20" fill="#245A74"/><text>Title</text></svg>`
+	gradient := `<svg width="40" height="20"><defs><linearGradient id="g"><stop stop-color="#000"/><stop style="stop-color:#fff"/></linearGradient></defs><rect width="40" height="20" fill="url(#g)"/></svg>`
+
+	tests := []struct {
+		name     string
+		svg      string
+		data     []byte
+		opts     RenderOptions
+		expected string
+	}{
+		{name: "valid", svg: withText, data: encode(content)},
+		{name: "requested size", svg: withText, data: encode(content), opts: RenderOptions{Width: 40, Height: 20}},
+		{name: "single color SVG may render to one color", svg: oneColor, data: encode(solid)},
+		{name: "empty SVG may render transparent", svg: `<svg width="40" height="20"/>`, data: encode(transparent)},
+		{name: "unknown intrinsic size is not checked", svg: `<svg width="100%"><text>a</text></svg>`, data: encode(content)},
+		{name: "physical units at the DPI", svg: `<svg width="0.5in" height="0.25in"><text>a</text></svg>`, data: encode(content), opts: RenderOptions{DPI: 80}},
+		{name: "physical units at the default DPI", svg: `<svg width="0.5in" height="0.25in"><text>a</text></svg>`, data: encode(content), expected: "got 40x20 pixels, expected 48x24"},
+		{name: "not a PNG", svg: withText, data: []byte("not a png"), expected: "invalid output"},
+		{name: "truncated", svg: withText, data: encode(content)[:60], expected: "invalid output"},
+		{name: "wrong intrinsic size", svg: `<svg width="80" height="20"><text>a</text></svg>`, data: encode(content), expected: "got 40x20 pixels, expected 80x20"},
+		{name: "wrong requested size", svg: withText, data: encode(content), opts: RenderOptions{Width: 80, Height: 40}, expected: "got 40x20 pixels, expected 80x40"},
+		{name: "transparent", svg: oneColor, data: encode(transparent), expected: "fully transparent"},
+		{name: "text missing", svg: withText, data: encode(solid), expected: "single color (#245a74)"},
+		{name: "gradient missing", svg: gradient, data: encode(solid), expected: "single color"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRaster(tt.svg, tt.data, tt.opts)
+			if tt.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fromLogoTheme is the theme name that derives the palette from the project
+// logo.
+const fromLogoTheme = "from-logo"
+
+// The title and tagline are white on the card, a white layer of cardOpacity
+// over the background. Derived palettes keep at least minTextContrast
+// between the two, the WCAG AA ratio for large text.
+const (
+	cardOpacity     = 0.28
+	minTextContrast = 3.0
+)
+
+// logoColorBoxes is the number of colors a logo is reduced to before the
+// palette colors are picked.
+const logoColorBoxes = 8
+
+// paintAttrs are the attributes and CSS properties whose colors count as
+// the colors of an SVG logo.
+var paintAttrs = []string{"fill", "stroke", "stop-color", "flood-color", "color"}
+
+var paintPropertyPattern = regexp.MustCompile(`(?:^|[;{\s])(fill|stroke|stop-color|flood-color|color)\s*:\s*([^;}!]+)`)
+
+// swatch is one of a logo's dominant colors in HSL, with its share of the
+// logo.
+type swatch struct {
+	H, S, L float64
+	Weight  int
+}
+
+// logoTheme derives a palette from the dominant colors of logo. The most
+// common saturated color leads the background gradient, followed by the next
+// one of a clearly different hue, or a neighbouring hue when the logo has a
+// single color. Both are darkened until white text on the card reaches
+// minTextContrast.
+func logoTheme(logo *Logo) (*ThemePalette, error) {
+	histogram := logoColorHistogram(logo)
+	if len(histogram) == 0 {
+		return nil, fmt.Errorf("failed to derive a theme from the logo: no colors found")
+	}
+
+	var swatches []swatch
+	for _, box := range medianCutBoxes(histogram, logoColorBoxes) {
+		h, s, l := toHSL(box.average())
+		swatches = append(swatches, swatch{H: h, S: s, L: l, Weight: box.population()})
+	}
+	sort.SliceStable(swatches, func(i, j int) bool {
+		return swatches[i].score() > swatches[j].score()
+	})
+
+	primary := swatches[0]
+	secondary := swatch{H: math.Mod(primary.H+40, 360), S: primary.S, L: primary.L}
+	for _, sw := range swatches[1:] {
+		if sw.S >= 0.15 && !sw.extreme() && hueDistance(sw.H, primary.H) >= 25 {
+			secondary = sw
+			break
+		}
+	}
+
+	bg0, l0 := primary.readable()
+	bg1, l1 := secondary.readable()
+	return &ThemePalette{
+		Background: Gradient{Type: "linear", Angle: 135, Stops: []GradientStop{
+			{Color: bg0, Offset: 0},
+			{Color: bg1, Offset: 50},
+			{Color: hexColor(fromHSL(primary.H, primary.saturation()*0.6, 0.12)), Offset: 100},
+		}},
+		WAVE0: hexColor(fromHSL(primary.H, primary.saturation(), math.Min(l0+0.12, 0.9))),
+		WAVE1: hexColor(fromHSL(secondary.H, secondary.saturation(), math.Min(l1+0.12, 0.9))),
+	}, nil
+}
+
+// score ranks swatches by how much of the logo they cover, favouring
+// saturated colors. Near black and near white are more often outlines and
+// backgrounds than brand colors.
+func (sw swatch) score() float64 {
+	score := float64(sw.Weight) * (0.25 + sw.S)
+	if sw.extreme() {
+		score *= 0.1
+	}
+	return score
+}
+
+func (sw swatch) extreme() bool {
+	return sw.L < 0.08 || sw.L > 0.92
+}
+
+// saturation lifts pale colors so the background keeps the brand's hue.
+// Grays stay gray.
+func (sw swatch) saturation() float64 {
+	if sw.S < 0.1 {
+		return sw.S
+	}
+	return math.Max(sw.S, 0.35)
+}
+
+// readable returns the swatch as a hex color, darkened until white text on
+// the card over it reaches minTextContrast, and the lightness it ended at.
+func (sw swatch) readable() (string, float64) {
+	s := sw.saturation()
+	l := math.Min(sw.L, 0.55)
+	for l > 0 && textContrast(fromHSL(sw.H, s, l)) < minTextContrast {
+		l = math.Max(l-0.01, 0)
+	}
+	return hexColor(fromHSL(sw.H, s, l)), l
+}
+
+// textContrast is the contrast ratio of white text on the card over bg.
+func textContrast(bg color.NRGBA) float64 {
+	blend := func(v uint8) uint8 {
+		return uint8(math.Round(float64(v)*(1-cardOpacity) + 255*cardOpacity))
+	}
+	card := color.NRGBA{R: blend(bg.R), G: blend(bg.G), B: blend(bg.B), A: 255}
+	return 1.05 / (relativeLuminance(card) + 0.05)
+}
+
+// relativeLuminance is the WCAG relative luminance of c.
+func relativeLuminance(c color.NRGBA) float64 {
+	linear := func(v uint8) float64 {
+		x := float64(v) / 255
+		if x <= 0.03928 {
+			return x / 12.92
+		}
+		return math.Pow((x+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(c.R) + 0.7152*linear(c.G) + 0.0722*linear(c.B)
+}
+
+// logoColorHistogram counts the colors of logo: the opaque pixels of a
+// raster logo, sampled on a grid for large images, or every paint color
+// of an SVG logo.
+func logoColorHistogram(logo *Logo) map[color.NRGBA]int {
+	histogram := make(map[color.NRGBA]int)
+	if logo.Image != nil {
+		bounds := logo.Image.Bounds()
+		step := max(1, int(math.Sqrt(float64(bounds.Dx()*bounds.Dy())/65536)))
+		for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+			for x := bounds.Min.X; x < bounds.Max.X; x += step {
+				c := color.NRGBAModel.Convert(logo.Image.At(x, y)).(color.NRGBA)
+				if c.A < 128 {
+					continue
+				}
+				c.A = 255
+				histogram[c]++
+			}
+		}
+		return histogram
+	}
+
+	logo.Node.Walk(func(n *SVGNode) bool {
+		for _, name := range paintAttrs {
+			if value, ok := n.Attr(name); ok {
+				if c, ok := parseColor(value); ok {
+					histogram[c]++
+				}
+			}
+		}
+		var css []string
+		if style, ok := n.Attr("style"); ok {
+			css = append(css, style)
+		}
+		if n.Name == "style" {
+			css = append(css, n.TextContent())
+		}
+		for _, text := range css {
+			for _, match := range paintPropertyPattern.FindAllStringSubmatch(text, -1) {
+				if c, ok := parseColor(match[2]); ok {
+					histogram[c]++
+				}
+			}
+		}
+		return true
+	})
+	return histogram
+}
+
+// parseColor parses the hex (#RGB, #RRGGBB, with or without alpha) and
+// rgb() colors editors write. Alpha is ignored; none, url() paints and
+// color names are not colors here.
+func parseColor(s string) (color.NRGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		switch len(hex) {
+		case 3, 4:
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		case 6, 8:
+			hex = hex[:6]
+		default:
+			return color.NRGBA{}, false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return color.NRGBA{}, false
+		}
+		return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, true
+	}
+
+	args, ok := strings.CutPrefix(s, "rgb(")
+	if !ok {
+		args, ok = strings.CutPrefix(s, "rgba(")
+	}
+	if !ok || !strings.HasSuffix(args, ")") {
+		return color.NRGBA{}, false
+	}
+	fields := strings.FieldsFunc(strings.TrimSuffix(args, ")"), func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+	if len(fields) < 3 {
+		return color.NRGBA{}, false
+	}
+	var rgb [3]uint8
+	for i, field := range fields[:3] {
+		pct, percent := strings.CutSuffix(field, "%")
+		v, err := strconv.ParseFloat(pct, 64)
+		if err != nil {
+			return color.NRGBA{}, false
+		}
+		if percent {
+			v = v * 255 / 100
+		}
+		rgb[i] = uint8(math.Round(math.Max(0, math.Min(255, v))))
+	}
+	return color.NRGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255}, true
+}
+
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// toHSL converts c to hue in degrees, saturation and lightness.
+func toHSL(c color.NRGBA) (h, s, l float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	hi, lo := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l = (hi + lo) / 2
+	d := hi - lo
+	if d == 0 {
+		return 0, 0, l
+	}
+	s = d / (1 - math.Abs(2*l-1))
+	switch hi {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, s, l
+}
+
+func fromHSL(h, s, l float64) color.NRGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	channel := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v+m)) * 255))
+	}
+	return color.NRGBA{R: channel(r), G: channel(g), B: channel(b), A: 255}
+}
+
+// hueDistance is the angle between two hues in degrees.
+func hueDistance(a, b float64) float64 {
+	d := math.Abs(a - b)
+	return math.Min(d, 360-d)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input string
+		want  color.NRGBA
+		ok    bool
+	}{
+		{"#00ADD8", color.NRGBA{0x00, 0xAD, 0xD8, 255}, true},
+		{" #f0a ", color.NRGBA{0xFF, 0x00, 0xAA, 255}, true},
+		{"#00ADD880", color.NRGBA{0x00, 0xAD, 0xD8, 255}, true},
+		{"rgb(255, 128, 0)", color.NRGBA{255, 128, 0, 255}, true},
+		{"rgba(0 0 255 / 50%)", color.NRGBA{0, 0, 255, 255}, true},
+		{"rgb(100%,0%,50%)", color.NRGBA{255, 0, 128, 255}, true},
+		{"none", color.NRGBA{}, false},
+		{"url(#gradient)", color.NRGBA{}, false},
+		{"currentColor", color.NRGBA{}, false},
+		{"#12345", color.NRGBA{}, false},
+		{"#zzz", color.NRGBA{}, false},
+		{"rgb(1, 2)", color.NRGBA{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseColor(tt.input)
+		assert.Equal(t, tt.ok, ok, tt.input)
+		assert.Equal(t, tt.want, got, tt.input)
+	}
+}
+
+func TestHSL(t *testing.T) {
+	for _, c := range []color.NRGBA{{0x00, 0xAD, 0xD8, 255}, {0xFF, 0xD1, 0x4F, 255}, {0x7A, 0x36, 0x51, 255}, {0x80, 0x80, 0x80, 255}, {0, 0, 0, 255}} {
+		h, s, l := toHSL(c)
+		assert.Equal(t, c, fromHSL(h, s, l), hexColor(c))
+	}
+	h, s, l := toHSL(color.NRGBA{255, 0, 0, 255})
+	assert.Equal(t, []float64{0, 1, 0.5}, []float64{h, s, l})
+	assert.Equal(t, 20.0, hueDistance(350, 10))
+}
+
+func TestTextContrast(t *testing.T) {
+	assert.InDelta(t, 21, 1.05/(relativeLuminance(color.NRGBA{A: 255})+0.05), 1e-9)
+	// The card lightens even a black background.
+	assert.Less(t, textContrast(color.NRGBA{A: 255}), 21.0)
+	assert.Less(t, textContrast(color.NRGBA{R: 0xF8, G: 0xF9, B: 0xFB, A: 255}), minTextContrast)
+}
+
+func TestLogoTheme(t *testing.T) {
+	assertReadable := func(t *testing.T, theme *ThemePalette) {
+		t.Helper()
+		for _, hex := range []string{theme.Background.Stops[0].Color, theme.Background.Stops[1].Color, theme.Background.Stops[2].Color} {
+			c, ok := parseColor(hex)
+			require.True(t, ok, hex)
+			assert.GreaterOrEqual(t, textContrast(c), minTextContrast, hex)
+		}
+	}
+	hue := func(t *testing.T, hex string) float64 {
+		t.Helper()
+		c, ok := parseColor(hex)
+		require.True(t, ok, hex)
+		h, _, _ := toHSL(c)
+		return h
+	}
+
+	t.Run("svg paint colors", func(t *testing.T) {
+		doc, err := parseSVG(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">` +
+			`<style>.a { fill: #00ADD8; } .b{stroke:#00ADD8}</style>` +
+			`<rect class="a"/><circle class="b"/><path style="fill: rgb(255, 209, 79)"/><path fill="#000"/><path fill="#FFF"/><path fill="none"/></svg>`)
+		require.NoError(t, err)
+		theme, err := logoTheme(&Logo{Node: doc.Root, Aspect: 1})
+		require.NoError(t, err)
+
+		assert.InDelta(t, 193, hue(t, theme.Background.Stops[0].Color), 2, "the most common color leads")
+		assert.InDelta(t, 44, hue(t, theme.Background.Stops[1].Color), 2)
+		assert.InDelta(t, 193, hue(t, theme.WAVE0), 2)
+		assertReadable(t, theme)
+	})
+
+	t.Run("raster pixels", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 40; x++ {
+				switch {
+				case x < 10:
+					// transparent
+				case x < 30:
+					img.Set(x, y, color.NRGBA{0xE0, 0x3E, 0x2D, 255})
+				default:
+					img.Set(x, y, color.NRGBA{0x2D, 0x9C, 0xDB, 255})
+				}
+			}
+		}
+		theme, err := logoTheme(&Logo{Image: img, Aspect: 1})
+		require.NoError(t, err)
+		assert.InDelta(t, 6, hue(t, theme.Background.Stops[0].Color), 2)
+		assert.InDelta(t, 202, hue(t, theme.Background.Stops[1].Color), 2)
+		assertReadable(t, theme)
+	})
+
+	t.Run("single color gets a neighbouring hue", func(t *testing.T) {
+		doc, err := parseSVG(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><path fill="#6E40C9"/><path fill="#FFFFFF"/></svg>`)
+		require.NoError(t, err)
+		theme, err := logoTheme(&Logo{Node: doc.Root, Aspect: 1})
+		require.NoError(t, err)
+		assert.InDelta(t, 40, hueDistance(hue(t, theme.Background.Stops[0].Color), hue(t, theme.Background.Stops[1].Color)), 2)
+		assertReadable(t, theme)
+	})
+
+	t.Run("light colors are darkened", func(t *testing.T) {
+		doc, err := parseSVG(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><path fill="#FFE873"/></svg>`)
+		require.NoError(t, err)
+		theme, err := logoTheme(&Logo{Node: doc.Root, Aspect: 1})
+		require.NoError(t, err)
+		assertReadable(t, theme)
+	})
+
+	t.Run("no colors", func(t *testing.T) {
+		doc, err := parseSVG(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><path d="M0 0h10v10z"/></svg>`)
+		require.NoError(t, err)
+		_, err = logoTheme(&Logo{Node: doc.Root, Aspect: 1})
+		assert.ErrorContains(t, err, "no colors found")
+
+		_, err = logoTheme(&Logo{Image: image.NewNRGBA(image.Rect(0, 0, 4, 4)), Aspect: 1})
+		assert.ErrorContains(t, err, "no colors found")
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+type pdfFormat struct{}
+
+func (pdfFormat) Name() string { return "pdf" }
+func (pdfFormat) Ext() string  { return "pdf" }
+
+// EncodeVector keeps the banner as vector content through rsvg-convert's PDF
+// mode when it is installed. It reports false when rsvg-convert is missing
+// or fails, and the raster is used instead.
+func (pdfFormat) EncodeVector(ctx context.Context, svg string, opts EncodeOptions) ([]byte, bool, error) {
+	if !(rsvgConvertRenderer{}).Available() {
+		return nil, false, nil
+	}
+	renderCtx, cancel := renderContext(ctx, opts.Render)
+	data, err := runRsvgConvert(renderCtx, []byte(svg), "pdf", opts.Render)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, fmt.Errorf("PDF rendering stopped: %w", ctx.Err())
+		}
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+// Encode wraps the raster in a single-page PDF.
+func (pdfFormat) Encode(ctx context.Context, src *EncodeSource, opts EncodeOptions) ([]byte, error) {
+	img, err := src.Image()
+	if err != nil {
+		return nil, err
+	}
+	return rasterPDF(img, src.Raster.Options.DPI)
+}
+
+// rasterPDF writes a single-page PDF showing img at dpi (96 when zero), with
+// the alpha channel as a soft mask.
+func rasterPDF(img image.Image, dpi float64) ([]byte, error) {
+	if dpi <= 0 {
+		dpi = 96
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+	rgb := make([]byte, 0, width*height*3)
+	alpha := make([]byte, 0, width*height)
+	opaque := true
+	for i := 0; i < len(nrgba.Pix); i += 4 {
+		rgb = append(rgb, nrgba.Pix[i], nrgba.Pix[i+1], nrgba.Pix[i+2])
+		alpha = append(alpha, nrgba.Pix[i+3])
+		opaque = opaque && nrgba.Pix[i+3] == 0xff
+	}
+
+	rgbData, err := deflate(rgb)
+	if err != nil {
+		return nil, err
+	}
+
+	pageW := float64(width) * 72 / dpi
+	pageH := float64(height) * 72 / dpi
+	content := fmt.Sprintf("q %s 0 0 %s 0 0 cm /Im0 Do Q", formatNumber(pageW), formatNumber(pageH))
+
+	w := &pdfWriter{}
+	w.object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	w.object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>", nil)
+	w.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << /Im0 5 0 R >> >> /Contents 4 0 R >>",
+		formatNumber(pageW), formatNumber(pageH)), nil)
+	w.object(fmt.Sprintf("<< /Length %d >>", len(content)), []byte(content))
+
+	imageDict := fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d", width, height, len(rgbData))
+	if opaque {
+		w.object(imageDict+" >>", rgbData)
+	} else {
+		alphaData, err := deflate(alpha)
+		if err != nil {
+			return nil, err
+		}
+		w.object(imageDict+" /SMask 6 0 R >>", rgbData)
+		w.object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>",
+			width, height, len(alphaData)), alphaData)
+	}
+
+	return w.finish(), nil
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress PDF stream: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress PDF stream: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pdfWriter numbers objects from 1 in the order they are added and records
+// their offsets for the cross-reference table.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *pdfWriter) object(dict string, stream []byte) {
+	if w.buf.Len() == 0 {
+		// The binary comment marks the file as binary for transfer tools.
+		w.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	}
+	w.offsets = append(w.offsets, w.buf.Len())
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\n", len(w.offsets), dict)
+	if stream != nil {
+		w.buf.WriteString("stream\n")
+		w.buf.Write(stream)
+		w.buf.WriteString("\nendstream\n")
+	}
+	w.buf.WriteString("endobj\n")
+}
+
+func (w *pdfWriter) finish() []byte {
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, xref)
+	return w.buf.Bytes()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRasterPDF(t *testing.T) {
+	t.Run("opaque image", func(t *testing.T) {
+		data, err := rasterPDF(testImage(160, 60), 0)
+		require.NoError(t, err)
+
+		assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+		assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+		assert.Contains(t, string(data), "/MediaBox [0 0 120 45]")
+		assert.NotContains(t, string(data), "/SMask")
+		assertPDFXref(t, data, 5)
+
+		// The image stream holds the RGB pixels.
+		start := bytes.Index(data, []byte("/Subtype /Image"))
+		stream := data[bytes.Index(data[start:], []byte("stream\n"))+start+7:]
+		zr, err := zlib.NewReader(bytes.NewReader(stream))
+		require.NoError(t, err)
+		pixels, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Len(t, pixels, 160*60*3)
+		assert.Equal(t, []byte{200, 40, 40}, pixels[:3])
+	})
+
+	t.Run("transparency uses a soft mask", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		img.SetNRGBA(1, 1, color.NRGBA{R: 255, A: 128})
+
+		data, err := rasterPDF(img, 72)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "/SMask 6 0 R")
+		assert.Contains(t, string(data), "/MediaBox [0 0 4 4]")
+		assertPDFXref(t, data, 6)
+	})
+}
+
+// assertPDFXref checks that the cross-reference table points at each object.
+func assertPDFXref(t *testing.T, data []byte, objects int) {
+	t.Helper()
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	require.NotNil(t, startxref)
+	xref, err := strconv.Atoi(string(startxref[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data[xref:], []byte(fmt.Sprintf("xref\n0 %d\n", objects+1))))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	require.Len(t, entries, objects)
+	for i, entry := range entries {
+		offset, err := strconv.Atoi(string(entry[1]))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(data[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+}
+
+func TestPDFFormat(t *testing.T) {
+	src := imageSource(t, testImage(32, 12))
+	src.SVG = `<svg xmlns="http://www.w3.org/2000/svg" width="32" height="12"><rect width="32" height="12" fill="red"/></svg>`
+
+	data, err := pdfFormat{}.Encode(context.Background(), src, EncodeOptions{Quality: defaultQuality})
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-")))
+	assert.Contains(t, string(data), "/Subtype /Image")
+
+	data, ok, err := pdfFormat{}.EncodeVector(context.Background(), src.SVG, EncodeOptions{Quality: defaultQuality})
+	require.NoError(t, err)
+	if (rsvgConvertRenderer{}).Available() {
+		require.True(t, ok)
+		assert.True(t, bytes.HasPrefix(data, []byte("%PDF-")))
+		assert.NotContains(t, string(data), "/Subtype /Image", "rsvg-convert should keep vector content")
+	} else {
+		assert.False(t, ok)
+	}
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sort"
+	"sync"
+)
+
+// keptPNGChunks are the ancillary chunks that affect how the image looks or
+// prints. Everything else, such as text and timestamps, is stripped.
+var keptPNGChunks = map[string]bool{
+	"pHYs": true,
+	"sRGB": true,
+	"gAMA": true,
+	"cHRM": true,
+	"iCCP": true,
+}
+
+// paletteSizes are tried in order when quantizing to meet a size budget.
+var paletteSizes = []int{256, 128, 64, 32, 16}
+
+// optimizePNG re-encodes data without losing information: unneeded chunks
+// are dropped, the alpha channel is dropped when the image is opaque, images
+// with at most 256 colors get a palette, and the filter strategy that
+// compresses best is kept. When maxBytes is positive and the result is still
+// larger, the image is quantized to ever smaller palettes with dithering
+// until it fits. The smallest result is returned even if none fits.
+func optimizePNG(data []byte, maxBytes int) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PNG for optimization: %w", err)
+	}
+	chunks, err := ancillaryPNGChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	best := data
+	reduced := losslessReduce(img)
+	optimized, err := encodePNGSmallest(reduced, chunks)
+	if err != nil {
+		return nil, err
+	}
+	if len(optimized) < len(best) {
+		best = optimized
+	}
+
+	if maxBytes <= 0 || len(best) <= maxBytes {
+		return best, nil
+	}
+	for _, colors := range paletteSizes {
+		// An exact palette already beats quantizing to as many colors.
+		if paletted, ok := reduced.(*image.Paletted); ok && colors >= len(paletted.Palette) {
+			continue
+		}
+		quantized, err := encodePNGSmallest(quantize(img, colors), chunks)
+		if err != nil {
+			return nil, err
+		}
+		if len(quantized) < len(best) {
+			best = quantized
+		}
+		if len(best) <= maxBytes {
+			break
+		}
+	}
+	return best, nil
+}
+
+// ancillaryPNGChunks returns the raw kept chunks of data, ready to copy.
+func ancillaryPNGChunks(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("failed to optimize PNG: not a PNG")
+	}
+
+	var kept []byte
+	for rest := data[len(pngSignature):]; len(rest) >= 12; {
+		length := binary.BigEndian.Uint32(rest[0:4])
+		if uint64(len(rest)) < 12+uint64(length) {
+			return nil, fmt.Errorf("failed to optimize PNG: truncated PNG")
+		}
+		if keptPNGChunks[string(rest[4:8])] {
+			kept = append(kept, rest[:12+length]...)
+		}
+		rest = rest[12+length:]
+	}
+	return kept, nil
+}
+
+// losslessReduce returns an exact palette image when img has at most 256
+// colors, and img converted to NRGBA otherwise.
+func losslessReduce(img image.Image) image.Image {
+	nrgba := toNRGBA(img)
+
+	index := make(map[color.NRGBA]uint8)
+	var palette color.Palette
+	for i := 0; i < len(nrgba.Pix); i += 4 {
+		c := color.NRGBA{R: nrgba.Pix[i], G: nrgba.Pix[i+1], B: nrgba.Pix[i+2], A: nrgba.Pix[i+3]}
+		if _, ok := index[c]; ok {
+			continue
+		}
+		if len(palette) == 256 {
+			return nrgba
+		}
+		index[c] = uint8(len(palette))
+		palette = append(palette, c)
+	}
+
+	paletted := image.NewPaletted(nrgba.Bounds(), palette)
+	for i, j := 0, 0; i < len(nrgba.Pix); i, j = i+4, j+1 {
+		c := color.NRGBA{R: nrgba.Pix[i], G: nrgba.Pix[i+1], B: nrgba.Pix[i+2], A: nrgba.Pix[i+3]}
+		paletted.Pix[j] = index[c]
+	}
+	return paletted
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) && nrgba.Stride == 4*nrgba.Rect.Dx() {
+		return nrgba
+	}
+	nrgba := image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return nrgba
+}
+
+// quantize reduces img to at most colors colors chosen by median cut, with
+// Floyd-Steinberg dithering to keep gradients smooth.
+func quantize(img image.Image, colors int) *image.Paletted {
+	nrgba := toNRGBA(img)
+	palette := medianCut(nrgba, colors)
+	paletted := image.NewPaletted(nrgba.Bounds(), palette)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), nrgba, image.Point{})
+	return paletted
+}
+
+// colorBox is a set of distinct colors with their pixel counts.
+type colorBox struct {
+	colors []color.NRGBA
+	counts []int
+}
+
+// medianCut splits the color space at the weighted median of the channel
+// with the widest range until there are n boxes, and returns their averages.
+func medianCut(img *image.NRGBA, n int) color.Palette {
+	histogram := make(map[color.NRGBA]int)
+	for i := 0; i < len(img.Pix); i += 4 {
+		histogram[color.NRGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}]++
+	}
+
+	boxes := medianCutBoxes(histogram, n)
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		palette[i] = box.average()
+	}
+	return palette
+}
+
+// medianCutBoxes splits the colors of histogram, weighted by their counts,
+// into at most n boxes. Colors are sorted first so the result does not
+// depend on map order.
+func medianCutBoxes(histogram map[color.NRGBA]int, n int) []colorBox {
+	all := colorBox{}
+	for c := range histogram {
+		all.colors = append(all.colors, c)
+	}
+	sort.Slice(all.colors, func(i, j int) bool {
+		a, b := all.colors[i], all.colors[j]
+		return uint32(a.R)<<24|uint32(a.G)<<16|uint32(a.B)<<8|uint32(a.A) < uint32(b.R)<<24|uint32(b.G)<<16|uint32(b.B)<<8|uint32(b.A)
+	})
+	for _, c := range all.colors {
+		all.counts = append(all.counts, histogram[c])
+	}
+	boxes := []colorBox{all}
+
+	for len(boxes) < n {
+		// Split the box with the widest channel range.
+		split, channel, widest := -1, 0, 0
+		for i, box := range boxes {
+			if len(box.colors) < 2 {
+				continue
+			}
+			if ch, r := box.widestChannel(); r > widest {
+				split, channel, widest = i, ch, r
+			}
+		}
+		if split < 0 {
+			break
+		}
+		low, high := boxes[split].cut(channel)
+		boxes[split] = low
+		boxes = append(boxes, high)
+	}
+	return boxes
+}
+
+func channel(c color.NRGBA, ch int) int {
+	switch ch {
+	case 0:
+		return int(c.R)
+	case 1:
+		return int(c.G)
+	case 2:
+		return int(c.B)
+	}
+	return int(c.A)
+}
+
+func (b colorBox) widestChannel() (int, int) {
+	best, widest := 0, -1
+	for ch := 0; ch < 4; ch++ {
+		lo, hi := 255, 0
+		for _, c := range b.colors {
+			v := channel(c, ch)
+			lo, hi = min(lo, v), max(hi, v)
+		}
+		if hi-lo > widest {
+			best, widest = ch, hi-lo
+		}
+	}
+	return best, widest
+}
+
+// cut splits the box at the weighted median of ch.
+func (b colorBox) cut(ch int) (colorBox, colorBox) {
+	order := make([]int, len(b.colors))
+	total := 0
+	for i := range order {
+		order[i] = i
+		total += b.counts[i]
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return channel(b.colors[order[i]], ch) < channel(b.colors[order[j]], ch)
+	})
+
+	var low, high colorBox
+	seen := 0
+	for k, i := range order {
+		// Both halves get at least one color.
+		if (seen < total/2 && k < len(order)-1) || k == 0 {
+			low.colors = append(low.colors, b.colors[i])
+			low.counts = append(low.counts, b.counts[i])
+		} else {
+			high.colors = append(high.colors, b.colors[i])
+			high.counts = append(high.counts, b.counts[i])
+		}
+		seen += b.counts[i]
+	}
+	return low, high
+}
+
+// population is the number of pixels in the box.
+func (b colorBox) population() int {
+	total := 0
+	for _, n := range b.counts {
+		total += n
+	}
+	return total
+}
+
+func (b colorBox) average() color.NRGBA {
+	var sum [4]int
+	total := 0
+	for i, c := range b.colors {
+		n := b.counts[i]
+		sum[0] += int(c.R) * n
+		sum[1] += int(c.G) * n
+		sum[2] += int(c.B) * n
+		sum[3] += int(c.A) * n
+		total += n
+	}
+	return color.NRGBA{
+		R: uint8((sum[0] + total/2) / total),
+		G: uint8((sum[1] + total/2) / total),
+		B: uint8((sum[2] + total/2) / total),
+		A: uint8((sum[3] + total/2) / total),
+	}
+}
+
+// Filter strategies tried by encodePNGSmallest: each fixed PNG filter type,
+// plus adaptive selection per row.
+const (
+	filterNone = iota
+	filterSub
+	filterUp
+	filterAverage
+	filterPaeth
+	filterAdaptive
+)
+
+// encodePNGSmallest encodes img with each filter strategy at the best zlib
+// level, in parallel, and returns the smallest file, with chunks inserted
+// after IHDR. Palette images only try no filtering and adaptive filtering,
+// since predicting palette indices rarely helps.
+func encodePNGSmallest(img image.Image, chunks []byte) ([]byte, error) {
+	strategies := []int{filterNone, filterSub, filterUp, filterAverage, filterPaeth, filterAdaptive}
+	if _, ok := img.(*image.Paletted); ok {
+		strategies = []int{filterNone, filterAdaptive}
+	}
+
+	results := make([][]byte, len(strategies))
+	errs := make([]error, len(strategies))
+	var wg sync.WaitGroup
+	for i, strategy := range strategies {
+		wg.Add(1)
+		go func(i, strategy int) {
+			defer wg.Done()
+			results[i], errs[i] = encodePNG(img, strategy, chunks)
+		}(i, strategy)
+	}
+	wg.Wait()
+
+	var best []byte
+	for i, data := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if best == nil || len(data) < len(best) {
+			best = data
+		}
+	}
+	return best, nil
+}
+
+// encodePNG writes 8-bit PNGs: indexed for *image.Paletted, RGB for opaque
+// images and RGBA otherwise.
+func encodePNG(img image.Image, strategy int, chunks []byte) ([]byte, error) {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	var colorType byte
+	var bpp int
+	var raw []byte
+	var plte, trns []byte
+	if paletted, ok := img.(*image.Paletted); ok {
+		colorType, bpp = 3, 1
+		raw = paletted.Pix
+		for _, c := range paletted.Palette {
+			nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+			plte = append(plte, nc.R, nc.G, nc.B)
+			trns = append(trns, nc.A)
+		}
+		// tRNS may omit trailing opaque entries.
+		for len(trns) > 0 && trns[len(trns)-1] == 0xff {
+			trns = trns[:len(trns)-1]
+		}
+	} else {
+		nrgba := toNRGBA(img)
+		colorType, bpp = 6, 4
+		raw = nrgba.Pix
+		if nrgba.Opaque() {
+			colorType, bpp = 2, 3
+			raw = make([]byte, 0, width*height*3)
+			for i := 0; i < len(nrgba.Pix); i += 4 {
+				raw = append(raw, nrgba.Pix[i], nrgba.Pix[i+1], nrgba.Pix[i+2])
+			}
+		}
+	}
+
+	var idat bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&idat, zlib.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	stride := width * bpp
+	prev := make([]byte, stride)
+	filtered := make([]byte, stride+1)
+	candidate := make([]byte, stride+1)
+	for y := 0; y < height; y++ {
+		row := raw[y*stride : (y+1)*stride]
+		if strategy == filterAdaptive {
+			// Minimum sum of absolute differences, as recommended by the PNG
+			// specification.
+			bestSum := -1
+			for f := filterNone; f <= filterPaeth; f++ {
+				filterRow(candidate, row, prev, bpp, f)
+				if sum := absSum(candidate[1:]); bestSum < 0 || sum < bestSum {
+					bestSum = sum
+					copy(filtered, candidate)
+				}
+			}
+		} else {
+			filterRow(filtered, row, prev, bpp, strategy)
+		}
+		if _, err := zw.Write(filtered); err != nil {
+			return nil, fmt.Errorf("failed to compress PNG: %w", err)
+		}
+		prev = row
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress PNG: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.Write(pngSignature)
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(height))
+	ihdr[8], ihdr[9] = 8, colorType
+	writePNGChunk(&out, "IHDR", ihdr)
+	out.Write(chunks)
+	if plte != nil {
+		writePNGChunk(&out, "PLTE", plte)
+		if len(trns) > 0 {
+			writePNGChunk(&out, "tRNS", trns)
+		}
+	}
+	writePNGChunk(&out, "IDAT", idat.Bytes())
+	writePNGChunk(&out, "IEND", nil)
+	return out.Bytes(), nil
+}
+
+// filterRow writes the filter type byte followed by the filtered row.
+func filterRow(dst, row, prev []byte, bpp, filter int) {
+	dst[0] = byte(filter)
+	out := dst[1:]
+	for i := range row {
+		var a, b, c byte
+		if i >= bpp {
+			a, c = row[i-bpp], prev[i-bpp]
+		}
+		b = prev[i]
+		switch filter {
+		case filterNone:
+			out[i] = row[i]
+		case filterSub:
+			out[i] = row[i] - a
+		case filterUp:
+			out[i] = row[i] - b
+		case filterAverage:
+			out[i] = row[i] - byte((int(a)+int(b))/2)
+		case filterPaeth:
+			out[i] = row[i] - paeth(a, b, c)
+		}
+	}
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func absSum(filtered []byte) int {
+	sum := 0
+	for _, v := range filtered {
+		sum += abs(int(int8(v)))
+	}
+	return sum
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gradientImage has a color per pixel, so it cannot be palette-reduced
+// losslessly.
+func gradientImage(width, height int, alpha uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: alpha})
+		}
+	}
+	return img
+}
+
+// noiseImage is incompressible without losing colors.
+func noiseImage(width, height int) *image.NRGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	rng.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+func encodeTestPNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, (&png.Encoder{CompressionLevel: png.NoCompression}).Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// assertSamePixels compares images in non-premultiplied color.
+func assertSamePixels(t *testing.T, expected image.Image, data []byte) {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, expected.Bounds().Size(), img.Bounds().Size())
+
+	want := image.NewNRGBA(expected.Bounds())
+	draw.Draw(want, want.Bounds(), expected, expected.Bounds().Min, draw.Src)
+	got := image.NewNRGBA(img.Bounds())
+	draw.Draw(got, got.Bounds(), img, img.Bounds().Min, draw.Src)
+	assert.Equal(t, want.Pix, got.Pix)
+}
+
+func TestOptimizePNG(t *testing.T) {
+	tests := []struct {
+		name      string
+		img       image.Image
+		colorType byte
+	}{
+		{
+			name:      "opaque image drops alpha",
+			img:       gradientImage(256, 64, 255),
+			colorType: 2,
+		},
+		{
+			name:      "translucent image keeps alpha",
+			img:       gradientImage(256, 64, 128),
+			colorType: 6,
+		},
+		{
+			name:      "few colors get a palette",
+			img:       testImage(256, 64),
+			colorType: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := encodeTestPNG(t, tt.img)
+
+			data, err := optimizePNG(original, 0)
+			require.NoError(t, err)
+
+			assert.Less(t, len(data), len(original))
+			assert.Equal(t, tt.colorType, data[len(pngSignature)+8+9])
+			assertSamePixels(t, tt.img, data)
+		})
+	}
+}
+
+func TestOptimizePNGChunks(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	original := encodeTestPNG(t, testImage(64, 24))
+	withDPI, err := setPNGDPI(original, 144)
+	require.NoError(t, err)
+	// Insert a text chunk after IHDR.
+	buf.Write(withDPI[len(pngSignature) : len(pngSignature)+25])
+	writePNGChunk(&buf, "tEXt", []byte("Software\x00test"))
+	buf.Write(withDPI[len(pngSignature)+25:])
+
+	data, err := optimizePNG(buf.Bytes(), 0)
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(data), "tEXt")
+	assert.Contains(t, string(data), "pHYs")
+	assertSamePixels(t, testImage(64, 24), data)
+}
+
+func TestOptimizePNGBudget(t *testing.T) {
+	img := noiseImage(128, 128)
+	original := encodeTestPNG(t, img)
+
+	lossless, err := optimizePNG(original, 0)
+	require.NoError(t, err)
+
+	budget := len(lossless) / 2
+	data, err := optimizePNG(original, budget)
+	require.NoError(t, err)
+
+	assert.LessOrEqual(t, len(data), budget)
+	assert.Equal(t, byte(3), data[len(pngSignature)+8+9])
+	decoded, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, img.Bounds(), decoded.Bounds())
+}
+
+func TestOptimizePNGInvalid(t *testing.T) {
+	_, err := optimizePNG([]byte("not a png"), 0)
+	assert.Error(t, err)
+}
+
+func TestMedianCut(t *testing.T) {
+	palette := medianCut(gradientImage(64, 64, 255), 16)
+	assert.Len(t, palette, 16)
+
+	// Never more colors than the image has.
+	palette = medianCut(toNRGBA(testImage(8, 8)), 16)
+	assert.Len(t, palette, 2)
+}
+
+func TestMedianCutBoxes(t *testing.T) {
+	histogram := map[color.NRGBA]int{
+		{R: 255, A: 255}: 30, {R: 250, A: 255}: 10,
+		{B: 255, A: 255}: 20, {G: 200, B: 40, A: 255}: 5,
+	}
+	boxes := medianCutBoxes(histogram, 3)
+	require.Len(t, boxes, 3)
+	total := 0
+	for _, box := range boxes {
+		total += box.population()
+	}
+	assert.Equal(t, 65, total)
+
+	// The split does not depend on map order.
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, boxes, medianCutBoxes(histogram, 3))
+	}
+}
+
+func TestPNGFilterStrategies(t *testing.T) {
+	img := gradientImage(32, 16, 200)
+	for strategy := filterNone; strategy <= filterAdaptive; strategy++ {
+		data, err := encodePNG(img, strategy, nil)
+		require.NoError(t, err)
+		assertSamePixels(t, img, data)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Renderer converts an SVG document into PNG bytes.
+type Renderer interface {
+	// Name is the identifier used by the -renderer flag and config key.
+	Name() string
+	// Available reports whether the renderer can run on this machine.
+	Available() bool
+	// Render must stop and return an error once ctx is done.
+	Render(ctx context.Context, svg []byte, opts RenderOptions) ([]byte, error)
+}
+
+// RenderOptions are passed to every renderer so all backends produce the
+// same output.
+type RenderOptions struct {
+	// Width and Height are the exact PNG size in pixels. Zero renders at the
+	// intrinsic SVG size.
+	Width  int
+	Height int
+	// DPI resolves physical units such as mm and pt. Zero keeps the renderer
+	// default of 96.
+	DPI float64
+	// Timeout bounds each renderer attempt. Zero waits indefinitely.
+	Timeout time.Duration
+	// Fonts are the fonts every renderer loads.
+	Fonts Fonts
+}
+
+// defaultRenderTimeout stops a hung rsvg-convert or a pathological SVG.
+const defaultRenderTimeout = time.Minute
+
+// renderContext applies the render timeout to ctx.
+func renderContext(ctx context.Context, opts RenderOptions) (context.Context, context.CancelFunc) {
+	if opts.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, opts.Timeout)
+}
+
+// defaultRendererOrder is tried when no renderer is configured: the system
+// rsvg-convert is fastest, the embedded WASM build always works.
+var defaultRendererOrder = []string{"rsvg-convert", "resvg-wasm"}
+
+var rendererRegistry = map[string]Renderer{}
+
+func registerRenderer(r Renderer) {
+	rendererRegistry[r.Name()] = r
+}
+
+func init() {
+	registerRenderer(rsvgConvertRenderer{})
+	registerRenderer(resvgWasmRenderer{})
+}
+
+func rendererNames() []string {
+	names := make([]string, 0, len(rendererRegistry))
+	for name := range rendererRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseRendererList parses a comma-separated fallback list such as
+// "rsvg-convert,resvg-wasm". Empty input and "auto" select the default order.
+func parseRendererList(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "auto" {
+		return defaultRendererOrder, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := rendererRegistry[name]; !ok {
+			return nil, fmt.Errorf("unknown renderer %q. Use: auto, %s", name, strings.Join(rendererNames(), ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// rasterOptions returns the render options for each output scale. Size
+// errors are reported before any rendering starts.
+func rasterOptions(svg string, width, height int, scales []float64, dpi float64, timeout time.Duration) ([]RenderOptions, error) {
+	if dpi < 0 {
+		return nil, fmt.Errorf("invalid DPI %v: must not be negative", dpi)
+	}
+	if timeout < 0 {
+		return nil, fmt.Errorf("invalid render timeout %v: must not be negative", timeout)
+	}
+
+	opts := make([]RenderOptions, len(scales))
+	for i, scale := range scales {
+		w, h, err := outputSize(svg, width, height, scale, dpi)
+		if err != nil {
+			return nil, err
+		}
+		opts[i] = RenderOptions{Width: w, Height: h, DPI: dpi, Timeout: timeout}
+	}
+	return opts, nil
+}
+
+// renderRasters renders one PNG per output scale with the matching options.
+// The DPI, when set, is also recorded in the PNG metadata.
+func renderRasters(ctx context.Context, svg string, order []string, scales []float64, opts []RenderOptions) ([]Raster, error) {
+	rasters := make([]Raster, 0, len(scales))
+	for i, scale := range scales {
+		png, renderer, err := convertSVGToPNG(ctx, svg, order, opts[i])
+		if err != nil {
+			return nil, err
+		}
+		if opts[i].DPI > 0 {
+			if png, err = setPNGDPI(png, opts[i].DPI); err != nil {
+				return nil, err
+			}
+		}
+		rasters = append(rasters, Raster{Scale: scale, PNG: png, Renderer: renderer, Options: opts[i]})
+	}
+	return rasters, nil
+}
+
+// convertSVGToPNG renders with the first available renderer in order, falling
+// back to the next one when a renderer fails, times out or produces an
+// invalid image. It returns the name of the renderer that produced the PNG.
+// Once ctx is done no further renderer is tried.
+func convertSVGToPNG(ctx context.Context, svg string, order []string, opts RenderOptions) ([]byte, string, error) {
+	var errs []error
+	for _, name := range order {
+		r, ok := rendererRegistry[name]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown renderer %q", name)
+		}
+		if !r.Available() {
+			errs = append(errs, fmt.Errorf("%s: not available", name))
+			continue
+		}
+
+		attemptCtx, cancel := renderContext(ctx, opts)
+		png, err := r.Render(attemptCtx, []byte(svg), opts)
+		timedOut := errors.Is(attemptCtx.Err(), context.DeadlineExceeded)
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			return nil, "", fmt.Errorf("PNG rendering stopped: %w", ctx.Err())
+		case err != nil && timedOut:
+			errs = append(errs, fmt.Errorf("%s: timed out after %v", name, opts.Timeout))
+			continue
+		case err != nil:
+			errs = append(errs, err)
+			continue
+		}
+		// Renderers can succeed with a broken image, e.g. when fonts fail
+		// to load, so the output is checked before it is accepted.
+		if err := validateRaster(svg, png, opts); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		return png, name, nil
+	}
+
+	if len(errs) == 0 {
+		return nil, "", fmt.Errorf("no renderer configured")
+	}
+	return nil, "", fmt.Errorf("PNG rendering failed: %w", errors.Join(errs...))
+}
+
+type rsvgConvertRenderer struct{}
+
+func (rsvgConvertRenderer) Name() string { return "rsvg-convert" }
+
+func (rsvgConvertRenderer) Available() bool {
+	_, err := exec.LookPath("rsvg-convert")
+	return err == nil
+}
+
+func (rsvgConvertRenderer) Render(ctx context.Context, svg []byte, opts RenderOptions) ([]byte, error) {
+	return convertWithRsvgConvert(ctx, svg, opts)
+}
+
+type resvgWasmRenderer struct{}
+
+func (resvgWasmRenderer) Name() string { return "resvg-wasm" }
+
+// Available is always true: the WASM module is embedded in the binary.
+func (resvgWasmRenderer) Available() bool { return true }
+
+func (resvgWasmRenderer) Render(ctx context.Context, svg []byte, opts RenderOptions) ([]byte, error) {
+	return convertWithResvg(ctx, svg, opts)
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRenderer is a Renderer with scripted availability and output. Without
+// scripted output or error it renders a valid two-color PNG of the requested
+// size.
+type fakeRenderer struct {
+	name      string
+	available bool
+	png       []byte
+	err       error
+	calls     *int
+	opts      *RenderOptions
+	// hang blocks until the context is done, like a stuck subprocess.
+	hang bool
+}
+
+func (f fakeRenderer) Name() string    { return f.name }
+func (f fakeRenderer) Available() bool { return f.available }
+
+func (f fakeRenderer) Render(ctx context.Context, svg []byte, opts RenderOptions) ([]byte, error) {
+	if f.calls != nil {
+		*f.calls++
+	}
+	if f.opts != nil {
+		*f.opts = opts
+	}
+	if f.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if f.png == nil && f.err == nil {
+		return fakePNG(string(svg), opts)
+	}
+	return f.png, f.err
+}
+
+func fakePNG(svg string, opts RenderOptions) ([]byte, error) {
+	width, height := opts.Width, opts.Height
+	if width == 0 || height == 0 {
+		width, height = 1, 1
+		if w, h, err := intrinsicSize(svg, opts.DPI); err == nil {
+			width, height = int(w), int(h)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(width, height)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// withRenderers registers renderers for the duration of a test.
+func withRenderers(t *testing.T, renderers ...Renderer) {
+	t.Helper()
+	saved := make(map[string]Renderer, len(rendererRegistry))
+	for name, r := range rendererRegistry {
+		saved[name] = r
+	}
+	t.Cleanup(func() { rendererRegistry = saved })
+
+	for _, r := range renderers {
+		registerRenderer(r)
+	}
+}
+
+func TestParseRendererList(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:     "empty uses default order",
+			input:    "",
+			expected: defaultRendererOrder,
+		},
+		{
+			name:     "auto uses default order",
+			input:    "auto",
+			expected: defaultRendererOrder,
+		},
+		{
+			name:     "single renderer",
+			input:    "resvg-wasm",
+			expected: []string{"resvg-wasm"},
+		},
+		{
+			name:     "ordered list with spaces",
+			input:    "resvg-wasm, rsvg-convert",
+			expected: []string{"resvg-wasm", "rsvg-convert"},
+		},
+		{
+			name:        "unknown renderer",
+			input:       "inkscape",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := parseRendererList(tt.input)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown renderer")
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestConvertSVGToPNGFallback(t *testing.T) {
+	var brokenCalls, missingCalls int
+	withRenderers(t,
+		fakeRenderer{name: "broken", available: true, err: errors.New("broken: crashed"), calls: &brokenCalls},
+		fakeRenderer{name: "missing", available: false, calls: &missingCalls},
+		fakeRenderer{name: "good", available: true},
+	)
+
+	t.Run("falls back past failing and unavailable renderers", func(t *testing.T) {
+		png, renderer, err := convertSVGToPNG(context.Background(), "<svg/>", []string{"broken", "missing", "good"}, RenderOptions{})
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(png, pngSignature))
+		assert.Equal(t, "good", renderer)
+		assert.Equal(t, 1, brokenCalls)
+		assert.Equal(t, 0, missingCalls)
+	})
+
+	t.Run("first working renderer wins", func(t *testing.T) {
+		_, renderer, err := convertSVGToPNG(context.Background(), "<svg/>", []string{"good", "broken"}, RenderOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "good", renderer)
+	})
+
+	t.Run("all renderers fail", func(t *testing.T) {
+		png, renderer, err := convertSVGToPNG(context.Background(), "<svg/>", []string{"broken", "missing"}, RenderOptions{})
+		assert.Error(t, err)
+		assert.Nil(t, png)
+		assert.Empty(t, renderer)
+		assert.Contains(t, err.Error(), "broken: crashed")
+		assert.Contains(t, err.Error(), "missing: not available")
+	})
+
+	t.Run("unknown renderer", func(t *testing.T) {
+		_, _, err := convertSVGToPNG(context.Background(), "<svg/>", []string{"nope"}, RenderOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestConvertSVGToPNGValidation(t *testing.T) {
+	var blank bytes.Buffer
+	require.NoError(t, png.Encode(&blank, image.NewNRGBA(image.Rect(0, 0, 100, 50))))
+	withRenderers(t,
+		fakeRenderer{name: "empty", available: true, png: []byte{}},
+		fakeRenderer{name: "blank", available: true, png: blank.Bytes()},
+		fakeRenderer{name: "good", available: true},
+	)
+	svg := `<svg width="100" height="50"><rect width="100" height="50" fill="#fff"/><text>Title</text></svg>`
+
+	t.Run("invalid output falls back", func(t *testing.T) {
+		_, renderer, err := convertSVGToPNG(context.Background(), svg, []string{"empty", "blank", "good"}, RenderOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "good", renderer)
+	})
+
+	t.Run("invalid output is reported", func(t *testing.T) {
+		_, _, err := convertSVGToPNG(context.Background(), svg, []string{"empty", "blank"}, RenderOptions{})
+		assert.ErrorContains(t, err, "empty: invalid output")
+		assert.ErrorContains(t, err, "blank: invalid output: image is fully transparent")
+	})
+
+	t.Run("wrong size", func(t *testing.T) {
+		_, _, err := convertSVGToPNG(context.Background(), svg, []string{"blank"}, RenderOptions{Width: 200, Height: 100})
+		assert.ErrorContains(t, err, "got 100x50 pixels, expected 200x100")
+	})
+}
+
+func TestConvertSVGToPNGCancellation(t *testing.T) {
+	var goodCalls int
+	withRenderers(t,
+		fakeRenderer{name: "stuck", available: true, hang: true},
+		fakeRenderer{name: "good", available: true, calls: &goodCalls},
+	)
+
+	t.Run("timed out renderer falls back", func(t *testing.T) {
+		png, renderer, err := convertSVGToPNG(context.Background(), "<svg/>", []string{"stuck", "good"}, RenderOptions{Timeout: 10 * time.Millisecond})
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(png, pngSignature))
+		assert.Equal(t, "good", renderer)
+	})
+
+	t.Run("timeout is reported", func(t *testing.T) {
+		_, _, err := convertSVGToPNG(context.Background(), "<svg/>", []string{"stuck"}, RenderOptions{Timeout: 10 * time.Millisecond})
+		assert.ErrorContains(t, err, "stuck: timed out after 10ms")
+	})
+
+	t.Run("cancellation stops the fallback chain", func(t *testing.T) {
+		goodCalls = 0
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		_, _, err := convertSVGToPNG(ctx, "<svg/>", []string{"stuck", "good"}, RenderOptions{})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, goodCalls)
+	})
+}
+
+func TestGenerateBannerRendererSelection(t *testing.T) {
+	withRenderers(t,
+		fakeRenderer{name: "good", available: true},
+		fakeRenderer{name: "missing", available: false},
+	)
+	tempDir := t.TempDir()
+	readme := []byte("<!-- banner-title: Renderer -->")
+
+	t.Run("renderer flag", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "flag")
+		require.NoError(t, os.MkdirAll(projectDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), readme, 0644))
+
+		opts := testOptions("light", "center")
+		opts.Renderer = "missing,good"
+		require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+
+		data, err := os.ReadFile(filepath.Join(projectDir, "banner.png"))
+		require.NoError(t, err)
+		config, err := png.DecodeConfig(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, defaultCanvas.Width, config.Width)
+	})
+
+	t.Run("renderer from config", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "config")
+		require.NoError(t, os.MkdirAll(projectDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), readme, 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, configFileName), []byte("renderer: good\n"), 0644))
+
+		require.NoError(t, generateBanner(context.Background(), projectDir, testOptions("light", "center")))
+		_, err := os.Stat(filepath.Join(projectDir, "banner.png"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("scaled outputs", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "scaled")
+		require.NoError(t, os.MkdirAll(projectDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), readme, 0644))
+
+		opts := testOptions("light", "center")
+		opts.Renderer = "good"
+		opts.Scales = []float64{1, 2}
+		require.NoError(t, generateBanner(context.Background(), projectDir, opts))
+
+		for _, name := range []string{"banner.png", "banner@2x.png"} {
+			_, err := os.Stat(filepath.Join(projectDir, name))
+			assert.NoError(t, err, name)
+		}
+	})
+
+	t.Run("pinned renderer failure is an error", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "pinned")
+		require.NoError(t, os.MkdirAll(projectDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), readme, 0644))
+
+		opts := testOptions("light", "center")
+		opts.Renderer = "missing"
+		err := generateBanner(context.Background(), projectDir, opts)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "missing: not available")
+	})
+
+	t.Run("unknown renderer", func(t *testing.T) {
+		projectDir := filepath.Join(tempDir, "unknown")
+		require.NoError(t, os.MkdirAll(projectDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(projectDir, "README.md"), readme, 0644))
+
+		opts := testOptions("light", "center")
+		opts.Renderer = "inkscape"
+		err := generateBanner(context.Background(), projectDir, opts)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown renderer")
+	})
+}
+
+func TestRenderRasters(t *testing.T) {
+	var last RenderOptions
+	withRenderers(t, fakeRenderer{name: "good", available: true, opts: &last})
+
+	svg := `<svg width="1600" height="600"/>`
+	scales := []float64{1, 2}
+	opts, err := rasterOptions(svg, 800, 0, scales, 144, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []RenderOptions{
+		{Width: 800, Height: 300, DPI: 144},
+		{Width: 1600, Height: 600, DPI: 144},
+	}, opts)
+
+	rasters, err := renderRasters(context.Background(), svg, []string{"good"}, scales, opts)
+	require.NoError(t, err)
+	require.Len(t, rasters, 2)
+	assert.Equal(t, 2.0, rasters[1].Scale)
+	assert.Equal(t, "good", rasters[1].Renderer)
+	assert.Equal(t, opts[1], last)
+	assert.Contains(t, string(rasters[0].PNG), "pHYs")
+
+	_, err = rasterOptions(svg, 0, 0, scales, -1, 0)
+	assert.Error(t, err)
+	_, err = rasterOptions(svg, 0, 0, scales, 0, -time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// resvgWasmGz is resvg compiled to wasm32-wasi. See wasm/README.md.
+//
+//go:embed wasm/resvg.wasm.gz
+var resvgWasmGz []byte
+
+// resvgPool keeps compiled resvg WASM instances alive between renders.
+// Compiling the module and loading fonts dominate the cost of a single
+// render, so both happen once per process and instances are reused.
+// Each instance serves one render at a time; concurrent renders take
+// separate instances.
+type resvgPool struct {
+	// cacheDir stores compiled code between runs. Empty keeps it in memory.
+	cacheDir string
+
+	once     sync.Once
+	err      error
+	cache    wazero.CompilationCache
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	fontDirs []string
+
+	idle chan *resvgInstance
+}
+
+// defaultResvgPool is shared by every render in the process. Its compiled
+// code is cached on disk so later runs skip compilation too.
+var defaultResvgPool = newResvgPool(defaultResvgCacheDir(), runtime.NumCPU())
+
+// newResvgPool returns a pool that keeps at most size idle instances.
+func newResvgPool(cacheDir string, size int) *resvgPool {
+	return &resvgPool{
+		cacheDir: cacheDir,
+		idle:     make(chan *resvgInstance, size),
+	}
+}
+
+// resvgCacheRoot is the directory holding compiled code of every version of
+// the embedded module.
+func resvgCacheRoot() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "banner-kit-go", "wazero"), nil
+}
+
+// resvgCacheDir returns the cache directory of the embedded module. It is
+// keyed by the module hash so an upgraded module never loads stale code.
+func resvgCacheDir() (string, error) {
+	root, err := resvgCacheRoot()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(resvgWasmGz)
+	return filepath.Join(root, hex.EncodeToString(sum[:8])), nil
+}
+
+// defaultResvgCacheDir is resvgCacheDir, or empty to cache in memory only
+// when there is no user cache directory.
+func defaultResvgCacheDir() string {
+	dir, err := resvgCacheDir()
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// clearResvgCache removes the compiled code of all module versions and
+// returns the removed directory.
+func clearResvgCache() (string, error) {
+	root, err := resvgCacheRoot()
+	if err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(root); err != nil {
+		return "", fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return root, nil
+}
+
+// newCompilationCache opens the on-disk cache, falling back to memory when
+// the directory cannot be created.
+func (p *resvgPool) newCompilationCache() wazero.CompilationCache {
+	if p.cacheDir != "" {
+		if cache, err := wazero.NewCompilationCacheWithDir(p.cacheDir); err == nil {
+			return cache
+		}
+	}
+	return wazero.NewCompilationCache()
+}
+
+// init compiles the module once. The runtime outlives any single render, so
+// it does not use the caller's context.
+func (p *resvgPool) init() error {
+	p.once.Do(func() {
+		ctx := context.Background()
+		zr, err := gzip.NewReader(bytes.NewReader(resvgWasmGz))
+		if err != nil {
+			p.err = fmt.Errorf("failed to decompress resvg module: %w", err)
+			return
+		}
+		wasm, err := io.ReadAll(zr)
+		if err != nil {
+			p.err = fmt.Errorf("failed to decompress resvg module: %w", err)
+			return
+		}
+
+		p.cache = p.newCompilationCache()
+		// Guest code checks for cancellation, so a render can be stopped
+		// by a timeout or interrupt.
+		config := wazero.NewRuntimeConfig().WithCompilationCache(p.cache).WithCloseOnContextDone(true)
+		p.runtime = wazero.NewRuntimeWithConfig(ctx, config)
+		wasi_snapshot_preview1.MustInstantiate(ctx, p.runtime)
+
+		p.compiled, err = p.runtime.CompileModule(ctx, wasm)
+		if err != nil {
+			p.err = fmt.Errorf("failed to compile resvg module: %w", err)
+			return
+		}
+		p.fontDirs = systemFontDirs()
+	})
+	return p.err
+}
+
+// render converts svg to PNG with an idle instance, creating one when all
+// are busy. When ctx is done the running guest code is stopped.
+func (p *resvgPool) render(ctx context.Context, svg []byte, opts RenderOptions) ([]byte, error) {
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+
+	var inst *resvgInstance
+	select {
+	case inst = <-p.idle:
+		// Fonts cannot be removed from a font database, so an instance
+		// loaded with other fonts is replaced.
+		if inst.fonts != opts.Fonts.key() {
+			inst.close(ctx)
+			inst = nil
+		}
+	default:
+	}
+	if inst == nil {
+		var err error
+		inst, err = p.newInstance(ctx, opts.Fonts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	png, err := inst.render(ctx, withFontFallback(svg, embeddedFontFamily), opts)
+	if err != nil {
+		// resvg panics on invalid input, which leaves the instance's
+		// memory in an unknown state, so it is not reused.
+		inst.close(ctx)
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		// A context that ended after the render may have closed the module
+		// during cleanup.
+		inst.close(ctx)
+		return png, nil
+	}
+	p.release(ctx, inst)
+	return png, nil
+}
+
+func (p *resvgPool) release(ctx context.Context, inst *resvgInstance) {
+	select {
+	case p.idle <- inst:
+	default:
+		inst.close(ctx)
+	}
+}
+
+// close releases the runtime and every idle instance. Renders must not be
+// running.
+func (p *resvgPool) close() error {
+	ctx := context.Background()
+	for {
+		select {
+		case inst := <-p.idle:
+			inst.close(ctx)
+		default:
+			if p.runtime == nil {
+				return nil
+			}
+			if err := p.runtime.Close(ctx); err != nil {
+				return err
+			}
+			return p.cache.Close(ctx)
+		}
+	}
+}
+
+// resvgInstance is one module instance with a resvg renderer whose font
+// database is already loaded.
+type resvgInstance struct {
+	mod      api.Module
+	stderr   *bytes.Buffer
+	renderer uint64
+	// fonts is the key of the extra fonts in the font database.
+	fonts string
+
+	malloc api.Function
+	free   api.Function
+	rend   api.Function
+	dpi    api.Function
+}
+
+func (p *resvgPool) newInstance(ctx context.Context, fonts Fonts) (*resvgInstance, error) {
+	// Font directories are mounted read-only under /fonts. fontdb reads
+	// font files lazily, so the mounts stay for the instance lifetime.
+	// System fonts are only loaded on request, so by default the output
+	// does not depend on the machine.
+	var fontDirs []string
+	if fonts.System {
+		fontDirs = append(fontDirs, p.fontDirs...)
+	}
+	fontDirs = append(fontDirs, fonts.Dirs...)
+	fsConfig := wazero.NewFSConfig()
+	guestDirs := make([]string, len(fontDirs))
+	for i, dir := range fontDirs {
+		guestDirs[i] = fmt.Sprintf("/fonts/%d", i)
+		fsConfig = fsConfig.WithReadOnlyDirMount(dir, guestDirs[i])
+	}
+
+	stderr := &bytes.Buffer{}
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithStderr(stderr).
+		WithFSConfig(fsConfig)
+
+	mod, err := p.runtime.InstantiateModule(ctx, p.compiled, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate resvg module: %w", err)
+	}
+
+	inst := &resvgInstance{
+		mod:    mod,
+		stderr: stderr,
+		fonts:  fonts.key(),
+		malloc: mod.ExportedFunction("__wasm_bytes_malloc"),
+		free:   mod.ExportedFunction("__wasm_bytes_free"),
+		rend:   mod.ExportedFunction("__renderer_render"),
+		dpi:    mod.ExportedFunction("__renderer_options_dpi"),
+	}
+	newRenderer := mod.ExportedFunction("__renderer_new")
+	if inst.malloc == nil || inst.free == nil || inst.rend == nil || inst.dpi == nil || newRenderer == nil {
+		inst.close(ctx)
+		return nil, fmt.Errorf("resvg module is missing exported functions")
+	}
+
+	ret, err := newRenderer.Call(ctx)
+	if err != nil {
+		inst.close(ctx)
+		return nil, fmt.Errorf("failed to create renderer: %w", err)
+	}
+	inst.renderer = ret[0]
+
+	for i, dir := range guestDirs {
+		if err := inst.callWithBytes(ctx, "__renderer_fontdb_load_fonts_dir", []byte(dir)); err != nil {
+			inst.close(ctx)
+			return nil, fmt.Errorf("failed to load fonts from %s: %w", fontDirs[i], err)
+		}
+	}
+	// The embedded fonts are the fallback of every font-family list and
+	// the default family of text without one.
+	embedded, err := embeddedFonts()
+	if err != nil {
+		inst.close(ctx)
+		return nil, err
+	}
+	for _, font := range embedded {
+		if err := inst.callWithBytes(ctx, "__renderer_fontdb_load_font_data", font.data); err != nil {
+			inst.close(ctx)
+			return nil, fmt.Errorf("failed to load embedded font %s: %w", font.name, err)
+		}
+	}
+	if err := inst.callWithBytes(ctx, "__renderer_options_font_family", []byte(embeddedFontFamily)); err != nil {
+		inst.close(ctx)
+		return nil, fmt.Errorf("failed to set default font: %w", err)
+	}
+
+	// Single files are not mounted; their data is copied into the guest.
+	for _, file := range fonts.Files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			inst.close(ctx)
+			return nil, fmt.Errorf("failed to read font: %w", err)
+		}
+		if err := inst.callWithBytes(ctx, "__renderer_fontdb_load_font_data", data); err != nil {
+			inst.close(ctx)
+			return nil, fmt.Errorf("failed to load font %s: %w", file, err)
+		}
+	}
+
+	return inst, nil
+}
+
+// render returns the PNG encoding of svg, stretched to the requested size or
+// at its intrinsic size.
+func (inst *resvgInstance) render(ctx context.Context, svg []byte, opts RenderOptions) ([]byte, error) {
+	inst.stderr.Reset()
+
+	// Instances are reused, so the DPI is set on every render.
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = 96
+	}
+	if _, err := inst.dpi.Call(ctx, inst.renderer, api.EncodeF32(float32(dpi))); err != nil {
+		return nil, fmt.Errorf("failed to set DPI: %w", err)
+	}
+
+	// The renderer takes ownership of the input buffer and frees it.
+	ptr, err := inst.write(ctx, svg)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := inst.rend.Call(ctx, inst.renderer, api.EncodeU32(ptr), api.EncodeU32(uint32(len(svg))),
+		api.EncodeU32(uint32(opts.Width)), api.EncodeU32(uint32(opts.Height)))
+	if err != nil {
+		return nil, fmt.Errorf("resvg rendering failed: %w%s", err, inst.panicMessage())
+	}
+
+	outPtr := uint32(ret[0] >> 32)
+	outLen := uint32(ret[0])
+	defer inst.free.Call(ctx, api.EncodeU32(outPtr), api.EncodeU32(outLen))
+
+	data, ok := inst.mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("resvg rendering failed: output out of memory range")
+	}
+	return bytes.Clone(data), nil
+}
+
+// callWithBytes calls a renderer method that takes ownership of a byte slice.
+func (inst *resvgInstance) callWithBytes(ctx context.Context, name string, data []byte) error {
+	fn := inst.mod.ExportedFunction(name)
+	if fn == nil {
+		return fmt.Errorf("resvg module does not export %s", name)
+	}
+	ptr, err := inst.write(ctx, data)
+	if err != nil {
+		return err
+	}
+	if _, err := fn.Call(ctx, inst.renderer, api.EncodeU32(ptr), api.EncodeU32(uint32(len(data)))); err != nil {
+		return fmt.Errorf("%s failed: %w%s", name, err, inst.panicMessage())
+	}
+	return nil
+}
+
+// write copies data into a buffer allocated in guest memory.
+func (inst *resvgInstance) write(ctx context.Context, data []byte) (uint32, error) {
+	ret, err := inst.malloc.Call(ctx, api.EncodeU32(uint32(len(data))))
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate resvg memory: %w", err)
+	}
+	ptr := api.DecodeU32(ret[0])
+	if ptr == 0 || !inst.mod.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("failed to allocate resvg memory")
+	}
+	return ptr, nil
+}
+
+// panicMessage returns the Rust panic message written to stderr, if any.
+func (inst *resvgInstance) panicMessage() string {
+	msg := strings.TrimSpace(inst.stderr.String())
+	if msg == "" {
+		return ""
+	}
+	return " (" + msg + ")"
+}
+
+func (inst *resvgInstance) close(ctx context.Context) {
+	inst.mod.Close(ctx)
+}
+
+// systemFontDirs lists the existing font directories of the host OS.
+func systemFontDirs() []string {
+	var candidates []string
+	home, _ := os.UserHomeDir()
+
+	switch runtime.GOOS {
+	case "windows":
+		windir := os.Getenv("WINDIR")
+		if windir == "" {
+			windir = `C:\Windows`
+		}
+		candidates = append(candidates, filepath.Join(windir, "Fonts"))
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			candidates = append(candidates, filepath.Join(local, "Microsoft", "Windows", "Fonts"))
+		}
+	case "darwin":
+		candidates = append(candidates, "/Library/Fonts", "/System/Library/Fonts")
+		if home != "" {
+			candidates = append(candidates, filepath.Join(home, "Library", "Fonts"))
+		}
+		if assets, err := filepath.Glob("/System/Library/AssetsV2/com_apple_MobileAsset_Font*"); err == nil {
+			candidates = append(candidates, assets...)
+		}
+	default:
+		candidates = append(candidates, "/usr/share/fonts", "/usr/local/share/fonts")
+		if home != "" {
+			candidates = append(candidates, filepath.Join(home, ".fonts"), filepath.Join(home, ".local", "share", "fonts"))
+		}
+	}
+
+	var dirs []string
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
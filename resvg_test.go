@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResvgPool(t *testing.T) {
+	pool := newResvgPool("", 2)
+	t.Cleanup(func() { pool.close() })
+
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="120" height="40">
+		<rect width="120" height="40" fill="green"/>
+	</svg>`)
+
+	t.Run("reuses instances", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			data, err := pool.render(context.Background(), svg, RenderOptions{})
+			require.NoError(t, err)
+
+			config, err := png.DecodeConfig(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, 120, config.Width)
+			assert.Equal(t, 40, config.Height)
+		}
+		assert.Len(t, pool.idle, 1)
+	})
+
+	t.Run("explicit size", func(t *testing.T) {
+		data, err := pool.render(context.Background(), svg, RenderOptions{Width: 240, Height: 80, DPI: 192})
+		require.NoError(t, err)
+
+		config, err := png.DecodeConfig(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, 240, config.Width)
+		assert.Equal(t, 80, config.Height)
+
+	})
+
+	t.Run("DPI resolves physical units", func(t *testing.T) {
+		inch := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="1in" height="0.5in"/>`)
+		for _, tt := range []struct {
+			dpi   float64
+			width int
+		}{{192, 192}, {0, 96}} {
+			// The second render reuses the instance, so the DPI must reset.
+			data, err := pool.render(context.Background(), inch, RenderOptions{DPI: tt.dpi})
+			require.NoError(t, err)
+			config, err := png.DecodeConfig(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, tt.width, config.Width, "dpi %v", tt.dpi)
+		}
+	})
+
+	t.Run("mm size passes validation at the DPI", func(t *testing.T) {
+		mm := `<svg xmlns="http://www.w3.org/2000/svg" width="100mm" height="50mm" viewBox="0 0 100 50"><rect width="100" height="50" fill="#245A74"/><rect width="50" height="50" fill="#FFFFFF"/></svg>`
+		opts := RenderOptions{DPI: 192}
+		data, err := pool.render(context.Background(), []byte(mm), opts)
+		require.NoError(t, err)
+		config, err := png.DecodeConfig(bytes.NewReader(data))
+		require.NoError(t, err)
+		// 755.9x377.95 pixels, which resvg rounds down.
+		assert.Equal(t, 755, config.Width)
+		assert.Equal(t, 377, config.Height)
+		assert.NoError(t, validateRaster(mm, data, opts))
+
+		w, h, err := outputSize(mm, 0, 0, 2, opts.DPI)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1512, 756}, []int{w, h})
+	})
+
+	t.Run("concurrent renders", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make([]error, 8)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				data, err := pool.render(context.Background(), svg, RenderOptions{})
+				if err == nil {
+					_, err = png.DecodeConfig(bytes.NewReader(data))
+				}
+				errs[i] = err
+			}(i)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+		assert.LessOrEqual(t, len(pool.idle), cap(pool.idle))
+	})
+
+	t.Run("invalid SVG discards the instance", func(t *testing.T) {
+		_, err := pool.render(context.Background(), []byte("not an svg"), RenderOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "resvg rendering failed")
+
+		_, err = pool.render(context.Background(), svg, RenderOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("timeout stops a slow render", func(t *testing.T) {
+		slow := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="4000" height="4000">` +
+			`<filter id="blur"><feGaussianBlur stdDeviation="300"/><feMorphology radius="50"/></filter>` +
+			`<rect width="4000" height="4000" fill="#f00" filter="url(#blur)"/></svg>`)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := pool.render(ctx, slow, RenderOptions{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		_, err = pool.render(context.Background(), svg, RenderOptions{})
+		assert.NoError(t, err)
+	})
+}
+
+func TestResvgPoolFonts(t *testing.T) {
+	const serif = "/usr/share/fonts/truetype/dejavu/DejaVuSerif.ttf"
+	if _, err := os.Stat(serif); err != nil {
+		t.Skip("DejaVu Serif is not installed")
+	}
+
+	pool := newResvgPool("", 1)
+	t.Cleanup(func() { pool.close() })
+	require.NoError(t, pool.init())
+	pool.fontDirs = []string{filepath.Dir(serif)}
+
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="200" height="60">
+		<text x="10" y="40" font-family="'Missing Font', 'DejaVu Serif'" font-size="32" fill="#000">Fonts</text>
+	</svg>`)
+	render := func(t *testing.T, fonts Fonts) []byte {
+		t.Helper()
+		data, err := pool.render(context.Background(), svg, RenderOptions{Fonts: fonts})
+		require.NoError(t, err)
+		return data
+	}
+
+	embedded := render(t, Fonts{})
+	assert.NotEqual(t, transparentPNG(t, 200, 60), embedded, "embedded fallback should draw the text")
+	assert.NotEqual(t, embedded, render(t, Fonts{Files: []string{serif}}), "font file")
+	assert.NotEqual(t, embedded, render(t, Fonts{Dirs: []string{filepath.Dir(serif)}}), "font directory")
+	assert.NotEqual(t, embedded, render(t, Fonts{System: true}), "system fonts")
+	// The pooled instance with other fonts must not leak into later renders.
+	assert.Equal(t, embedded, render(t, Fonts{}), "fonts from an earlier render")
+}
+
+// transparentPNG renders an empty SVG of the given size.
+func transparentPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	pool := newResvgPool("", 1)
+	defer pool.close()
+	data, err := pool.render(context.Background(), []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d"/>`, width, height)), RenderOptions{})
+	require.NoError(t, err)
+	return data
+}
+
+func TestResvgEmbeddedFont(t *testing.T) {
+	pool := newResvgPool("", 1)
+	t.Cleanup(func() { pool.close() })
+	require.NoError(t, pool.init())
+	// Simulate a machine without any fonts.
+	pool.fontDirs = nil
+
+	theme, err := getTheme("dark")
+	require.NoError(t, err)
+	render := func(name string) []byte {
+		svg, err := generateSVG(&Metadata{Name: name}, theme, "center", []string{}, defaultCanvas)
+		require.NoError(t, err)
+		data, err := pool.render(context.Background(), []byte(svg), RenderOptions{})
+		require.NoError(t, err)
+		return data
+	}
+
+	// The built-in font-family lists resolve to the embedded font, so the
+	// title is drawn and the output is the same on every run.
+	titled := render("Embedded")
+	assert.NotEqual(t, render(""), titled)
+	assert.Equal(t, titled, render("Embedded"))
+}
+
+func TestResvgCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "cache"))
+	t.Setenv("HOME", home)
+	t.Setenv("LocalAppData", filepath.Join(home, "cache"))
+
+	dir, err := resvgCacheDir()
+	require.NoError(t, err)
+	root, err := resvgCacheRoot()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dir, home), "cache dir %s outside home", dir)
+	assert.Equal(t, root, filepath.Dir(dir))
+	assert.Len(t, filepath.Base(dir), 16)
+
+	pool := newResvgPool(dir, 1)
+	_, err = pool.render(context.Background(), []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"/>`), RenderOptions{})
+	require.NoError(t, err)
+	require.NoError(t, pool.close())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.NotEmpty(t, entries, "compiled code should be cached on disk")
+
+	removed, err := clearResvgCache()
+	require.NoError(t, err)
+	assert.Equal(t, root, removed)
+	assert.NoDirExists(t, root)
+
+	// Clearing an empty cache is not an error.
+	_, err = clearResvgCache()
+	assert.NoError(t, err)
+}
+
+func benchmarkBanner(b *testing.B) []byte {
+	b.Helper()
+	theme, err := getTheme("dark")
+	require.NoError(b, err)
+	svg, err := generateSVG(&Metadata{Name: "Benchmark", Tagline: "Rendering in batch"}, theme, "center", []string{}, defaultCanvas)
+	require.NoError(b, err)
+	return []byte(svg)
+}
+
+// BenchmarkResvgFresh measures the cost of setting up resvg for every
+// render, as happens without the pool.
+func BenchmarkResvgFresh(b *testing.B) {
+	svg := benchmarkBanner(b)
+	for i := 0; i < b.N; i++ {
+		pool := newResvgPool("", 1)
+		_, err := pool.render(context.Background(), svg, RenderOptions{})
+		require.NoError(b, err)
+		pool.close()
+	}
+}
+
+func BenchmarkResvgPooled(b *testing.B) {
+	svg := benchmarkBanner(b)
+	pool := newResvgPool("", 1)
+	defer pool.close()
+
+	_, err := pool.render(context.Background(), svg, RenderOptions{})
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := pool.render(context.Background(), svg, RenderOptions{})
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkResvgPooledParallel(b *testing.B) {
+	svg := benchmarkBanner(b)
+	pool := newResvgPool("", 4)
+	defer pool.close()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := pool.render(context.Background(), svg, RenderOptions{})
+			require.NoError(b, err)
+		}
+	})
+}
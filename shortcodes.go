@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// shortcodeFS holds the shortcode tables: Nerd Font icons as :nf-fa-rocket:
+// and GitHub-style emoji as :rocket:.
+//
+//go:embed shortcodes/*.txt
+var shortcodeFS embed.FS
+
+// shortcodeRe matches a shortcode candidate. Candidates without a letter
+// that are not in the table, such as ":30:" in "10:30:00", are left alone
+// without a warning.
+var shortcodeRe = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+
+// shortcodeTable maps shortcode names, without colons, to their text.
+var shortcodeTable = sync.OnceValues(func() (map[string]string, error) {
+	table := map[string]string{}
+	paths, err := fs.Glob(shortcodeFS, "shortcodes/*.txt")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := shortcodeFS.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := parseShortcodes(string(data), table); err != nil {
+			return nil, fmt.Errorf("invalid shortcode table %s: %w", path, err)
+		}
+	}
+	return table, nil
+})
+
+// parseShortcodes adds the lines "name XXXX [YYYY...]" of a table, with the
+// code points in hex, to table. Blank lines and # comments are skipped.
+func parseShortcodes(data string, table map[string]string) error {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || !shortcodeRe.MatchString(":"+fields[0]+":") {
+			return fmt.Errorf("line %d: expected a name and code points", line)
+		}
+		if _, ok := table[fields[0]]; ok {
+			return fmt.Errorf("line %d: duplicate shortcode %q", line, fields[0])
+		}
+		var text strings.Builder
+		for _, field := range fields[1:] {
+			code, err := strconv.ParseUint(field, 16, 32)
+			if err != nil || code > 0x10FFFF {
+				return fmt.Errorf("line %d: invalid code point %q", line, field)
+			}
+			text.WriteRune(rune(code))
+		}
+		table[fields[0]] = text.String()
+	}
+	return scanner.Err()
+}
+
+// expandShortcodes replaces the known shortcodes in s and returns the
+// unknown ones, which are kept as written.
+func expandShortcodes(s string) (string, []string, error) {
+	if !strings.Contains(s, ":") {
+		return s, nil, nil
+	}
+	table, err := shortcodeTable()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var unknown []string
+	expanded := shortcodeRe.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[1 : len(m)-1]
+		if text, ok := table[name]; ok {
+			return text
+		}
+		if strings.ContainsAny(name, "abcdefghijklmnopqrstuvwxyz") {
+			unknown = append(unknown, m)
+		}
+		return m
+	})
+	return expanded, unknown, nil
+}
+
+// expandTextShortcodes expands the shortcodes in the title, tagline and
+// badges in place. It returns a warning for every unknown shortcode.
+func expandTextShortcodes(metadata *Metadata, badges []string) ([]string, error) {
+	var warnings []string
+	expand := func(slot string, s *string) error {
+		expanded, unknown, err := expandShortcodes(*s)
+		if err != nil {
+			return err
+		}
+		*s = expanded
+		for _, code := range unknown {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown shortcode %s", slot, code))
+		}
+		return nil
+	}
+
+	if err := expand("title", &metadata.Name); err != nil {
+		return nil, err
+	}
+	if err := expand("tagline", &metadata.Tagline); err != nil {
+		return nil, err
+	}
+	for i := range badges {
+		if err := expand(badgeID(i+1), &badges[i]); err != nil {
+			return nil, err
+		}
+	}
+	return warnings, nil
+}
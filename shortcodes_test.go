@@ -0,0 +1,138 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortcodeTable(t *testing.T) {
+	table, err := shortcodeTable()
+	require.NoError(t, err)
+	assert.Equal(t, "\U0001F680", table["rocket"])
+	assert.Equal(t, "❤\ufe0f", table["heart"])
+	assert.Equal(t, "\uf135", table["nf-fa-rocket"])
+	assert.Equal(t, "\ue724", table["nf-dev-go"])
+	assert.Equal(t, table["+1"], table["thumbsup"])
+
+	// Only the Nerd Font sets named in the README are included.
+	for name := range table {
+		if set, ok := strings.CutPrefix(name, "nf-"); ok {
+			set, _, _ = strings.Cut(set, "-")
+			assert.Contains(t, []string{"fa", "dev", "seti", "linux"}, set, name)
+		}
+	}
+}
+
+func TestParseShortcodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		expected    map[string]string
+		expectError string
+	}{
+		{
+			name:     "comments and sequences",
+			data:     "# comment\n\nrocket 1F680\ntechnologist 1F9D1 200D 1F4BB\n",
+			expected: map[string]string{"rocket": "\U0001F680", "technologist": "\U0001F9D1\u200d\U0001F4BB"},
+		},
+		{
+			name:        "missing code point",
+			data:        "rocket\n",
+			expectError: "line 1",
+		},
+		{
+			name:        "invalid code point",
+			data:        "rocket 1F680\nfire XYZ\n",
+			expectError: `line 2: invalid code point "XYZ"`,
+		},
+		{
+			name:        "duplicate",
+			data:        "rocket 1F680\nrocket 1F681\n",
+			expectError: "duplicate shortcode",
+		},
+		{
+			name:        "invalid name",
+			data:        "Rocket 1F680\n",
+			expectError: "line 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := map[string]string{}
+			err := parseShortcodes(tt.data, table)
+
+			if tt.expectError != "" {
+				assert.ErrorContains(t, err, tt.expectError)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, table)
+			}
+		})
+	}
+}
+
+func TestExpandShortcodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		unknown  []string
+	}{
+		{
+			name:     "no shortcodes",
+			input:    "Banner Kit",
+			expected: "Banner Kit",
+		},
+		{
+			name:     "emoji and Nerd Font icons",
+			input:    ":nf-dev-go: Go :rocket::sparkles:",
+			expected: "\ue724 Go \U0001F680✨",
+		},
+		{
+			name:     "aliases with signs and digits",
+			input:    ":+1: :100:",
+			expected: "\U0001F44D \U0001F4AF",
+		},
+		{
+			name:     "times are not shortcodes",
+			input:    "Daily at 10:30:00",
+			expected: "Daily at 10:30:00",
+		},
+		{
+			name:     "unknown shortcode is kept",
+			input:    ":nf-fa-nope: and :unicorn_face:",
+			expected: ":nf-fa-nope: and :unicorn_face:",
+			unknown:  []string{":nf-fa-nope:", ":unicorn_face:"},
+		},
+		{
+			name:     "markup is left to escaping",
+			input:    "<:zap:>",
+			expected: "<⚡>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expanded, unknown, err := expandShortcodes(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, expanded)
+			assert.Equal(t, tt.unknown, unknown)
+		})
+	}
+}
+
+func TestExpandTextShortcodes(t *testing.T) {
+	metadata := &Metadata{Name: ":nf-fa-github: Kit", Tagline: "Fast :zap: :nope:"}
+	badges := []string{":package: v1", ":what:"}
+
+	warnings, err := expandTextShortcodes(metadata, badges)
+	require.NoError(t, err)
+	assert.Equal(t, "\uf09b Kit", metadata.Name)
+	assert.Equal(t, "Fast ⚡ :nope:", metadata.Tagline)
+	assert.Equal(t, []string{"\U0001F4E6 v1", ":what:"}, badges)
+	assert.Equal(t, []string{"tagline: unknown shortcode :nope:", "badge-2: unknown shortcode :what:"}, warnings)
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// SVGNodeKind identifies the type of a node in an SVGDocument.
+type SVGNodeKind int
+
+const (
+	ElementNode SVGNodeKind = iota
+	TextNode
+	CommentNode
+	ProcInstNode
+	DirectiveNode
+)
+
+// SVGAttr is an attribute with its name qualified as written in the source,
+// e.g. "xlink:href".
+type SVGAttr struct {
+	Name  string
+	Value string
+}
+
+// SVGNode is a node of an SVGDocument. Text holds the unescaped character
+// data of text nodes and the raw content of comments, processing
+// instructions and directives.
+type SVGNode struct {
+	Kind     SVGNodeKind
+	Name     string
+	Attrs    []SVGAttr
+	Children []*SVGNode
+	Text     string
+	Parent   *SVGNode
+}
+
+// SVGDocument is a minimal, order-preserving SVG document model. Prolog holds
+// the nodes before the root element, such as the XML declaration.
+type SVGDocument struct {
+	Prolog []*SVGNode
+	Root   *SVGNode
+}
+
+// parseSVG builds a document from SVG source. Namespace prefixes are kept as
+// written so documents exported by editors round-trip unchanged. Internal
+// entities declared in a DOCTYPE are expanded, and that DOCTYPE is dropped.
+func parseSVG(data string) (*SVGDocument, error) {
+	decoder := xml.NewDecoder(strings.NewReader(data))
+	decoder.Strict = true
+
+	doc := &SVGDocument{}
+	var current *SVGNode
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SVG: %w", err)
+		}
+
+		var node *SVGNode
+		switch t := token.(type) {
+		case xml.StartElement:
+			node = &SVGNode{Kind: ElementNode, Name: qualifiedName(t.Name)}
+			for _, attr := range t.Attr {
+				node.Attrs = append(node.Attrs, SVGAttr{Name: qualifiedName(attr.Name), Value: attr.Value})
+			}
+		case xml.EndElement:
+			if current == nil || current.Name != qualifiedName(t.Name) {
+				return nil, fmt.Errorf("failed to parse SVG: unexpected end element </%s>", qualifiedName(t.Name))
+			}
+			current = current.Parent
+			continue
+		case xml.CharData:
+			node = &SVGNode{Kind: TextNode, Text: string(t)}
+		case xml.Comment:
+			node = &SVGNode{Kind: CommentNode, Text: string(t)}
+		case xml.ProcInst:
+			node = &SVGNode{Kind: ProcInstNode, Name: t.Target, Text: string(t.Inst)}
+		case xml.Directive:
+			if entities := doctypeEntities(string(t)); entities != nil {
+				// Editors such as Illustrator declare namespace URLs as
+				// entities. They are expanded while parsing, so the DOCTYPE
+				// is no longer needed and renderers never see it.
+				decoder.Entity = entities
+				continue
+			}
+			node = &SVGNode{Kind: DirectiveNode, Text: string(t)}
+		}
+
+		switch {
+		case current != nil:
+			current.AppendChild(node)
+		case node.Kind == ElementNode:
+			if doc.Root != nil {
+				return nil, fmt.Errorf("failed to parse SVG: multiple root elements")
+			}
+			doc.Root = node
+		case node.Kind == TextNode && strings.TrimSpace(node.Text) == "":
+			// whitespace outside the root element is re-created on output
+		case node.Kind == TextNode:
+			return nil, fmt.Errorf("failed to parse SVG: text outside root element")
+		case doc.Root == nil:
+			doc.Prolog = append(doc.Prolog, node)
+		}
+
+		if node.Kind == ElementNode {
+			current = node
+		}
+	}
+
+	if doc.Root == nil {
+		return nil, fmt.Errorf("failed to parse SVG: no root element")
+	}
+	if current != nil {
+		return nil, fmt.Errorf("failed to parse SVG: unclosed element <%s>", current.Name)
+	}
+
+	return doc, nil
+}
+
+// entityDeclRe matches an internal general entity declaration of a DOCTYPE.
+var entityDeclRe = regexp.MustCompile(`<!ENTITY\s+([^\s%"'>]+)\s+(?:"([^"]*)"|'([^']*)')\s*>`)
+
+// doctypeEntities returns the internal entities a DOCTYPE directive
+// declares, or nil when it declares none. External entities are not read.
+func doctypeEntities(directive string) map[string]string {
+	if !strings.HasPrefix(directive, "DOCTYPE") {
+		return nil
+	}
+	var entities map[string]string
+	for _, m := range entityDeclRe.FindAllStringSubmatch(directive, -1) {
+		if entities == nil {
+			entities = map[string]string{}
+		}
+		entities[m[1]] = m[2] + m[3]
+	}
+	return entities
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// String serializes the document. Attribute order and whitespace text are
+// preserved, so identical documents always produce identical output.
+func (d *SVGDocument) String() string {
+	var buf bytes.Buffer
+	for _, node := range d.Prolog {
+		writeNode(&buf, node)
+		buf.WriteByte('\n')
+	}
+	writeNode(&buf, d.Root)
+	buf.WriteByte('\n')
+	return buf.String()
+}
+
+func writeNode(buf *bytes.Buffer, n *SVGNode) {
+	switch n.Kind {
+	case ElementNode:
+		buf.WriteByte('<')
+		buf.WriteString(n.Name)
+		for _, attr := range n.Attrs {
+			fmt.Fprintf(buf, ` %s="%s"`, attr.Name, escapeAttr(attr.Value))
+		}
+		if len(n.Children) == 0 {
+			buf.WriteString("/>")
+			return
+		}
+		buf.WriteByte('>')
+		for _, child := range n.Children {
+			writeNode(buf, child)
+		}
+		buf.WriteString("</")
+		buf.WriteString(n.Name)
+		buf.WriteByte('>')
+	case TextNode:
+		buf.WriteString(escapeXML(n.Text))
+	case CommentNode:
+		buf.WriteString("<!--")
+		buf.WriteString(n.Text)
+		buf.WriteString("-->")
+	case ProcInstNode:
+		buf.WriteString("<?")
+		buf.WriteString(n.Name)
+		if n.Text != "" {
+			buf.WriteByte(' ')
+			buf.WriteString(n.Text)
+		}
+		buf.WriteString("?>")
+	case DirectiveNode:
+		buf.WriteString("<!")
+		buf.WriteString(n.Text)
+		buf.WriteByte('>')
+	}
+}
+
+// escapeAttr escapes a value for a double-quoted attribute. Apostrophes are
+// left alone since font-family lists use them for quoting.
+func escapeAttr(s string) string {
+	return strings.ReplaceAll(escapeXML(s), "&apos;", "'")
+}
+
+// Walk calls fn for n and every descendant in document order. Returning
+// false from fn skips the node's children.
+func (n *SVGNode) Walk(fn func(*SVGNode) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range append([]*SVGNode(nil), n.Children...) {
+		child.Walk(fn)
+	}
+}
+
+// FindByID returns the first element with the given id, or nil.
+func (n *SVGNode) FindByID(id string) *SVGNode {
+	var found *SVGNode
+	n.Walk(func(node *SVGNode) bool {
+		if found != nil {
+			return false
+		}
+		if node.Kind == ElementNode {
+			if v, ok := node.Attr("id"); ok && v == id {
+				found = node
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// FindByClass returns every element whose class list contains class.
+func (n *SVGNode) FindByClass(class string) []*SVGNode {
+	var found []*SVGNode
+	n.Walk(func(node *SVGNode) bool {
+		if node.Kind == ElementNode && node.HasClass(class) {
+			found = append(found, node)
+		}
+		return true
+	})
+	return found
+}
+
+// FindByName returns every element with the given qualified name.
+func (n *SVGNode) FindByName(name string) []*SVGNode {
+	var found []*SVGNode
+	n.Walk(func(node *SVGNode) bool {
+		if node.Kind == ElementNode && node.Name == name {
+			found = append(found, node)
+		}
+		return true
+	})
+	return found
+}
+
+func (n *SVGNode) HasClass(class string) bool {
+	v, ok := n.Attr("class")
+	if !ok {
+		return false
+	}
+	for _, c := range strings.Fields(v) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *SVGNode) Attr(name string) (string, bool) {
+	for _, attr := range n.Attrs {
+		if attr.Name == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// SetAttr updates an attribute in place, or appends it if missing.
+func (n *SVGNode) SetAttr(name, value string) {
+	for i := range n.Attrs {
+		if n.Attrs[i].Name == name {
+			n.Attrs[i].Value = value
+			return
+		}
+	}
+	n.Attrs = append(n.Attrs, SVGAttr{Name: name, Value: value})
+}
+
+func (n *SVGNode) RemoveAttr(name string) {
+	for i := range n.Attrs {
+		if n.Attrs[i].Name == name {
+			n.Attrs = append(n.Attrs[:i], n.Attrs[i+1:]...)
+			return
+		}
+	}
+}
+
+// TextContent returns the concatenated character data of n and its
+// descendants.
+func (n *SVGNode) TextContent() string {
+	var b strings.Builder
+	n.Walk(func(node *SVGNode) bool {
+		if node.Kind == TextNode {
+			b.WriteString(node.Text)
+		}
+		return true
+	})
+	return b.String()
+}
+
+// SetText replaces all children of n with a single text node.
+func (n *SVGNode) SetText(text string) {
+	for _, child := range n.Children {
+		child.Parent = nil
+	}
+	n.Children = nil
+	n.AppendChild(&SVGNode{Kind: TextNode, Text: text})
+}
+
+func (n *SVGNode) AppendChild(child *SVGNode) {
+	child.Parent = n
+	n.Children = append(n.Children, child)
+}
+
+// InsertAfter inserts node as the next sibling of n.
+func (n *SVGNode) InsertAfter(node *SVGNode) {
+	parent := n.Parent
+	if parent == nil {
+		return
+	}
+	idx := parent.childIndex(n)
+	if idx < 0 {
+		return
+	}
+	node.Parent = parent
+	parent.Children = append(parent.Children, nil)
+	copy(parent.Children[idx+2:], parent.Children[idx+1:])
+	parent.Children[idx+1] = node
+}
+
+// InsertBefore inserts node as the previous sibling of n.
+func (n *SVGNode) InsertBefore(node *SVGNode) {
+	parent := n.Parent
+	if parent == nil {
+		return
+	}
+	idx := parent.childIndex(n)
+	if idx < 0 {
+		return
+	}
+	node.Parent = parent
+	parent.Children = append(parent.Children, nil)
+	copy(parent.Children[idx+1:], parent.Children[idx:])
+	parent.Children[idx] = node
+}
+
+// Remove detaches n from its parent, together with the whitespace that
+// indented it, so removed elements leave no blank lines behind.
+func (n *SVGNode) Remove() {
+	parent := n.Parent
+	if parent == nil {
+		return
+	}
+	idx := parent.childIndex(n)
+	if idx < 0 {
+		return
+	}
+	start := idx
+	if idx > 0 {
+		prev := parent.Children[idx-1]
+		if prev.Kind == TextNode && strings.TrimSpace(prev.Text) == "" {
+			start = idx - 1
+		}
+	}
+	parent.Children = append(parent.Children[:start], parent.Children[idx+1:]...)
+	n.Parent = nil
+}
+
+// Clone returns a deep copy of n that is not attached to any parent.
+func (n *SVGNode) Clone() *SVGNode {
+	clone := &SVGNode{
+		Kind:  n.Kind,
+		Name:  n.Name,
+		Attrs: append([]SVGAttr(nil), n.Attrs...),
+		Text:  n.Text,
+	}
+	for _, child := range n.Children {
+		clone.AppendChild(child.Clone())
+	}
+	return clone
+}
+
+func (n *SVGNode) childIndex(child *SVGNode) int {
+	for i, c := range n.Children {
+		if c == child {
+			return i
+		}
+	}
+	return -1
+}
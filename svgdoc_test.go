@@ -0,0 +1,214 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSVG(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{
+			name: "declaration, comments and whitespace round-trip",
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<svg width="10" height="10">
+  <!-- Background -->
+  <rect x="0" y="0"/>
+</svg>`,
+			expected: `<?xml version="1.0" encoding="UTF-8"?>
+<svg width="10" height="10">
+  <!-- Background -->
+  <rect x="0" y="0"/>
+</svg>
+`,
+		},
+		{
+			name:     "namespace prefixes are preserved",
+			input:    `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#a"/></svg>`,
+			expected: `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#a"/></svg>` + "\n",
+		},
+		{
+			name:     "multi-line attributes are normalized",
+			input:    "<svg><rect\n    x=\"1\"\n    y=\"2\"\n  /></svg>",
+			expected: `<svg><rect x="1" y="2"/></svg>` + "\n",
+		},
+		{
+			name:     "entities are decoded and re-escaped",
+			input:    `<svg><text font-family="'Hack'">&#128640; &amp; &lt;b&gt;</text></svg>`,
+			expected: `<svg><text font-family="'Hack'">&#128640; &amp; &lt;b&gt;</text></svg>` + "\n",
+		},
+		{
+			name: "DOCTYPE entities are expanded",
+			input: `<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd" [
+	<!ENTITY ns_svg "http://www.w3.org/2000/svg">
+	<!ENTITY ns_xlink 'http://www.w3.org/1999/xlink'>
+	<!ENTITY % param "ignored">
+	<!ENTITY ext SYSTEM "file:///etc/passwd">
+]>
+<svg xmlns="&ns_svg;" xmlns:xlink="&ns_xlink;"><text>&ns_svg;</text></svg>`,
+			expected: `<?xml version="1.0" encoding="utf-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><text>http://www.w3.org/2000/svg</text></svg>
+`,
+		},
+		{
+			name: "DOCTYPE without entities is kept",
+			input: `<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<svg/>`,
+			expected: `<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<svg/>
+`,
+		},
+		{
+			name: "external entities are not read",
+			input: `<!DOCTYPE svg [<!ENTITY a "a"><!ENTITY ext SYSTEM "file:///etc/passwd">]>
+<svg>&ext;</svg>`,
+			expectError: true,
+		},
+		{
+			name:        "mismatched end element",
+			input:       `<svg><g></svg>`,
+			expectError: true,
+		},
+		{
+			name:        "unclosed element",
+			input:       `<svg><g>`,
+			expectError: true,
+		},
+		{
+			name:        "no root element",
+			input:       `<!-- nothing -->`,
+			expectError: true,
+		},
+		{
+			name:        "not xml",
+			input:       `not an svg`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parseSVG(tt.input)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Nil(t, doc)
+				assert.Contains(t, err.Error(), "failed to parse SVG")
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, doc.String())
+			}
+		})
+	}
+}
+
+func TestSVGNodeQueries(t *testing.T) {
+	doc, err := parseSVG(`<svg>
+  <g id="card" class="panel glass">
+    <text id="title" class="label">Title</text>
+    <text class="label muted">Tagline</text>
+  </g>
+  <rect class="panel"/>
+</svg>`)
+	require.NoError(t, err)
+
+	t.Run("find by id", func(t *testing.T) {
+		title := doc.Root.FindByID("title")
+		require.NotNil(t, title)
+		assert.Equal(t, "text", title.Name)
+		assert.Equal(t, "Title", title.TextContent())
+		assert.Nil(t, doc.Root.FindByID("missing"))
+	})
+
+	t.Run("find by class", func(t *testing.T) {
+		assert.Len(t, doc.Root.FindByClass("panel"), 2)
+		assert.Len(t, doc.Root.FindByClass("label"), 2)
+		assert.Len(t, doc.Root.FindByClass("muted"), 1)
+		assert.Empty(t, doc.Root.FindByClass("pane"))
+	})
+
+	t.Run("find by name", func(t *testing.T) {
+		assert.Len(t, doc.Root.FindByName("text"), 2)
+		assert.Len(t, doc.Root.FindByName("rect"), 1)
+	})
+
+	t.Run("text content", func(t *testing.T) {
+		card := doc.Root.FindByID("card")
+		assert.Contains(t, card.TextContent(), "Title")
+		assert.Contains(t, card.TextContent(), "Tagline")
+	})
+}
+
+func TestSVGNodeEditing(t *testing.T) {
+	t.Run("set and remove attributes", func(t *testing.T) {
+		doc, err := parseSVG(`<svg><rect x="1" y="2"/></svg>`)
+		require.NoError(t, err)
+
+		rect := doc.Root.FindByName("rect")[0]
+		rect.SetAttr("x", "10")
+		rect.SetAttr("fill", "#FFF")
+		rect.RemoveAttr("y")
+		rect.RemoveAttr("missing")
+
+		assert.Equal(t, `<svg><rect x="10" fill="#FFF"/></svg>`+"\n", doc.String())
+	})
+
+	t.Run("clone and insert", func(t *testing.T) {
+		doc, err := parseSVG(`<svg><g id="badge-1"><text>one</text></g><circle/></svg>`)
+		require.NoError(t, err)
+
+		badge := doc.Root.FindByID("badge-1")
+		clone := badge.Clone()
+		assert.Nil(t, clone.Parent)
+
+		clone.SetAttr("id", "badge-2")
+		clone.FindByName("text")[0].SetText("two")
+		badge.InsertAfter(clone)
+
+		assert.Equal(t, `<svg><g id="badge-1"><text>one</text></g><g id="badge-2"><text>two</text></g><circle/></svg>`+"\n", doc.String())
+		assert.Equal(t, doc.Root, clone.Parent)
+	})
+
+	t.Run("insert before", func(t *testing.T) {
+		doc, err := parseSVG(`<svg><text/><circle/></svg>`)
+		require.NoError(t, err)
+
+		circle := doc.Root.FindByName("circle")[0]
+		circle.InsertBefore(&SVGNode{Kind: ElementNode, Name: "rect"})
+		doc.Root.FindByName("text")[0].InsertBefore(&SVGNode{Kind: ElementNode, Name: "g"})
+
+		assert.Equal(t, `<svg><g/><text/><rect/><circle/></svg>`+"\n", doc.String())
+		assert.Equal(t, doc.Root, doc.Root.FindByName("rect")[0].Parent)
+	})
+
+	t.Run("remove drops indentation", func(t *testing.T) {
+		doc, err := parseSVG("<svg>\n  <rect/>\n  <circle/>\n</svg>")
+		require.NoError(t, err)
+
+		doc.Root.FindByName("rect")[0].Remove()
+		assert.Equal(t, "<svg>\n  <circle/>\n</svg>\n", doc.String())
+	})
+
+	t.Run("serialization is deterministic", func(t *testing.T) {
+		template, err := loadTemplate("center")
+		require.NoError(t, err)
+
+		first, err := parseSVG(template)
+		require.NoError(t, err)
+		second, err := parseSVG(template)
+		require.NoError(t, err)
+
+		assert.Equal(t, first.String(), second.String())
+
+		reparsed, err := parseSVG(first.String())
+		require.NoError(t, err)
+		assert.Equal(t, first.String(), reparsed.String())
+	})
+}
@@ -2,53 +2,125 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"regexp"
+	"sort"
 	"strings"
 )
 
+// ThemePalette is a theme's colors: the background gradient and the two
+// colors of the background shapes.
 type ThemePalette struct {
-	BG0   string
-	BG1   string
-	BG2   string
-	WAVE0 string
-	WAVE1 string
+	Background Gradient `yaml:"background"`
+	WAVE0      string   `yaml:"wave0"`
+	WAVE1      string   `yaml:"wave1"`
 }
 
 var themes = map[string]ThemePalette{
 	"light": {
-		BG0:   "#8BCFE6",
-		BG1:   "#F2B5C8",
-		BG2:   "#F8F9FB",
+		Background: Gradient{Type: "linear", Angle: 135, Stops: []GradientStop{
+			{Color: "#8BCFE6", Offset: 0},
+			{Color: "#F2B5C8", Offset: 50},
+			{Color: "#F8F9FB", Offset: 100},
+		}},
 		WAVE0: "#9DD7EC",
 		WAVE1: "#F6AFC3",
 	},
 	"muted": {
-		BG0:   "#7FC3DD",
-		BG1:   "#EFAEC2",
-		BG2:   "#F3F5F7",
+		Background: Gradient{Type: "linear", Angle: 135, Stops: []GradientStop{
+			{Color: "#7FC3DD", Offset: 0},
+			{Color: "#EFAEC2", Offset: 50},
+			{Color: "#F3F5F7", Offset: 100},
+		}},
 		WAVE0: "#8FCFE3",
 		WAVE1: "#F2A7BE",
 	},
 	"dark": {
-		BG0:   "#245A74",
-		BG1:   "#7A3651",
-		BG2:   "#0F1720",
+		Background: Gradient{Type: "linear", Angle: 135, Stops: []GradientStop{
+			{Color: "#245A74", Offset: 0},
+			{Color: "#7A3651", Offset: 50},
+			{Color: "#0F1720", Offset: 100},
+		}},
 		WAVE0: "#3A7C96",
 		WAVE1: "#A35A74",
 	},
+	// The six stripes of the rainbow flag, darkened where white text
+	// would be hard to read.
+	"pride": {
+		Background: Gradient{Type: "linear", Angle: 180, Stops: []GradientStop{
+			{Color: "#E40303", Offset: 0},
+			{Color: "#A85C00", Offset: 20},
+			{Color: "#756D00", Offset: 40},
+			{Color: "#008026", Offset: 60},
+			{Color: "#24408E", Offset: 80},
+			{Color: "#732982", Offset: 100},
+		}},
+		WAVE0: "#F58600",
+		WAVE1: "#A63BBC",
+	},
 }
 
 func getTheme(name string) (*ThemePalette, error) {
+	return resolveTheme(name, nil)
+}
+
+// resolveTheme looks name up in the themes of the project config, then in
+// the built-in ones.
+func resolveTheme(name string, custom map[string]ThemePalette) (*ThemePalette, error) {
+	if theme, ok := custom[name]; ok {
+		return &theme, nil
+	}
 	theme, ok := themes[name]
 	if !ok {
-		availableThemes := make([]string, 0, len(themes))
+		availableThemes := make([]string, 0, len(themes)+len(custom))
 		for k := range themes {
 			availableThemes = append(availableThemes, k)
 		}
+		for k := range custom {
+			availableThemes = append(availableThemes, k)
+		}
+		sort.Strings(availableThemes)
 		return nil, fmt.Errorf("unknown theme %q. Use: %s", name, strings.Join(availableThemes, ", "))
 	}
 	return &theme, nil
 }
 
+// validateTheme completes a theme from the project config: stops without an
+// offset are placed, and the wave colors default to the first and last stop.
+func validateTheme(theme *ThemePalette) error {
+	theme.Background.normalize()
+	if err := theme.Background.validate(); err != nil {
+		return err
+	}
+	stops := theme.Background.Stops
+	if theme.WAVE0 == "" {
+		theme.WAVE0 = stops[0].Color
+	}
+	if theme.WAVE1 == "" {
+		theme.WAVE1 = stops[len(stops)-1].Color
+	}
+	for _, c := range []string{theme.WAVE0, theme.WAVE1} {
+		if _, ok := parseColor(c); !ok {
+			return fmt.Errorf("invalid wave color %q", c)
+		}
+	}
+	return nil
+}
+
+// backgroundColors returns three colors of the background gradient for the
+// BG0 to BG2 variables and slots: the first stop, the one nearest the
+// middle and the last.
+func (t *ThemePalette) backgroundColors() (bg0, bg1, bg2 string) {
+	stops := t.Background.Stops
+	middle := stops[0]
+	for _, stop := range stops[1:] {
+		if math.Abs(stop.Offset-50) < math.Abs(middle.Offset-50) {
+			middle = stop
+		}
+	}
+	return stops[0].Color, middle.Color, stops[len(stops)-1].Color
+}
+
 func escapeXML(s string) string {
 	var result strings.Builder
 	result.Grow(len(s))
@@ -77,33 +149,190 @@ func escapeXML(s string) string {
 	return result.String()
 }
 
-func replaceVariables(svg string, vars map[string]string) string {
-	result := svg
-	for k, v := range vars {
-		placeholder := fmt.Sprintf("{{%s}}", k)
-		result = strings.ReplaceAll(result, placeholder, escapeXML(v))
+// replaceVariables substitutes {{NAME}} placeholders in every attribute value
+// and text node of the document. Like variable names, placeholder names are
+// case-insensitive: vars holds them upper-cased. Values are escaped when the
+// document is serialized.
+func replaceVariables(doc *SVGDocument, vars map[string]string) {
+	replace := func(s string) string {
+		if !strings.Contains(s, "{{") {
+			return s
+		}
+		return placeholderRe.ReplaceAllStringFunc(s, func(m string) string {
+			if v, ok := vars[strings.ToUpper(m[2:len(m)-2])]; ok {
+				return v
+			}
+			return m
+		})
+	}
+
+	doc.Root.Walk(func(n *SVGNode) bool {
+		switch n.Kind {
+		case ElementNode:
+			for i := range n.Attrs {
+				n.Attrs[i].Value = replace(n.Attrs[i].Value)
+			}
+		case TextNode:
+			n.Text = replace(n.Text)
+		}
+		return true
+	})
+}
+
+var (
+	varNameRe     = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	placeholderRe = regexp.MustCompile(`\{\{([A-Za-z0-9_]+)\}\}`)
+)
+
+// normalizeVarName validates a user-defined variable name and upper-cases it,
+// so README markers, flags and config keys all map to the same placeholder.
+func normalizeVarName(name string) (string, error) {
+	if !varNameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid variable name %q: use letters, digits and underscores", name)
+	}
+	return strings.ToUpper(name), nil
+}
+
+func normalizeVars(vars map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(vars))
+	for name, value := range vars {
+		key, err := normalizeVarName(name)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// mergeVars combines variable sets, with later sets taking precedence.
+func mergeVars(sets ...map[string]string) map[string]string {
+	result := make(map[string]string)
+	for _, set := range sets {
+		for k, v := range set {
+			result[k] = v
+		}
 	}
 	return result
 }
 
-func stripBadge(svg string, n int) string {
-	startMarker := fmt.Sprintf("<!--BADGE%d_START-->", n)
-	endMarker := fmt.Sprintf("<!--BADGE%d_END-->", n)
+// addUserVars adds user-defined variables to the built-in ones. User
+// variables cannot shadow built-in names.
+func addUserVars(vars, userVars map[string]string) error {
+	for name, value := range userVars {
+		if _, ok := vars[name]; ok {
+			return fmt.Errorf("variable %q is reserved and cannot be overridden", name)
+		}
+		vars[name] = value
+	}
+	return nil
+}
 
-	startIdx := strings.Index(svg, startMarker)
-	if startIdx == -1 {
-		return svg
+// checkUndefinedVariables reports every placeholder in the template that has
+// no value in vars, matching names as replaceVariables does.
+func checkUndefinedVariables(template string, vars map[string]string) error {
+	seen := make(map[string]bool)
+	for _, match := range placeholderRe.FindAllStringSubmatch(template, -1) {
+		if _, ok := vars[strings.ToUpper(match[1])]; !ok {
+			seen[match[1]] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil
 	}
 
-	endIdx := strings.Index(svg, endMarker)
-	if endIdx == -1 {
-		return svg
+	missing := make([]string, 0, len(seen))
+	for name := range seen {
+		missing = append(missing, name)
 	}
+	sort.Strings(missing)
+	return fmt.Errorf("template references undefined variables: %s", strings.Join(missing, ", "))
+}
 
-	endIdx += len(endMarker)
-	for endIdx < len(svg) && (svg[endIdx] == ' ' || svg[endIdx] == '\n' || svg[endIdx] == '\r' || svg[endIdx] == '\t') {
-		endIdx++
+// setSlotText fills the element with the given id. Slots may be a <text>
+// element or a group containing one; the first <text> is used. Design tools
+// wrap text in a positioned <tspan>, so the first one is kept and any
+// further lines are dropped.
+func setSlotText(doc *SVGDocument, id, text string) bool {
+	slot := slotTextTarget(doc, id)
+	if slot == nil {
+		return false
 	}
+	slot.SetText(text)
+	return true
+}
 
-	return svg[:startIdx] + svg[endIdx:]
+// slotTextTarget returns the element whose content setSlotText replaces,
+// dropping the <tspan>s after the first one.
+func slotTextTarget(doc *SVGDocument, id string) *SVGNode {
+	slot := doc.Root.FindByID(id)
+	if slot == nil {
+		return nil
+	}
+	if slot.Name != "text" && slot.Name != "tspan" {
+		texts := slot.FindByName("text")
+		if len(texts) == 0 {
+			return nil
+		}
+		slot = texts[0]
+	}
+	if slot.Name == "text" {
+		if spans := slot.FindByName("tspan"); len(spans) > 0 {
+			for _, span := range spans[1:] {
+				span.Remove()
+			}
+			slot = spans[0]
+		}
+	}
+	return slot
+}
+
+// themeSlots maps the ids of gradient stops to the theme colors they take.
+func themeSlots(theme *ThemePalette) map[string]string {
+	bg0, bg1, bg2 := theme.backgroundColors()
+	return map[string]string{
+		"bg-stop-0":   bg0,
+		"bg-stop-1":   bg1,
+		"bg-stop-2":   bg2,
+		"wave-stop-0": theme.WAVE0,
+		"wave-stop-1": theme.WAVE1,
+	}
+}
+
+func applyThemeSlots(doc *SVGDocument, theme *ThemePalette) {
+	for id, color := range themeSlots(theme) {
+		if stop := doc.Root.FindByID(id); stop != nil {
+			setPaint(stop, "stop-color", color)
+		}
+	}
+}
+
+// setPaint sets a presentation attribute. Editors such as Inkscape store
+// paint in the style attribute, which takes precedence, so a matching style
+// property is updated as well.
+func setPaint(n *SVGNode, property, value string) {
+	n.SetAttr(property, value)
+
+	style, ok := n.Attr("style")
+	if !ok {
+		return
+	}
+	declarations := strings.Split(style, ";")
+	for i, decl := range declarations {
+		name, _, found := strings.Cut(decl, ":")
+		if found && strings.TrimSpace(name) == property {
+			declarations[i] = property + ":" + value
+		}
+	}
+	n.SetAttr("style", strings.Join(declarations, ";"))
+}
+
+func badgeID(n int) string {
+	return fmt.Sprintf("badge-%d", n)
+}
+
+func stripBadge(doc *SVGDocument, n int) {
+	if badge := doc.Root.FindByID(badgeID(n)); badge != nil {
+		badge.Remove()
+	}
 }
@@ -28,6 +28,11 @@ func TestGetTheme(t *testing.T) {
 			themeName:   "dark",
 			expectError: false,
 		},
+		{
+			name:        "pride theme",
+			themeName:   "pride",
+			expectError: false,
+		},
 		{
 			name:        "invalid theme",
 			themeName:   "invalid",
@@ -51,9 +56,9 @@ func TestGetTheme(t *testing.T) {
 			} else {
 				require.NoError(t, err)
 				require.NotNil(t, theme)
-				assert.NotEmpty(t, theme.BG0)
-				assert.NotEmpty(t, theme.BG1)
-				assert.NotEmpty(t, theme.BG2)
+				assert.NotEmpty(t, theme.Background.Stops[0].Color)
+				assert.NotEmpty(t, theme.Background.Stops[1].Color)
+				assert.NotEmpty(t, theme.Background.Stops[2].Color)
 				assert.NotEmpty(t, theme.WAVE0)
 				assert.NotEmpty(t, theme.WAVE1)
 			}
@@ -65,9 +70,9 @@ func TestGetThemeValidation(t *testing.T) {
 	t.Run("light theme has correct colors", func(t *testing.T) {
 		theme, err := getTheme("light")
 		require.NoError(t, err)
-		assert.Equal(t, "#8BCFE6", theme.BG0)
-		assert.Equal(t, "#F2B5C8", theme.BG1)
-		assert.Equal(t, "#F8F9FB", theme.BG2)
+		assert.Equal(t, "#8BCFE6", theme.Background.Stops[0].Color)
+		assert.Equal(t, "#F2B5C8", theme.Background.Stops[1].Color)
+		assert.Equal(t, "#F8F9FB", theme.Background.Stops[2].Color)
 		assert.Equal(t, "#9DD7EC", theme.WAVE0)
 		assert.Equal(t, "#F6AFC3", theme.WAVE1)
 	})
@@ -75,9 +80,9 @@ func TestGetThemeValidation(t *testing.T) {
 	t.Run("muted theme has correct colors", func(t *testing.T) {
 		theme, err := getTheme("muted")
 		require.NoError(t, err)
-		assert.Equal(t, "#7FC3DD", theme.BG0)
-		assert.Equal(t, "#EFAEC2", theme.BG1)
-		assert.Equal(t, "#F3F5F7", theme.BG2)
+		assert.Equal(t, "#7FC3DD", theme.Background.Stops[0].Color)
+		assert.Equal(t, "#EFAEC2", theme.Background.Stops[1].Color)
+		assert.Equal(t, "#F3F5F7", theme.Background.Stops[2].Color)
 		assert.Equal(t, "#8FCFE3", theme.WAVE0)
 		assert.Equal(t, "#F2A7BE", theme.WAVE1)
 	})
@@ -85,14 +90,63 @@ func TestGetThemeValidation(t *testing.T) {
 	t.Run("dark theme has correct colors", func(t *testing.T) {
 		theme, err := getTheme("dark")
 		require.NoError(t, err)
-		assert.Equal(t, "#245A74", theme.BG0)
-		assert.Equal(t, "#7A3651", theme.BG1)
-		assert.Equal(t, "#0F1720", theme.BG2)
+		assert.Equal(t, "#245A74", theme.Background.Stops[0].Color)
+		assert.Equal(t, "#7A3651", theme.Background.Stops[1].Color)
+		assert.Equal(t, "#0F1720", theme.Background.Stops[2].Color)
 		assert.Equal(t, "#3A7C96", theme.WAVE0)
 		assert.Equal(t, "#A35A74", theme.WAVE1)
 	})
 }
 
+func TestPrideTheme(t *testing.T) {
+	theme, err := getTheme("pride")
+	require.NoError(t, err)
+	require.NoError(t, theme.Background.validate())
+	assert.Len(t, theme.Background.Stops, 6)
+	for _, stop := range theme.Background.Stops {
+		c, _ := parseColor(stop.Color)
+		assert.GreaterOrEqual(t, textContrast(c), minTextContrast, stop.Color)
+	}
+}
+
+func TestResolveTheme(t *testing.T) {
+	custom := map[string]ThemePalette{"brand": {Background: Gradient{Type: "radial", Stops: []GradientStop{{"#111111", 0}, {"#222222", 100}}}}}
+
+	theme, err := resolveTheme("brand", custom)
+	require.NoError(t, err)
+	assert.Equal(t, "radial", theme.Background.Type)
+
+	theme, err = resolveTheme("dark", custom)
+	require.NoError(t, err)
+	assert.Equal(t, "#245A74", theme.Background.Stops[0].Color)
+
+	_, err = resolveTheme("sunset", custom)
+	assert.EqualError(t, err, `unknown theme "sunset". Use: brand, dark, light, muted, pride`)
+}
+
+func TestValidateTheme(t *testing.T) {
+	theme := ThemePalette{Background: Gradient{Stops: []GradientStop{{"#111111", -1}, {"#222222", -1}, {"#333333", -1}}}, WAVE1: "#444444"}
+	require.NoError(t, validateTheme(&theme))
+	assert.Equal(t, "linear", theme.Background.Type)
+	assert.Equal(t, 50.0, theme.Background.Stops[1].Offset)
+	assert.Equal(t, "#111111", theme.WAVE0, "wave colors default to the stops")
+	assert.Equal(t, "#444444", theme.WAVE1)
+
+	theme = ThemePalette{Background: Gradient{Stops: []GradientStop{{"#111111", -1}, {"#222222", -1}}}, WAVE0: "teal"}
+	assert.EqualError(t, validateTheme(&theme), `invalid wave color "teal"`)
+}
+
+func TestThemeBackgroundColors(t *testing.T) {
+	pride, err := getTheme("pride")
+	require.NoError(t, err)
+	bg0, bg1, bg2 := pride.backgroundColors()
+	assert.Equal(t, []string{"#E40303", "#756D00", "#732982"}, []string{bg0, bg1, bg2})
+
+	two := &ThemePalette{Background: Gradient{Stops: []GradientStop{{"#111111", 0}, {"#222222", 100}}}}
+	bg0, bg1, bg2 = two.backgroundColors()
+	assert.Equal(t, []string{"#111111", "#111111", "#222222"}, []string{bg0, bg1, bg2})
+}
+
 func TestEscapeXML(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -163,59 +217,80 @@ func TestReplaceVariables(t *testing.T) {
 	}{
 		{
 			name:     "single variable",
-			template: "Hello {{NAME}}",
+			template: "<svg>Hello {{NAME}}</svg>",
 			vars:     map[string]string{"NAME": "World"},
-			expected: "Hello World",
+			expected: "<svg>Hello World</svg>\n",
 		},
 		{
 			name:     "multiple variables",
-			template: "{{GREETING}} {{NAME}}, welcome to {{PLACE}}",
+			template: "<svg>{{GREETING}} {{NAME}}, welcome to {{PLACE}}</svg>",
 			vars: map[string]string{
 				"GREETING": "Hello",
 				"NAME":     "Alice",
 				"PLACE":    "Wonderland",
 			},
-			expected: "Hello Alice, welcome to Wonderland",
+			expected: "<svg>Hello Alice, welcome to Wonderland</svg>\n",
+		},
+		{
+			name:     "lower-case placeholder",
+			template: "<svg>{{version}} {{Version}}</svg>",
+			vars:     map[string]string{"VERSION": "v2"},
+			expected: "<svg>v2 v2</svg>\n",
 		},
 		{
 			name:     "variable with special characters gets escaped",
-			template: "<text>{{CONTENT}}</text>",
+			template: "<svg><text>{{CONTENT}}</text></svg>",
 			vars:     map[string]string{"CONTENT": "Tom & Jerry"},
-			expected: "<text>Tom &amp; Jerry</text>",
+			expected: "<svg><text>Tom &amp; Jerry</text></svg>\n",
 		},
 		{
 			name:     "variable with unicode",
-			template: "{{TITLE}}",
+			template: "<svg>{{TITLE}}</svg>",
 			vars:     map[string]string{"TITLE": "🚀 Project"},
-			expected: "&#128640; Project",
+			expected: "<svg>&#128640; Project</svg>\n",
+		},
+		{
+			name:     "variables in attributes",
+			template: `<svg width="{{WIDTH}}"><rect fill="{{COLOR}}" title="{{TITLE}}"/></svg>`,
+			vars:     map[string]string{"WIDTH": "1200", "COLOR": "#FFF", "TITLE": `"quoted" <b>`},
+			expected: `<svg width="1200"><rect fill="#FFF" title="&quot;quoted&quot; &lt;b&gt;"/></svg>` + "\n",
 		},
 		{
 			name:     "no variables",
-			template: "Static content",
+			template: "<svg>Static content</svg>",
 			vars:     map[string]string{},
-			expected: "Static content",
+			expected: "<svg>Static content</svg>\n",
 		},
 		{
 			name:     "unused variables",
-			template: "Hello {{NAME}}",
+			template: "<svg>Hello {{NAME}}</svg>",
 			vars: map[string]string{
 				"NAME":  "World",
 				"EXTRA": "Ignored",
 			},
-			expected: "Hello World",
+			expected: "<svg>Hello World</svg>\n",
 		},
 		{
 			name:     "missing variables remain unchanged",
-			template: "Hello {{NAME}}, from {{PLACE}}",
+			template: "<svg>Hello {{NAME}}, from {{PLACE}}</svg>",
 			vars:     map[string]string{"NAME": "World"},
-			expected: "Hello World, from {{PLACE}}",
+			expected: "<svg>Hello World, from {{PLACE}}</svg>\n",
+		},
+		{
+			name:     "values are not expanded recursively",
+			template: "<svg>{{A}}</svg>",
+			vars:     map[string]string{"A": "{{B}}", "B": "nope"},
+			expected: "<svg>{{B}}</svg>\n",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := replaceVariables(tt.template, tt.vars)
-			assert.Equal(t, tt.expected, result)
+			doc, err := parseSVG(tt.template)
+			require.NoError(t, err)
+
+			replaceVariables(doc, tt.vars)
+			assert.Equal(t, tt.expected, doc.String())
 		})
 	}
 }
@@ -230,102 +305,212 @@ func TestStripBadge(t *testing.T) {
 		{
 			name: "strip badge 1",
 			svg: `<svg>
-<!--BADGE1_START-->
+<g id="badge-1">
 <rect x="10" y="10" width="100" height="20"/>
-<!--BADGE1_END-->
+</g>
 <circle cx="50" cy="50" r="40"/>
 </svg>`,
 			badgeNum: 1,
 			expected: `<svg>
 <circle cx="50" cy="50" r="40"/>
-</svg>`,
+</svg>
+`,
 		},
 		{
-			name: "strip badge 2 with trailing whitespace",
+			name: "strip badge 2 with indentation",
 			svg: `<svg>
-<!--BADGE2_START-->
-<text>Badge 2</text>
-<!--BADGE2_END-->   
-<circle/>
+    <g id="badge-2"><text>Badge 2</text></g>
+    <circle/>
 </svg>`,
 			badgeNum: 2,
 			expected: `<svg>
-<circle/>
-</svg>`,
+    <circle/>
+</svg>
+`,
 		},
 		{
-			name: "strip badge 3 with newline after",
+			name: "strip badge that is a text element",
 			svg: `<svg>
-<!--BADGE3_START-->
-<g>Badge 3</g>
-<!--BADGE3_END-->
-
-<rect/>
+  <text id="badge-3">Badge 3</text>
+  <rect/>
 </svg>`,
 			badgeNum: 3,
 			expected: `<svg>
-<rect/>
-</svg>`,
+  <rect/>
+</svg>
+`,
 		},
 		{
-			name: "badge markers not present",
+			name: "badge not present",
 			svg: `<svg>
 <circle cx="50" cy="50" r="40"/>
 </svg>`,
 			badgeNum: 1,
 			expected: `<svg>
 <circle cx="50" cy="50" r="40"/>
-</svg>`,
+</svg>
+`,
 		},
 		{
-			name: "only start marker present",
+			name: "nested badge",
 			svg: `<svg>
-<!--BADGE1_START-->
-<rect/>
+<g id="card">
+  <g id="badge-1"><text>Badge 1</text></g>
+</g>
 </svg>`,
 			badgeNum: 1,
 			expected: `<svg>
-<!--BADGE1_START-->
-<rect/>
-</svg>`,
-		},
-		{
-			name: "only end marker present",
-			svg: `<svg>
-<rect/>
-<!--BADGE1_END-->
-</svg>`,
-			badgeNum: 1,
-			expected: `<svg>
-<rect/>
-<!--BADGE1_END-->
-</svg>`,
+<g id="card">
+</g>
+</svg>
+`,
 		},
 		{
 			name: "multiple badges strip specific one",
 			svg: `<svg>
-<!--BADGE1_START-->
-<text>Badge 1</text>
-<!--BADGE1_END-->
-<!--BADGE2_START-->
-<text>Badge 2</text>
-<!--BADGE2_END-->
+<g id="badge-1"><text>Badge 1</text></g>
+<g id="badge-2"><text>Badge 2</text></g>
 <circle/>
 </svg>`,
 			badgeNum: 1,
 			expected: `<svg>
-<!--BADGE2_START-->
-<text>Badge 2</text>
-<!--BADGE2_END-->
+<g id="badge-2"><text>Badge 2</text></g>
 <circle/>
-</svg>`,
+</svg>
+`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := stripBadge(tt.svg, tt.badgeNum)
-			assert.Equal(t, tt.expected, result)
+			doc, err := parseSVG(tt.svg)
+			require.NoError(t, err)
+
+			stripBadge(doc, tt.badgeNum)
+			assert.Equal(t, tt.expected, doc.String())
 		})
 	}
 }
+
+func TestSetSlotText(t *testing.T) {
+	svg := `<svg><text id="title">old</text><g id="badge-1"><rect/><text>x</text></g><g id="empty"/></svg>`
+
+	doc, err := parseSVG(svg)
+	require.NoError(t, err)
+
+	assert.True(t, setSlotText(doc, "title", "New & Improved"))
+	assert.True(t, setSlotText(doc, "badge-1", "v1.0"))
+	assert.False(t, setSlotText(doc, "empty", "ignored"))
+	assert.False(t, setSlotText(doc, "missing", "ignored"))
+
+	assert.Equal(t, `<svg><text id="title">New &amp; Improved</text><g id="badge-1"><rect/><text>v1.0</text></g><g id="empty"/></svg>`+"\n", doc.String())
+
+	t.Run("tspan-wrapped text keeps the first span", func(t *testing.T) {
+		doc, err := parseSVG(`<svg><text id="title"><tspan x="1">one</tspan><tspan x="2">two</tspan></text><tspan id="tagline">old</tspan></svg>`)
+		require.NoError(t, err)
+
+		assert.True(t, setSlotText(doc, "title", "New"))
+		assert.True(t, setSlotText(doc, "tagline", "Line"))
+		assert.Equal(t, `<svg><text id="title"><tspan x="1">New</tspan></text><tspan id="tagline">Line</tspan></svg>`+"\n", doc.String())
+	})
+}
+
+func TestApplyThemeSlots(t *testing.T) {
+	theme, err := getTheme("muted")
+	require.NoError(t, err)
+
+	doc, err := parseSVG(`<svg>
+<stop id="bg-stop-0" stop-color="#000"/>
+<stop id="bg-stop-2" style="stop-color:#000;stop-opacity:0.5"/>
+<stop id="wave-stop-1"/>
+<stop id="other" stop-color="#000"/>
+</svg>`)
+	require.NoError(t, err)
+
+	applyThemeSlots(doc, theme)
+
+	assert.Equal(t, `<svg>
+<stop id="bg-stop-0" stop-color="#7FC3DD"/>
+<stop id="bg-stop-2" style="stop-color:#F3F5F7;stop-opacity:0.5" stop-color="#F3F5F7"/>
+<stop id="wave-stop-1" stop-color="#F2A7BE"/>
+<stop id="other" stop-color="#000"/>
+</svg>
+`, doc.String())
+}
+
+func TestNormalizeVarName(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{input: "VERSION", expected: "VERSION"},
+		{input: "author", expected: "AUTHOR"},
+		{input: "repo_url2", expected: "REPO_URL2"},
+		{input: "2FAST", expectError: true},
+		{input: "my-var", expectError: true},
+		{input: "", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			name, err := normalizeVarName(tt.input)
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestMergeVars(t *testing.T) {
+	config := map[string]string{"VERSION": "1.0", "AUTHOR": "config"}
+	readme := map[string]string{"VERSION": "2.0"}
+	cli := map[string]string{"AUTHOR": "cli"}
+
+	result := mergeVars(config, readme, cli)
+	assert.Equal(t, map[string]string{"VERSION": "2.0", "AUTHOR": "cli"}, result)
+}
+
+func TestAddUserVars(t *testing.T) {
+	t.Run("adds new variables", func(t *testing.T) {
+		vars := map[string]string{"BG0": "#000"}
+		err := addUserVars(vars, map[string]string{"VERSION": "1.0"})
+		require.NoError(t, err)
+		assert.Equal(t, "1.0", vars["VERSION"])
+	})
+
+	t.Run("rejects built-in names", func(t *testing.T) {
+		vars := map[string]string{"BG0": "#000"}
+		err := addUserVars(vars, map[string]string{"BG0": "#FFF"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "reserved")
+		assert.Equal(t, "#000", vars["BG0"])
+	})
+}
+
+func TestCheckUndefinedVariables(t *testing.T) {
+	t.Run("all variables defined", func(t *testing.T) {
+		err := checkUndefinedVariables("{{NAME}} v{{VERSION}}", map[string]string{"NAME": "x", "VERSION": "1"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("empty value counts as defined", func(t *testing.T) {
+		err := checkUndefinedVariables("{{BADGE_1}}", map[string]string{"BADGE_1": ""})
+		assert.NoError(t, err)
+	})
+
+	t.Run("reports sorted unique missing names", func(t *testing.T) {
+		err := checkUndefinedVariables("{{URL}} {{AUTHOR}} {{URL}} {{NAME}}", map[string]string{"NAME": "x"})
+		require.Error(t, err)
+		assert.Equal(t, "template references undefined variables: AUTHOR, URL", err.Error())
+	})
+
+	t.Run("placeholder names are case-insensitive", func(t *testing.T) {
+		assert.NoError(t, checkUndefinedVariables("{{version}}", map[string]string{"VERSION": "1"}))
+		assert.EqualError(t, checkUndefinedVariables("{{author}}", map[string]string{}), "template references undefined variables: author")
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// metricFace selects one of the embedded faces.
+type metricFace struct {
+	bold bool
+	mono bool
+}
+
+// metricFonts parses the embedded faces once per process. Text is measured
+// with them whichever font ends up rendering it, so the layout does not
+// depend on the machine either.
+var metricFonts = sync.OnceValues(func() (map[metricFace]*sfnt.Font, error) {
+	embedded, err := embeddedFonts()
+	if err != nil {
+		return nil, err
+	}
+	faces := map[metricFace]*sfnt.Font{}
+	for _, f := range embedded {
+		parsed, err := sfnt.Parse(f.data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse embedded font %s: %w", f.name, err)
+		}
+		var buf sfnt.Buffer
+		family, err := parsed.Name(&buf, sfnt.NameIDFamily)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse embedded font %s: %w", f.name, err)
+		}
+		subfamily, err := parsed.Name(&buf, sfnt.NameIDSubfamily)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse embedded font %s: %w", f.name, err)
+		}
+		faces[metricFace{bold: subfamily == "Bold", mono: family == embeddedMonoFamily}] = parsed
+	}
+	for _, face := range []metricFace{{}, {bold: true}, {mono: true}, {bold: true, mono: true}} {
+		if faces[face] == nil {
+			return nil, fmt.Errorf("embedded fonts lack a face (bold: %t, mono: %t)", face.bold, face.mono)
+		}
+	}
+	return faces, nil
+})
+
+// measureText returns the advance width of text set in the embedded font at
+// size, in the unit of size. Kerning is applied where the font has it.
+func measureText(text string, size float64, bold bool) (float64, error) {
+	return measureFace(text, size, metricFace{bold: bold})
+}
+
+// measureFace is measureText with a choice of the monospace face.
+func measureFace(text string, size float64, face metricFace) (float64, error) {
+	faces, err := metricFonts()
+	if err != nil {
+		return 0, err
+	}
+	f := faces[face]
+
+	// Advances are read in font units and scaled afterwards, which keeps
+	// them exact for any size.
+	unitsPerEm := fixed.Int26_6(f.UnitsPerEm())
+	ppem := unitsPerEm << 6
+	var buf sfnt.Buffer
+	var width fixed.Int26_6
+	var prev sfnt.GlyphIndex
+	for i, r := range text {
+		glyph, err := f.GlyphIndex(&buf, r)
+		if err != nil {
+			return 0, err
+		}
+		advance, err := f.GlyphAdvance(&buf, glyph, ppem, font.HintingNone)
+		if err != nil {
+			return 0, err
+		}
+		if i > 0 {
+			if kern, err := f.Kern(&buf, prev, glyph, ppem, font.HintingNone); err == nil {
+				width += kern
+			}
+		}
+		width += advance
+		prev = glyph
+	}
+	return float64(width) / 64 * size / float64(unitsPerEm), nil
+}
+
+// missingGlyph returns the first rune of text the face has no glyph for.
+// Such text is drawn with another font, so its measured width is wrong.
+func missingGlyph(text string, face metricFace) (rune, bool) {
+	faces, err := metricFonts()
+	if err != nil {
+		return 0, false
+	}
+	var buf sfnt.Buffer
+	for _, r := range text {
+		if glyph, err := faces[face].GlyphIndex(&buf, r); err == nil && glyph == 0 {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// estimateFace is measureFace for text the face lacks glyphs for. Those
+// characters are drawn with another font, so they count as one em when they
+// are wide, such as CJK characters and emoji, and as 0.6 em otherwise;
+// joiners, selectors and combining marks count as zero.
+func estimateFace(text string, size float64, face metricFace) (float64, error) {
+	faces, err := metricFonts()
+	if err != nil {
+		return 0, err
+	}
+	var buf sfnt.Buffer
+	width := 0.0
+	for _, r := range text {
+		if glyph, err := faces[face].GlyphIndex(&buf, r); err == nil && glyph != 0 {
+			advance, err := measureFace(string(r), size, face)
+			if err != nil {
+				return 0, err
+			}
+			width += advance
+			continue
+		}
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) || unicode.Is(unicode.Variation_Selector, r):
+		case wideRune(r):
+			width += size
+		default:
+			width += 0.6 * size
+		}
+	}
+	return width, nil
+}
+
+// wideRune reports whether r is drawn one em wide: East Asian scripts,
+// full-width forms and pictographs.
+func wideRune(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.So) ||
+		r >= 0x3000 && r <= 0x303F || r >= 0xFF01 && r <= 0xFF60
+}
+
+// measureSpans returns the advance width of styled text, with code spans
+// set in the monospace face.
+func measureSpans(spans []inlineSpan, size float64, bold bool) (float64, error) {
+	total := 0.0
+	for _, span := range spans {
+		width, err := measureFace(span.Text, size, metricFace{bold: bold || span.Bold, mono: span.Code})
+		if err != nil {
+			return 0, err
+		}
+		total += width
+	}
+	return total, nil
+}
+
+// fitFontSize returns size, reduced so text fits in maxWidth. The size is
+// kept when the text already fits or cannot be measured.
+func fitFontSize(text string, size, maxWidth float64, bold bool) float64 {
+	return fitSpansSize([]inlineSpan{{Text: text}}, size, maxWidth, bold)
+}
+
+// fitSpansSize is fitFontSize for styled text.
+func fitSpansSize(spans []inlineSpan, size, maxWidth float64, bold bool) float64 {
+	width, err := measureSpans(spans, size, bold)
+	if err != nil || width <= maxWidth || maxWidth <= 0 {
+		return size
+	}
+	// Round down so the formatted size never overflows again.
+	return math.Floor(size*maxWidth/width*100) / 100
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasureText(t *testing.T) {
+	empty, err := measureText("", 32, false)
+	require.NoError(t, err)
+	assert.Zero(t, empty)
+
+	regular, err := measureText("Banner Kit", 32, false)
+	require.NoError(t, err)
+	assert.Greater(t, regular, 100.0)
+
+	double, err := measureText("Banner Kit", 64, false)
+	require.NoError(t, err)
+	assert.InDelta(t, 2*regular, double, 0.001)
+
+	bold, err := measureText("Banner Kit", 32, true)
+	require.NoError(t, err)
+	assert.Greater(t, bold, regular)
+
+	wide, err := measureText("WWW", 32, false)
+	require.NoError(t, err)
+	narrow, err := measureText("iii", 32, false)
+	require.NoError(t, err)
+	assert.Greater(t, wide, narrow)
+
+	// Every character of the monospace face has the same advance.
+	monoWide, err := measureFace("WWW", 32, metricFace{mono: true})
+	require.NoError(t, err)
+	monoNarrow, err := measureFace("iii", 32, metricFace{mono: true})
+	require.NoError(t, err)
+	assert.InDelta(t, monoWide, monoNarrow, 0.001)
+
+	spans, err := measureSpans([]inlineSpan{{Text: "Kit "}, {Text: "iii", Code: true}}, 32, false)
+	require.NoError(t, err)
+	kit, err := measureText("Kit ", 32, false)
+	require.NoError(t, err)
+	assert.InDelta(t, kit+monoNarrow, spans, 0.001)
+}
+
+func TestFitFontSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxWidth float64
+		shrinks  bool
+	}{
+		{name: "short text keeps its size", text: "Kit", maxWidth: 1000},
+		{name: "long text shrinks", text: "a-very-long-project-name-that-overflows", maxWidth: 500, shrinks: true},
+		{name: "no width limit", text: "a-very-long-project-name-that-overflows", maxWidth: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := fitFontSize(tt.text, 88, tt.maxWidth, true)
+			if !tt.shrinks {
+				assert.Equal(t, 88.0, size)
+				return
+			}
+			assert.Less(t, size, 88.0)
+			width, err := measureText(tt.text, size, true)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, width, tt.maxWidth)
+			assert.Greater(t, width, tt.maxWidth*0.99)
+		})
+	}
+}